@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ================= 确认按钮 =================
+// FEISHU_SHOW_ACK_BUTTON 开启后, 卡片底部附加一个 "确认收到" 按钮, value 中携带 turn-id。
+// 本程序是一次性 CLI, 无法自己接收回调, 回调地址 FEISHU_CALLBACK_URL 需由用户提供:
+//   - 按钮以 open_url 方式打开 FEISHU_CALLBACK_URL?action=ack&turn_id=...&thread_id=...,
+//     群机器人 Webhook 卡片也可使用;
+//   - 自建应用若在开发者后台把卡片回调地址配置为同一服务, 点击时还会收到 value 回传。
+
+const ackAction = "ack"
+
+// FeishuAction 是 1.0 卡片的交互模块
+type FeishuAction struct {
+	Tag     string         `json:"tag"`
+	Actions []FeishuButton `json:"actions"`
+}
+
+// FeishuButton 是 1.0 卡片的按钮
+type FeishuButton struct {
+	Tag   string            `json:"tag"`
+	Text  FeishuText        `json:"text"`
+	Type  string            `json:"type"`
+	URL   string            `json:"url,omitempty"`
+	Value map[string]string `json:"value,omitempty"`
+}
+
+// FeishuButtonV2 是 2.0 卡片的按钮, 跳转与回调通过 behaviors 声明
+type FeishuButtonV2 struct {
+	Tag       string           `json:"tag"`
+	Text      FeishuText       `json:"text"`
+	Type      string           `json:"type"`
+	Behaviors []buttonBehavior `json:"behaviors"`
+}
+
+type buttonBehavior struct {
+	Type       string            `json:"type"`
+	DefaultURL string            `json:"default_url,omitempty"`
+	Value      map[string]string `json:"value,omitempty"`
+}
+
+// ackValue 按钮回传的数据
+func ackValue(n CodexNotification) map[string]string {
+	return map[string]string{"action": ackAction, "turn_id": n.TurnID, "thread_id": n.ThreadID}
+}
+
+// ackURL 在回调地址上追加 action、turn_id 与 thread_id 查询参数
+func ackURL(callback string, n CodexNotification) string {
+	u, err := url.Parse(callback)
+	if err != nil {
+		return callback
+	}
+	q := u.Query()
+	for k, v := range ackValue(n) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ackElement 构建 1.0 卡片的确认按钮模块
+func ackElement(n CodexNotification, cfg FeishuConfig) FeishuAction {
+	return FeishuAction{
+		Tag: "action",
+		Actions: []FeishuButton{{
+			Tag:   "button",
+			Text:  FeishuText{Tag: "plain_text", Content: cfg.t(msgAckButton)},
+			Type:  "primary",
+			URL:   ackURL(cfg.CallbackURL, n),
+			Value: ackValue(n),
+		}},
+	}
+}
+
+// ackElementV2 构建 2.0 卡片的确认按钮
+func ackElementV2(n CodexNotification, cfg FeishuConfig) FeishuButtonV2 {
+	return FeishuButtonV2{
+		Tag:  "button",
+		Text: FeishuText{Tag: "plain_text", Content: cfg.t(msgAckButton)},
+		Type: "primary",
+		Behaviors: []buttonBehavior{
+			{Type: "open_url", DefaultURL: ackURL(cfg.CallbackURL, n)},
+			{Type: "callback", Value: ackValue(n)},
+		},
+	}
+}
+
+// validateCallbackURL 校验 FEISHU_CALLBACK_URL; 开启确认按钮时必须配置
+func validateCallbackURL(raw string, showAck bool) error {
+	if raw == "" {
+		if showAck {
+			return fmt.Errorf("FEISHU_SHOW_ACK_BUTTON requires FEISHU_CALLBACK_URL")
+		}
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("FEISHU_CALLBACK_URL: invalid URL %q", raw)
+	}
+	return nil
+}
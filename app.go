@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ================= 飞书自建应用 (tenant_access_token) 发送 =================
+// 与 Webhook 机器人不同, 自建应用通过开放平台 API 以应用身份发送消息:
+//   1. POST /open-apis/auth/v3/tenant_access_token/internal 获取 tenant_access_token
+//   2. POST /open-apis/im/v1/messages?receive_id_type=chat_id 发送消息
+// token 缓存在状态目录中, 到期前复用, 避免每次调用都重新获取。
+
+const (
+	backendWebhook = "webhook" // 群机器人 Webhook (默认)
+	backendApp     = "app"     // 自建应用
+
+	defaultOpenAPIBase = "https://open.feishu.cn"
+
+	// tokenRefreshMargin token 到期前提前刷新的时间
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// invalidTokenCodes 开放平台返回的 token 无效/过期错误码, 遇到时清除缓存重新获取
+var invalidTokenCodes = map[int]bool{
+	99991661: true, // missing access token
+	99991663: true, // invalid tenant access token
+	99991668: true, // invalid access token
+}
+
+func init() {
+	registerBackend(backendApp, nil)
+	registerNotifier("feishu-app", newAppNotifier)
+}
+
+type appNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newAppNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendApp {
+		return nil, nil
+	}
+	var missing []string
+	for _, kv := range [][2]string{
+		{"FEISHU_APP_ID", cfg.AppID},
+		{"FEISHU_APP_SECRET", cfg.AppSecret},
+		{"FEISHU_CHAT_ID", cfg.ChatID},
+	} {
+		if kv[1] == "" {
+			missing = append(missing, kv[0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("FEISHU_BACKEND=app requires %s", strings.Join(missing, ", "))
+	}
+	return []Notifier{&appNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (a *appNotifier) Name() string { return "feishu-app" }
+
+func (a *appNotifier) Target() string { return "chat:" + a.cfg.ChatID }
+
+// appMessage 是 im/v1/messages 的请求体, content 为 JSON 字符串
+type appMessage struct {
+	ReceiveID string `json:"receive_id"`
+	MsgType   string `json:"msg_type"`
+	Content   string `json:"content"`
+}
+
+// openAPIResponse 开放平台通用返回结构
+type openAPIResponse struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (a *appNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := a.cfg
+	if cfg.ArchiveDoc && !cfg.DryRun {
+		docURL, err := a.createArchiveDoc(ctx, n)
+		if err != nil {
+			// 归档失败不影响卡片发送
+			logger.Warn("archive document failed, sending card without link", "err", err)
+		} else {
+			cfg.ArchiveDocURL = docURL
+		}
+	}
+
+	if len(cfg.MentionEmails) > 0 && !cfg.DryRun {
+		cfg.EmailMentions = a.resolveMentionEmails(ctx, time.Now())
+	}
+	cfg, fileKey := a.withAttachment(ctx, n, cfg)
+	var buildErr error
+	fitted, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		msgType, content := renderContent(n, cfg)
+		if card, ok := content.(interactiveCard); ok && cfg.EditWindow > 0 {
+			// 只有共享卡片才能被更新
+			content = card.shared()
+		}
+		contentJSON, err := json.Marshal(content)
+		if err != nil {
+			buildErr = err
+		}
+		return appMessage{ReceiveID: cfg.ChatID, MsgType: msgType, Content: string(contentJSON)}
+	})
+	if err != nil {
+		return err
+	}
+	if buildErr != nil {
+		return buildErr
+	}
+	msg := fitted.(appMessage)
+	msgType, contentJSON := msg.MsgType, []byte(msg.Content)
+
+	if a.cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, a.cfg.DryRunIndent, useColor(os.Stdout))
+	}
+
+	if msgType == "interactive" {
+		if updated, err := a.updateRecent(ctx, n.TurnID, contentJSON); err != nil {
+			logger.Warn("update previous card failed, sending a new one", "turnID", n.TurnID, "err", err)
+		} else if updated {
+			a.sendAttachment(ctx, fileKey)
+			return nil
+		}
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	endpoint := a.cfg.OpenAPIBase + "/open-apis/im/v1/messages?receive_id_type=chat_id"
+	var data json.RawMessage
+	err = withRetry(ctx, a.cfg, func() error {
+		var err error
+		data, err = a.callWithToken(ctx, "POST", endpoint, body)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if msgType == "interactive" {
+		a.rememberMessage(n.TurnID, data)
+	}
+	a.sendAttachment(ctx, fileKey)
+	return nil
+}
+
+// callWithToken 携带 tenant_access_token 以 JSON 请求体调用开放平台接口, token 失效时刷新后重试一次
+func (a *appNotifier) callWithToken(ctx context.Context, method, endpoint string, body []byte) (json.RawMessage, error) {
+	return a.callWithTokenAs(ctx, method, endpoint, "application/json; charset=utf-8", body)
+}
+
+// callWithTokenAs 同 callWithToken, 请求体类型由 contentType 指定 (如文件上传的 multipart)
+func (a *appNotifier) callWithTokenAs(ctx context.Context, method, endpoint, contentType string, body []byte) (json.RawMessage, error) {
+	token, err := a.tenantToken(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.requestJSON(ctx, method, endpoint, token, contentType, body)
+	var apiErr *FeishuAPIError
+	if errors.As(err, &apiErr) && invalidTokenCodes[apiErr.Code] {
+		logger.Info("tenant access token rejected, refreshing", "code", apiErr.Code)
+		if token, err = a.tenantToken(ctx, true); err != nil {
+			return nil, err
+		}
+		data, err = a.requestJSON(ctx, method, endpoint, token, contentType, body)
+	}
+	return data, err
+}
+
+// requestJSON 发送请求并解析开放平台的 JSON 返回, 非零 code 转换为 FeishuAPIError
+func (a *appNotifier) requestJSON(ctx context.Context, method, endpoint, token, contentType string, body []byte) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if a.cfg.RequestID != "" {
+		req.Header.Set("X-Request-Id", a.cfg.RequestID)
+	}
+
+	logger.Debug("calling feishu open api", "method", method, "endpoint", endpoint, "bytes", len(body))
+	runMetrics.attempts.Add(1)
+	runMetrics.bytesSent.Add(int64(len(body)))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var apiResp openAPIResponse
+	if jsonErr := json.Unmarshal(respBody, &apiResp); jsonErr != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, &HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Body:       string(respBody),
+				RetryAfter: serverRetryAfter(resp.Header, time.Now()),
+			}
+		}
+		return nil, fmt.Errorf("decode feishu response: %w (payload: %s)", jsonErr, string(respBody))
+	}
+	if apiResp.Code != 0 {
+		return nil, &FeishuAPIError{Code: apiResp.Code, Msg: apiResp.Msg, RetryAfter: serverRetryAfter(resp.Header, time.Now())}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return apiResp.Data, nil
+}
+
+// cachedToken 是缓存在状态目录中的 tenant_access_token
+type cachedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (a *appNotifier) tokenCachePath() string {
+	return filepath.Join(stateDir(a.cfg), "tenant_token.json")
+}
+
+// tenantToken 返回可用的 tenant_access_token, 优先使用未过期的缓存; force 为 true 时强制刷新
+func (a *appNotifier) tenantToken(ctx context.Context, force bool) (string, error) {
+	cache := map[string]cachedToken{}
+	if err := readStateFile(a.tokenCachePath(), &cache); err != nil {
+		logger.Debug("ignoring unreadable token cache", "err", err)
+		cache = map[string]cachedToken{}
+	}
+	if c, ok := cache[a.cfg.AppID]; ok && !force && time.Until(c.ExpiresAt) > tokenRefreshMargin {
+		return c.Token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"app_id": a.cfg.AppID, "app_secret": a.cfg.AppSecret})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.cfg.OpenAPIBase+"/open-apis/auth/v3/tenant_access_token/internal", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch tenant access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 该接口的 token 字段位于顶层而非 data 中
+	var tokenResp struct {
+		Code   int    `json:"code"`
+		Msg    string `json:"msg"`
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("decode tenant access token response (status %d): %w", resp.StatusCode, err)
+	}
+	if tokenResp.Code != 0 {
+		return "", fmt.Errorf("fetch tenant access token: %w", &FeishuAPIError{Code: tokenResp.Code, Msg: tokenResp.Msg})
+	}
+
+	cache[a.cfg.AppID] = cachedToken{
+		Token:     tokenResp.Token,
+		ExpiresAt: time.Now().Add(time.Duration(tokenResp.Expire) * time.Second),
+	}
+	if err := writeStateFile(a.tokenCachePath(), cache); err != nil {
+		logger.Warn("cache tenant access token", "err", err)
+	}
+	return tokenResp.Token, nil
+}
+
+// validateOpenAPIBase 校验开放平台地址
+func validateOpenAPIBase(raw string) (string, error) {
+	if raw == "" {
+		return defaultOpenAPIBase, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("FEISHU_OPEN_API_BASE: invalid URL %q", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// openAPICall 记录模拟开放平台收到的一次接口调用
+type openAPICall struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// openAPIStub 模拟飞书开放平台: 颁发 tenant_access_token, 其余接口按 "METHOD path" 返回 routes 中的响应体
+func openAPIStub(t *testing.T, routes map[string]string) (*httptest.Server, *[]openAPICall) {
+	t.Helper()
+	var calls []openAPICall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal" {
+			io.WriteString(w, `{"code":0,"msg":"ok","tenant_access_token":"t-test","expire":7200}`)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		calls = append(calls, openAPICall{Method: r.Method, Path: r.URL.Path, Body: b})
+		resp, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			resp = `{"code":404,"msg":"not found"}`
+		}
+		io.WriteString(w, resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newTestAppNotifier 构造发往 srv 的自建应用 sink
+func newTestAppNotifier(t *testing.T, srv *httptest.Server, env map[string]string) *appNotifier {
+	t.Helper()
+	if env == nil {
+		env = map[string]string{}
+	}
+	env["FEISHU_BACKEND"] = "app"
+	env["FEISHU_APP_ID"] = "cli_test"
+	env["FEISHU_APP_SECRET"] = "secret"
+	env["FEISHU_CHAT_ID"] = "oc_test"
+	env["FEISHU_OPEN_API_BASE"] = srv.URL
+	env["FEISHU_MAX_RETRIES"] = "0"
+	notifiers, err := newAppNotifier(testConfig(t, env))
+	if err != nil {
+		t.Fatalf("newAppNotifier: %v", err)
+	}
+	a := notifiers[0].(*appNotifier)
+	a.client = srv.Client()
+	return a
+}
+
+// callsTo 返回发往 path 的调用
+func callsTo(calls []openAPICall, method, path string) []openAPICall {
+	var matched []openAPICall
+	for _, c := range calls {
+		if c.Method == method && c.Path == path {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+const (
+	pathMessages  = "/open-apis/im/v1/messages"
+	pathDocuments = "/open-apis/docx/v1/documents"
+	pathDocBlocks = "/open-apis/docx/v1/documents/doxTest/blocks/doxTest/children"
+)
+
+func TestArchiveDocLinkedFromCard(t *testing.T) {
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathDocuments: `{"code":0,"data":{"document":{"document_id":"doxTest"}}}`,
+		"POST " + pathDocBlocks: `{"code":0,"data":{}}`,
+		"POST " + pathMessages:  `{"code":0,"data":{"message_id":"om_1"}}`,
+	})
+	a := newTestAppNotifier(t, srv, map[string]string{
+		"FEISHU_ARCHIVE_DOC":      "1",
+		"FEISHU_DOC_FOLDER_TOKEN": "fldTest",
+	})
+	n := testNotification()
+	n.LastAssistantMessage = strings.Repeat("line\n", docBlocksPerRequest+10) + "final line"
+	if err := a.Send(context.Background(), n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	created := callsTo(*calls, "POST", pathDocuments)
+	if len(created) != 1 {
+		t.Fatalf("got %d create document calls, want 1", len(created))
+	}
+	var doc map[string]string
+	if err := json.Unmarshal(created[0].Body, &doc); err != nil || doc["folder_token"] != "fldTest" || doc["title"] == "" {
+		t.Errorf("create document body = %s", created[0].Body)
+	}
+
+	// 超出单次上限的正文分批写入
+	writes := callsTo(*calls, "POST", pathDocBlocks)
+	if len(writes) != 2 {
+		t.Fatalf("got %d block writes, want 2", len(writes))
+	}
+	if !strings.Contains(string(writes[1].Body), "final line") {
+		t.Error("last block write does not contain the end of the transcript")
+	}
+
+	msgs := callsTo(*calls, "POST", pathMessages)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	if !strings.Contains(string(msgs[0].Body), defaultDocBaseURL+"doxTest") {
+		t.Errorf("card does not link the archive document: %s", msgs[0].Body)
+	}
+}
+
+// 文档接口失败时照常发送卡片, 不附链接
+func TestArchiveDocFailureStillSends(t *testing.T) {
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathDocuments: `{"code":1770032,"msg":"forbidden"}`,
+		"POST " + pathMessages:  `{"code":0,"data":{"message_id":"om_1"}}`,
+	})
+	a := newTestAppNotifier(t, srv, map[string]string{"FEISHU_ARCHIVE_DOC": "1"})
+	if err := a.Send(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msgs := callsTo(*calls, "POST", pathMessages)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	if strings.Contains(string(msgs[0].Body), defaultDocBaseURL) {
+		t.Error("card links a document that was never created")
+	}
+	if len(callsTo(*calls, "POST", pathDocBlocks)) != 0 {
+		t.Error("blocks written after document creation failed")
+	}
+}
+
+func TestArchiveDocDisabled(t *testing.T) {
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathMessages: `{"code":0,"data":{"message_id":"om_1"}}`,
+	})
+	if err := newTestAppNotifier(t, srv, nil).Send(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0].Path != pathMessages {
+		t.Errorf("calls = %+v, want only the message", *calls)
+	}
+}
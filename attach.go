@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"unicode/utf8"
+)
+
+// ================= 完整输出作为文件附件 =================
+// 仅自建应用方式可用: 执行结果超出卡片截断长度时, 将完整内容上传为文件
+// (POST /open-apis/im/v1/files), 卡片中注明附件名, 卡片发送后再发一条文件消息。
+// 上传失败时退回只展示截断后的内容。
+
+const (
+	attachFileName = "codex-output.txt"
+	// maxAttachBytes 开放平台文件上传的大小上限
+	maxAttachBytes = 30 << 20
+)
+
+// uploadFullOutput 上传完整输出, 返回 file_key
+func (a *appNotifier) uploadFullOutput(ctx context.Context, content string) (string, error) {
+	if len(content) > maxAttachBytes {
+		return "", fmt.Errorf("full output is %d bytes, over the %d byte upload limit", len(content), maxAttachBytes)
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, kv := range [][2]string{{"file_type", "stream"}, {"file_name", attachFileName}} {
+		if err := w.WriteField(kv[0], kv[1]); err != nil {
+			return "", err
+		}
+	}
+	part, err := w.CreateFormFile("file", attachFileName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	data, err := a.callWithTokenAs(ctx, "POST", a.cfg.OpenAPIBase+"/open-apis/im/v1/files", w.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("upload full output: %w", err)
+	}
+	var file struct {
+		FileKey string `json:"file_key"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil || file.FileKey == "" {
+		return "", fmt.Errorf("upload full output: response has no file_key: %s", string(data))
+	}
+	return file.FileKey, nil
+}
+
+// sendFile 向目标群发送文件消息
+func (a *appNotifier) sendFile(ctx context.Context, fileKey string) error {
+	content, err := json.Marshal(map[string]string{"file_key": fileKey})
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(appMessage{ReceiveID: a.cfg.ChatID, MsgType: "file", Content: string(content)})
+	if err != nil {
+		return err
+	}
+	_, err = a.callWithToken(ctx, "POST", a.cfg.OpenAPIBase+"/open-apis/im/v1/messages?receive_id_type=chat_id", body)
+	return err
+}
+
+// sendAttachment 在卡片送达后发送附件; 失败只记录警告, 不影响本次发送结果
+func (a *appNotifier) sendAttachment(ctx context.Context, fileKey string) {
+	if fileKey == "" {
+		return
+	}
+	if err := a.sendFile(ctx, fileKey); err != nil {
+		logger.Warn("send full output attachment failed", "err", err)
+	}
+}
+
+// withAttachment 在执行结果会被截断时上传完整内容, 成功后返回带附件名的配置与 file_key
+// 上传失败时仅记录日志, 卡片退回只展示截断后的内容
+func (a *appNotifier) withAttachment(ctx context.Context, n CodexNotification, cfg FeishuConfig) (FeishuConfig, string) {
+	if !cfg.AttachFullOutput || cfg.DryRun {
+		return cfg, ""
+	}
+	// 上传经过内容处理 (如脱敏) 后的结果, 与卡片展示保持一致
+	full := strings.TrimSpace(transformNotification(n, cfg.ContentPipeline).LastAssistantMessage)
+	if utf8.RuneCountInString(full) <= resultLimit {
+		return cfg, ""
+	}
+	fileKey, err := a.uploadFullOutput(ctx, full)
+	if err != nil {
+		logger.Warn("attach full output failed, sending truncated result only", "err", err)
+		return cfg, ""
+	}
+	cfg.AttachmentName = attachFileName
+	return cfg, fileKey
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// ================= 卡片 schema =================
+// 飞书卡片有 1.0 与 2.0 两种结构, 元素组织差异较大。cardBuilder 封装元素构建,
+// 发送、签名、预算截断等流程只依赖 interactiveCard, 不关心具体 schema。
+
+// 卡片 schema
+const (
+	cardSchemaV1 = 1 // 默认: 顶层 elements
+	cardSchemaV2 = 2 // "schema": "2.0", 元素位于 body.elements, 支持折叠面板
+)
+
+// interactiveCard 是可直接序列化为 msg_type=interactive 内容的卡片
+type interactiveCard interface {
+	// shared 返回开启 update_multi 的副本, 共享卡片发送后才能被更新
+	shared() interactiveCard
+}
+
+// cardBuilder 根据通知构建某一 schema 的卡片
+type cardBuilder interface {
+	build(n CodexNotification, cfg FeishuConfig, failed bool) interactiveCard
+}
+
+type cardBuilderV1 struct{}
+
+func (cardBuilderV1) build(n CodexNotification, cfg FeishuConfig, failed bool) interactiveCard {
+	return buildCard(n, cfg, failed)
+}
+
+type cardBuilderV2 struct{}
+
+func (cardBuilderV2) build(n CodexNotification, cfg FeishuConfig, failed bool) interactiveCard {
+	return buildCardV2(n, cfg, failed)
+}
+
+// cardBuilderFor 返回配置的 schema 对应的构建器
+func cardBuilderFor(cfg FeishuConfig) cardBuilder {
+	if cfg.CardSchema == cardSchemaV2 {
+		return cardBuilderV2{}
+	}
+	return cardBuilderV1{}
+}
+
+func (c FeishuCard) shared() interactiveCard {
+	c.Config.UpdateMulti = true
+	return c
+}
+
+// FeishuCardV2 是 schema 2.0 的卡片结构
+type FeishuCardV2 struct {
+	Schema string             `json:"schema"`
+	Config FeishuCardV2Config `json:"config"`
+	Header FeishuHeader       `json:"header"`
+	Body   FeishuCardV2Body   `json:"body"`
+}
+
+type FeishuCardV2Config struct {
+	WidthMode   string `json:"width_mode,omitempty"`
+	UpdateMulti bool   `json:"update_multi,omitempty"`
+}
+
+type FeishuCardV2Body struct {
+	Elements []interface{} `json:"elements"`
+}
+
+// FeishuMarkdownV2 是 2.0 的 markdown 元素, TextSize 为 "notation" 时显示为辅助小字
+type FeishuMarkdownV2 struct {
+	Tag      string `json:"tag"`
+	Content  string `json:"content"`
+	TextSize string `json:"text_size,omitempty"`
+}
+
+// FeishuCollapsiblePanel 是 2.0 的折叠面板
+type FeishuCollapsiblePanel struct {
+	Tag      string            `json:"tag"`
+	Expanded bool              `json:"expanded"`
+	Header   FeishuPanelHeader `json:"header"`
+	Elements []interface{}     `json:"elements"`
+}
+
+type FeishuPanelHeader struct {
+	Title FeishuText `json:"title"`
+}
+
+func (c FeishuCardV2) shared() interactiveCard {
+	c.Config.UpdateMulti = true
+	return c
+}
+
+// panelExpandLimit 结果不超过该字符数时折叠面板默认展开
+const panelExpandLimit = 200
+
+// buildCardV2 构建 schema 2.0 卡片: 内容与 1.0 相同, 执行结果放入折叠面板
+func buildCardV2(n CodexNotification, cfg FeishuConfig, failed bool) FeishuCardV2 {
+	var elements []interface{}
+	if cfg.CardTemplate != nil {
+		elements = templateElements(n, cfg, failed)
+	}
+	if elements == nil {
+		elements = layoutElementsV2(n, cfg, failed)
+	}
+	if cfg.DebugRaw {
+		elements = append(elements, debugElementV2(n, cfg))
+	}
+	return FeishuCardV2{
+		Schema: "2.0",
+		Config: FeishuCardV2Config{WidthMode: "fill"},
+		Header: FeishuHeader{
+			Template: cfg.styleFor(n, failed).Template,
+			Title:    FeishuText{Tag: "plain_text", Content: cardTitle(n, cfg, failed)},
+		},
+		Body: FeishuCardV2Body{Elements: elements},
+	}
+}
+
+// layoutElementsV2 按 FEISHU_LAYOUT 组装 2.0 卡片元素
+func layoutElementsV2(n CodexNotification, cfg FeishuConfig, failed bool) []interface{} {
+	var elements []interface{}
+	for _, line := range alertLines(n, cfg) {
+		elements = append(elements, markdownV2(line))
+	}
+	compact := cfg.Layout == layoutCompact
+	if compact {
+		elements = append(elements, markdownV2(fmt.Sprintf("**%s:** %s", cfg.t(msgLabelInput), inputText(n, cfg, " / "))))
+	} else {
+		elements = append(elements, markdownV2(fmt.Sprintf("**%s:**\n%s", cfg.t(msgLabelInput), inputText(n, cfg, "\n"))))
+		elements = append(elements, FeishuHr{Tag: "hr"})
+	}
+
+	// 2.0 的 markdown 元素原生支持代码块, 结果整体放入折叠面板; 没有正文的类型不展示面板
+	if label, result := eventBody(n, cfg, failed); label != "" {
+		panel := []interface{}{markdownV2(result)}
+		if cfg.PayloadTrimmed {
+			panel = append(panel, FeishuMarkdownV2{Tag: "markdown", Content: cfg.t(msgTrimmed), TextSize: "notation"})
+		}
+		elements = append(elements, FeishuCollapsiblePanel{
+			Tag:      "collapsible_panel",
+			Expanded: failed || len([]rune(result)) <= panelExpandLimit,
+			Header:   FeishuPanelHeader{Title: FeishuText{Tag: "markdown", Content: fmt.Sprintf("**%s**", cfg.t(label))}},
+			Elements: panel,
+		})
+		for _, link := range resultLinks(cfg) {
+			elements = append(elements, markdownV2(link))
+		}
+		if !compact {
+			elements = append(elements, FeishuHr{Tag: "hr"})
+		}
+	}
+	var fields []string
+	for _, f := range cardFields(n, cfg) {
+		fields = append(fields, fmt.Sprintf("**%s:** %s", f.Label, f.Value))
+	}
+	elements = append(elements, markdownV2(strings.Join(fields, "\n")))
+	if cfg.ShowAckButton {
+		elements = append(elements, ackElementV2(n, cfg))
+	}
+
+	if !compact {
+		var footer []string
+		for _, t := range buildFooter(n, cfg, time.Now()).Elements {
+			footer = append(footer, t.Content)
+		}
+		if len(footer) > 0 {
+			elements = append(elements, FeishuMarkdownV2{Tag: "markdown", Content: strings.Join(footer, " · "), TextSize: "notation"})
+		}
+	}
+	return elements
+}
+
+func markdownV2(content string) FeishuMarkdownV2 {
+	return FeishuMarkdownV2{Tag: "markdown", Content: content}
+}
+
+// renderCardV2HTML 将 2.0 卡片渲染为 HTML 片段, 折叠面板对应 <details>
+func renderCardV2HTML(card FeishuCardV2) string {
+	var b strings.Builder
+	color, ok := headerColors[card.Header.Template]
+	if !ok {
+		color = headerColors["default"]
+	}
+	fmt.Fprintf(&b, "<div class=\"card\">\n<div class=\"header\" style=\"background:%s\">%s</div>\n<div class=\"body\">\n",
+		color, html.EscapeString(card.Header.Title.Content))
+	writeElementsV2HTML(&b, card.Body.Elements)
+	b.WriteString("</div>\n</div>\n")
+	return b.String()
+}
+
+func writeElementsV2HTML(b *strings.Builder, elements []interface{}) {
+	for _, el := range elements {
+		switch e := el.(type) {
+		case FeishuMarkdownV2:
+			class := "div"
+			if e.TextSize == "notation" {
+				class = "note"
+			}
+			fmt.Fprintf(b, "<div class=\"%s\">%s</div>\n", class, larkMarkdownHTML(e.Content))
+		case FeishuHr:
+			b.WriteString("<hr>\n")
+		case json.RawMessage:
+			fmt.Fprintf(b, "<pre>%s</pre>\n", html.EscapeString(string(e)))
+		case FeishuButtonV2:
+			fmt.Fprintf(b, "<div class=\"div\"><button>%s</button></div>\n", html.EscapeString(e.Text.Content))
+		case FeishuCollapsiblePanel:
+			open := ""
+			if e.Expanded {
+				open = " open"
+			}
+			fmt.Fprintf(b, "<details%s><summary>%s</summary>\n", open, larkMarkdownHTML(e.Header.Title.Content))
+			writeElementsV2HTML(b, e.Elements)
+			b.WriteString("</details>\n")
+		default:
+			fmt.Fprintf(b, "<div class=\"note\">[unsupported element %T]</div>\n", el)
+		}
+	}
+}
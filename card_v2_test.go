@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cardJSON 将 renderContent 的卡片序列化后解析为通用结构, 便于按 JSON 字段检查
+func cardJSON(t *testing.T, n CodexNotification, cfg FeishuConfig) map[string]interface{} {
+	t.Helper()
+	msgType, content := renderContent(n, cfg)
+	if msgType != "interactive" {
+		t.Fatalf("msg type = %q, want interactive", msgType)
+	}
+	b, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var card map[string]interface{}
+	if err := json.Unmarshal(b, &card); err != nil {
+		t.Fatal(err)
+	}
+	return card
+}
+
+// elementTags 返回元素列表中各元素的 tag
+func elementTags(elements []interface{}) []string {
+	var tags []string
+	for _, e := range elements {
+		tags = append(tags, e.(map[string]interface{})["tag"].(string))
+	}
+	return tags
+}
+
+func TestCardV2Structure(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_CARD_SCHEMA": "2"})
+	card := cardJSON(t, testNotification(), cfg)
+
+	if card["schema"] != "2.0" {
+		t.Errorf("schema = %v, want 2.0", card["schema"])
+	}
+	if _, ok := card["elements"]; ok {
+		t.Error("2.0 card has top-level elements")
+	}
+	if mode := card["config"].(map[string]interface{})["width_mode"]; mode != "fill" {
+		t.Errorf("config.width_mode = %v, want fill", mode)
+	}
+	header := card["header"].(map[string]interface{})
+	title := header["title"].(map[string]interface{})
+	if header["template"] != "indigo" || title["tag"] != "plain_text" || !strings.Contains(title["content"].(string), "修复登录页面的样式问题") {
+		t.Errorf("header = %v", header)
+	}
+
+	elements := card["body"].(map[string]interface{})["elements"].([]interface{})
+	want := []string{"markdown", "hr", "collapsible_panel", "hr", "markdown", "markdown"}
+	if got := elementTags(elements); !reflect.DeepEqual(got, want) {
+		t.Fatalf("body element tags = %q, want %q", got, want)
+	}
+	if input := elements[0].(map[string]interface{})["content"].(string); !strings.Contains(input, "修复登录页面的样式问题") {
+		t.Errorf("input element = %q", input)
+	}
+	panel := elements[2].(map[string]interface{})
+	if panel["expanded"] != true {
+		t.Error("short result panel starts collapsed")
+	}
+	panelElements := panel["elements"].([]interface{})
+	if got := panelElements[0].(map[string]interface{})["content"]; got != "已修复, 所有测试通过。" {
+		t.Errorf("panel content = %v", got)
+	}
+	if fields := elements[4].(map[string]interface{})["content"].(string); !strings.Contains(fields, "`/work/demo`") || !strings.Contains(fields, "thread-1") {
+		t.Errorf("fields element = %q", fields)
+	}
+	if footer := elements[5].(map[string]interface{}); footer["text_size"] != "notation" || !strings.HasPrefix(footer["content"].(string), "Generated by Codex") {
+		t.Errorf("footer element = %v", footer)
+	}
+}
+
+// 长结果默认折叠, 失败时始终展开
+func TestCardV2PanelExpanded(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_CARD_SCHEMA": "2"})
+	n := testNotification()
+	n.LastAssistantMessage = strings.Repeat("结果", panelExpandLimit)
+
+	panel := func(card map[string]interface{}) map[string]interface{} {
+		for _, e := range card["body"].(map[string]interface{})["elements"].([]interface{}) {
+			if el := e.(map[string]interface{}); el["tag"] == "collapsible_panel" {
+				return el
+			}
+		}
+		t.Fatal("no collapsible panel")
+		return nil
+	}
+	if p := panel(cardJSON(t, n, cfg)); p["expanded"] != false {
+		t.Error("long result panel starts expanded")
+	}
+	n.Status = "error"
+	card := cardJSON(t, n, cfg)
+	if p := panel(card); p["expanded"] != true {
+		t.Error("failed result panel starts collapsed")
+	}
+	if tmpl := card["header"].(map[string]interface{})["template"]; tmpl != "red" {
+		t.Errorf("failed header template = %v, want red", tmpl)
+	}
+}
+
+// 未设置或设为 1 时使用 1.0 结构, 未知取值为配置错误
+func TestCardSchemaV1Fallback(t *testing.T) {
+	for _, schema := range []string{"", "1", "1.0"} {
+		cfg := testConfig(t, map[string]string{"FEISHU_CARD_SCHEMA": schema})
+		if _, content := renderContent(testNotification(), cfg); reflect.TypeOf(content) != reflect.TypeOf(FeishuCard{}) {
+			t.Errorf("FEISHU_CARD_SCHEMA=%q built %T, want FeishuCard", schema, content)
+		}
+		card := cardJSON(t, testNotification(), cfg)
+		if _, ok := card["schema"]; ok {
+			t.Errorf("FEISHU_CARD_SCHEMA=%q card has a schema field", schema)
+		}
+		if _, ok := card["body"]; ok {
+			t.Errorf("FEISHU_CARD_SCHEMA=%q card has a body", schema)
+		}
+		if elements, ok := card["elements"].([]interface{}); !ok || len(elements) == 0 {
+			t.Errorf("FEISHU_CARD_SCHEMA=%q card has no top-level elements", schema)
+		}
+	}
+
+	if _, ok := cardBuilderFor(testConfig(t, map[string]string{"FEISHU_CARD_SCHEMA": "2.0"})).(cardBuilderV2); !ok {
+		t.Error("FEISHU_CARD_SCHEMA=2.0 does not select the 2.0 builder")
+	}
+	isolateEnv(t, map[string]string{"FEISHU_CARD_SCHEMA": "3"})
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "FEISHU_CARD_SCHEMA") {
+		t.Errorf("loadConfig error = %v, want a FEISHU_CARD_SCHEMA error", err)
+	}
+}
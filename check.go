@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ================= check 子命令 =================
+// codex-notify check 在接入 Codex 之前验证配置: 配置能否加载、sink 是否可用、签名能否生成;
+// 加 -probe 时再向各 sink 发一次不产生消息的探测请求, 确认网络可达且凭证有效。
+// 不发送任何卡片; 以清单形式输出到 stdout, 任一检查失败时退出码为 1。
+
+// prober 由支持连通性探测的 sink 实现, 探测不应产生可见消息
+type prober interface {
+	Probe(ctx context.Context) error
+}
+
+// checkList 收集检查结果并输出清单
+type checkList struct {
+	w      io.Writer
+	failed bool
+}
+
+func (c *checkList) pass(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, "[PASS] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (c *checkList) skip(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, "[SKIP] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (c *checkList) fail(format string, args ...interface{}) {
+	c.failed = true
+	fmt.Fprintf(c.w, "[FAIL] %s\n", fmt.Sprintf(format, args...))
+}
+
+// runCheckCommand 执行配置与连通性检查, 返回进程退出码
+func runCheckCommand(probe bool) int {
+	c := &checkList{w: os.Stdout}
+	cfg, err := loadConfig()
+	if err != nil {
+		c.fail("config: %v", err)
+		return 1
+	}
+	attachRequestID(cfg)
+	if p := configFilePath(); p != "" && fileExists(p) {
+		c.pass("config loaded (file %s)", p)
+	} else {
+		c.pass("config loaded (environment only)")
+	}
+
+	// 逐个构造 sink, 以便报告每个 sink 的具体配置错误
+	var notifiers []Notifier
+	for _, name := range notifierOrder {
+		ns, err := notifierFactories[name](cfg)
+		if err != nil {
+			c.fail("sink %s: %v", name, err)
+			continue
+		}
+		for _, n := range ns {
+			target := ""
+			if t, ok := n.(targeter); ok {
+				target = " → " + t.Target()
+			}
+			c.pass("sink %s%s", n.Name(), target)
+			notifiers = append(notifiers, n)
+		}
+	}
+	if len(notifiers) == 0 && !c.failed {
+		c.fail("sinks: none configured (set FEISHU_WEBHOOK_URL, or pick another FEISHU_BACKEND and set its options)")
+	}
+
+	if cfg.Secret != "" {
+		if _, _, err := signRequest(cfg.Secret, time.Now); err != nil {
+			c.fail("signature: %v", err)
+		} else {
+			c.pass("signature generated from FEISHU_SECRET")
+		}
+	} else {
+		c.skip("signature (FEISHU_SECRET not set)")
+	}
+
+	if !probe {
+		c.skip("connectivity (run with -probe to contact the sinks)")
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		for _, n := range notifiers {
+			p, ok := n.(prober)
+			if !ok {
+				c.skip("probe %s (not supported)", n.Name())
+				continue
+			}
+			if err := p.Probe(ctx); err != nil {
+				c.fail("probe %s: %v", n.Name(), err)
+			} else {
+				c.pass("probe %s: reachable", n.Name())
+			}
+		}
+	}
+
+	if c.failed {
+		return 1
+	}
+	return 0
+}
+
+// fileExists 判断路径是否存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Probe 向 Webhook 发送只含签名字段、没有 msg_type 的请求: 飞书会先校验 token 与签名,
+// 再因缺少消息内容而拒绝, 不会在群里产生消息。token 或签名无效时返回错误
+func (f *feishuNotifier) Probe(ctx context.Context) error {
+	ts, sign, err := signRequest(f.cfg.Secret, f.now)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"timestamp": ts, "sign": sign})
+	if err != nil {
+		return err
+	}
+	cfg := f.cfg
+	cfg.Gzip = false
+	req, err := newWebhookRequest(ctx, cfg, body)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	var feishuResp FeishuResponse
+	if err := json.Unmarshal(respBody, &feishuResp); err != nil {
+		return fmt.Errorf("unexpected response: %w (payload: %s)", err, string(respBody))
+	}
+	if apiErr := feishuResp.apiError(); apiErr != nil && (errors.Is(apiErr, ErrWebhookInvalid) || errors.Is(apiErr, ErrSignatureRejected)) {
+		return apiErr
+	}
+	// 其它错误码 (如缺少 msg_type) 说明 token 与签名已通过校验
+	return nil
+}
+
+// Probe 强制刷新 tenant_access_token, 验证 App ID / App Secret 与开放平台的连通性
+func (a *appNotifier) Probe(ctx context.Context) error {
+	_, err := a.tenantToken(ctx, true)
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ================= 命令行参数 =================
+// 命令行参数覆盖对应的环境变量, 优先级: 命令行参数 > 环境变量 > 配置文件 > 内置默认值。
+// 通知 JSON 仍为最后一个位置参数; 参数为 "-", 或没有参数且标准输入不是终端时, 从标准输入读取。
+
+const usageText = `Usage: codex-notify [flags] <NOTIFICATION_JSON>
+       codex-notify [flags] [-] < notification.json
+       codex-notify [flags] -html-preview <path> <NOTIFICATION_JSON>
+       codex-notify [flags] test
+       codex-notify [flags] [-probe] check
+       codex-notify [flags] replay | flush
+       codex-notify [flags] serve
+       codex-notify [flags] digest
+       codex-notify version
+
+Flags:
+`
+
+// flagOverrides 由命令行参数设置的配置项, 键为对应的环境变量名
+var flagOverrides = map[string]string{}
+
+// cliOptions 命令行解析结果
+type cliOptions struct {
+	htmlPreview string   // -html-preview 输出路径
+	showVersion bool     // -version / -v
+	probe       bool     // -probe, 配合 check 子命令探测连通性
+	args        []string // 位置参数
+	usage       func()   // 输出用法说明 (到 stderr)
+}
+
+// parseFlags 解析命令行参数, 并将显式设置的配置类参数写入 flagOverrides
+func parseFlags(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet(binaryName, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageText)
+		fs.PrintDefaults()
+	}
+	opts.usage = fs.Usage
+
+	// 配置类参数: 参数名 -> 环境变量名
+	envFlags := map[string]string{
+		"webhook": "FEISHU_WEBHOOK_URL",
+		"secret":  "FEISHU_SECRET",
+		"timeout": "FEISHU_TIMEOUT",
+		"dry-run": "FEISHU_DRY_RUN",
+	}
+	fs.String("webhook", "", "Feishu webhook URL (overrides FEISHU_WEBHOOK_URL)")
+	fs.String("secret", "", "webhook signing secret (overrides FEISHU_SECRET)")
+	fs.String("timeout", "", "request timeout, e.g. 10s (overrides FEISHU_TIMEOUT)")
+	fs.Bool("dry-run", false, "print the message JSON instead of sending it (overrides FEISHU_DRY_RUN)")
+	fs.StringVar(&opts.htmlPreview, "html-preview", "", "render the card to an HTML file at `path` instead of sending it")
+	fs.BoolVar(&opts.probe, "probe", false, "with check, also contact each sink to confirm it is reachable (sends no message)")
+	fs.BoolVar(&opts.showVersion, "version", false, "print version information and exit")
+	fs.BoolVar(&opts.showVersion, "v", false, "shorthand for -version")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		env, ok := envFlags[f.Name]
+		if !ok {
+			return
+		}
+		value := f.Value.String()
+		if b, isBool := f.Value.(interface{ IsBoolFlag() bool }); isBool && b.IsBoolFlag() {
+			// 布尔参数统一写成 1/0, 与环境变量的解析方式一致
+			v, _ := strconv.ParseBool(value)
+			value = "0"
+			if v {
+				value = "1"
+			}
+		}
+		flagOverrides[env] = value
+	})
+	opts.args = fs.Args()
+	return opts, nil
+}
+
+// stdinArg 表示从标准输入读取通知 JSON 的位置参数
+const stdinArg = "-"
+
+// maxStdinBytes 从标准输入读取的通知 JSON 上限, 防止误接到无尽的输入流
+const maxStdinBytes = 64 << 20
+
+// withStdinArg 没有位置参数且标准输入为管道或文件时, 视为指定了 "-"
+func withStdinArg(args []string, stdin *os.File) []string {
+	if len(args) == 0 && !isTerminal(stdin) {
+		return []string{stdinArg}
+	}
+	return args
+}
+
+// notificationInput 返回通知 JSON: 参数为 "-" 时读取标准输入, 否则即为参数本身
+// 超长的执行结果会超出命令行参数长度限制, 通过标准输入传递可避免这一问题与 shell 转义
+func notificationInput(arg string, stdin io.Reader) (string, error) {
+	if arg != stdinArg {
+		return arg, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(stdin, maxStdinBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("read notification from stdin: %w", err)
+	}
+	if len(data) > maxStdinBytes {
+		return "", fmt.Errorf("read notification from stdin: input exceeds %d bytes", maxStdinBytes)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", fmt.Errorf("read notification from stdin: input is empty")
+	}
+	return string(data), nil
+}
@@ -2,24 +2,43 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // ================= 配置区域 =================
 // 运行前请在环境变量中设置以下配置:
 //   FEISHU_WEBHOOK_URL - 飞书群机器人提供的完整 Webhook URL (必填)
 //   FEISHU_SECRET      - 如果开启签名校验, 填写机器人安全设置中的 Secret (选填)
+//   FEISHU_CODEX_ENV   - 设为 1 时自动展示 CODEX_* 环境变量 (选填)
+//   FEISHU_CODEX_ENV_ALLOW - 允许展示的 CODEX_* 变量名, 逗号分隔, 支持通配符 (选填)
+//   FEISHU_TIMEOUT     - 请求超时, 如 10s (选填, 默认 10s)
+//   FEISHU_DRY_RUN     - 设为 1 时只输出卡片 JSON, 不发送 (选填)
+//   FEISHU_LOG_LEVEL   - 日志级别 debug/info/warn/error (选填, 默认 warn, 仅环境变量)
+//   FEISHU_LOG_FORMAT  - 日志格式 text/json (选填, 默认 text, 仅环境变量)
+// 以上配置也可写入 $XDG_CONFIG_HOME/codex-feishu/config.json (或 FEISHU_CONFIG_FILE
+// 指定的路径), 键名为去掉 FEISHU_ 前缀后的小写形式, 如 webhook_url。环境变量优先。
 // ===========================================
 
 // CodexNotification 定义 Codex 传入的 JSON 结构
@@ -30,15 +49,22 @@ type CodexNotification struct {
 	Cwd                  string   `json:"cwd"`
 	InputMessages        []string `json:"input-messages"`
 	LastAssistantMessage string   `json:"last-assistant-message"`
+	StartedAt            string   `json:"started-at,omitempty"` // 任务开始时间 (RFC3339, 选填)
+	Status               string   `json:"status,omitempty"`     // 任务状态: success / error / cancelled (选填, 优先于关键词判断)
+
+	Message string      `json:"message,omitempty"` // error / turn-aborted 的错误信息, approval-requested 的说明
+	Command commandLine `json:"command,omitempty"` // approval-requested 待审批的命令
+
+	Raw json.RawMessage `json:"-"` // Codex 传入的原始 JSON, 供 FEISHU_DEBUG_RAW 展示
 }
 
 // ================= 飞书卡片消息结构定义 =================
 
 type FeishuCardMsg struct {
-	Timestamp string     `json:"timestamp,omitempty"` // 认证字段: 秒级时间戳
-	Sign      string     `json:"sign,omitempty"`      // 认证字段: 签名
-	MsgType   string     `json:"msg_type"`
-	Card      FeishuCard `json:"card"`
+	Timestamp string          `json:"timestamp,omitempty"` // 认证字段: 秒级时间戳
+	Sign      string          `json:"sign,omitempty"`      // 认证字段: 签名
+	MsgType   string          `json:"msg_type"`
+	Card      interactiveCard `json:"card"`
 }
 
 type FeishuCard struct {
@@ -49,6 +75,7 @@ type FeishuCard struct {
 
 type FeishuCardConfig struct {
 	WideScreenMode bool `json:"wide_screen_mode"`
+	UpdateMulti    bool `json:"update_multi,omitempty"` // 共享卡片, 发送后可被更新
 }
 
 type FeishuHeader struct {
@@ -77,230 +104,1037 @@ type FeishuNote struct {
 	Elements []FeishuText `json:"elements"`
 }
 
+type FeishuMarkdown struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
 type FeishuHr struct {
 	Tag string `json:"tag"`
 }
 
-// ======================================================
+// 纯文本消息 (msg_type: text)
+
+type FeishuTextMsg struct {
+	Timestamp string            `json:"timestamp,omitempty"`
+	Sign      string            `json:"sign,omitempty"`
+	MsgType   string            `json:"msg_type"`
+	Content   FeishuTextContent `json:"content"`
+}
 
-type FeishuConfig struct {
-	WebhookURL string
-	Secret     string
+type FeishuTextContent struct {
+	Text string `json:"text"`
 }
 
+// 卡片布局
+const (
+	layoutRich    = "rich"    // 默认: 分隔线分区, 带底部备注
+	layoutCompact = "compact" // 紧凑: 无分隔线与底部备注
+)
+
+// 消息格式
+const (
+	msgFormatCard = "card" // 交互式卡片 (默认)
+	msgFormatText = "text" // 纯文本
+)
+
+// ======================================================
+
+// FeishuResponse 飞书接口返回, 兼容 code/msg、StatusCode/StatusMessage
+// 以及 v2 形态 (code 为字符串, 或错误嵌套在 data 中)
 type FeishuResponse struct {
-	Code          int    `json:"code"`
-	Msg           string `json:"msg"`
-	StatusCode    int    `json:"StatusCode"`
-	StatusMessage string `json:"StatusMessage"`
+	Code          feishuCode      `json:"code"`
+	Msg           string          `json:"msg"`
+	StatusCode    feishuCode      `json:"StatusCode"`
+	StatusMessage string          `json:"StatusMessage"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// feishuCode 同时接受数字与字符串形式的错误码
+type feishuCode struct {
+	Num  int
+	Text string // 非数字的字符串错误码, 如 "invalid_param"
+}
+
+func (c *feishuCode) UnmarshalJSON(b []byte) error {
+	*c = feishuCode{}
+	if string(b) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(b, &c.Num); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("code is neither number nor string: %s", string(b))
+	}
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		c.Num = n
+	} else {
+		c.Text = s
+	}
+	return nil
+}
+
+// failed 判断错误码是否表示失败
+func (c feishuCode) failed() bool {
+	return c.Num != 0 || c.Text != ""
+}
+
+// apiError 将响应中的任何非零错误码转换为 FeishuAPIError, 成功时返回 nil
+func (r FeishuResponse) apiError() *FeishuAPIError {
+	code, msg := r.Code, r.Msg
+	if !code.failed() && !r.StatusCode.failed() {
+		// v2 形态: {"code":0,"data":{"code":19021,"msg":"..."}}
+		var nested struct {
+			Code    feishuCode `json:"code"`
+			Msg     string     `json:"msg"`
+			Message string     `json:"message"`
+		}
+		if len(r.Data) == 0 || json.Unmarshal(r.Data, &nested) != nil || !nested.Code.failed() {
+			return nil
+		}
+		code, msg = nested.Code, nested.Msg
+		if msg == "" {
+			msg = nested.Message
+		}
+	}
+	return &FeishuAPIError{
+		Code:          code.Num,
+		CodeText:      code.Text,
+		Msg:           msg,
+		StatusCode:    r.StatusCode.Num,
+		StatusMessage: r.StatusMessage,
+	}
+}
+
+// FeishuAPIError 表示飞书接口返回了非零业务错误码
+type FeishuAPIError struct {
+	Code          int
+	CodeText      string // 字符串形式的错误码 (无法解析为数字时)
+	Msg           string
+	StatusCode    int
+	StatusMessage string
+	RetryAfter    time.Duration // 频率限制时服务端要求的等待时间, 0 表示未指定
+}
+
+func (e *FeishuAPIError) Error() string {
+	code := strconv.Itoa(e.Code)
+	if e.CodeText != "" {
+		code = strconv.Quote(e.CodeText)
+	}
+	detail := fmt.Sprintf("feishu error code=%s statusCode=%d msg=%s statusMessage=%s", code, e.StatusCode, e.Msg, e.StatusMessage)
+	switch {
+	case e.webhookInvalid():
+		return fmt.Sprintf("%v (%s)", ErrWebhookInvalid, detail)
+	case e.signatureRejected():
+		return fmt.Sprintf("%v (%s)", ErrSignatureRejected, detail)
+	}
+	return detail
+}
+
+func (e *FeishuAPIError) Unwrap() error {
+	switch {
+	case e.webhookInvalid():
+		return ErrWebhookInvalid
+	case e.signatureRejected():
+		return ErrSignatureRejected
+	}
+	return nil
+}
+
+// ErrWebhookInvalid 表示 Webhook 已失效或机器人被停用
+var ErrWebhookInvalid = errors.New("webhook appears disabled or invalid — check the robot settings")
+
+// webhookInvalidCodes 飞书在 Webhook token 无效或机器人被停用时返回的错误码
+var webhookInvalidCodes = map[int]bool{
+	19001: true, // param invalid: incoming webhook access token invalid
+	19002: true, // incoming webhook not found
+	19007: true, // bot not enabled
+}
+
+func (e *FeishuAPIError) webhookInvalid() bool {
+	return webhookInvalidCodes[e.Code] || webhookInvalidCodes[e.StatusCode]
+}
+
+// ErrSignatureRejected 表示飞书拒绝了签名, 常见原因是密钥错误或本机时钟偏差超过一小时
+var ErrSignatureRejected = errors.New("signature rejected — check FEISHU_SECRET, and whether the system clock is skewed (Feishu allows at most 1h drift)")
+
+// codeSignMismatch 签名校验失败或时间戳与服务器时间相差超过一小时
+const codeSignMismatch = 19021
+
+func (e *FeishuAPIError) signatureRejected() bool {
+	return e.Code == codeSignMismatch || e.StatusCode == codeSignMismatch
+}
+
+// clockSkewWarn 本机时钟与飞书服务器时间的偏差超过该值时告警
+// 飞书允许的最大偏差为一小时, 提前告警以便在签名失效前修正
+const clockSkewWarn = 5 * time.Minute
+
+// clockSkew 根据响应的 Date 头估算本机时钟偏差 (本机时间减服务器时间)
+// Date 头缺失或无法解析时 ok 为 false
+func clockSkew(date string, now time.Time) (skew time.Duration, ok bool) {
+	if date == "" {
+		return 0, false
+	}
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(server), true
 }
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: codex-notify <NOTIFICATION_JSON>")
+	// 先加载 .env, 使其中的 FEISHU_LOG_LEVEL 等变量也能生效
+	dotEnvErr := loadDotEnv()
+	setupLogger()
+	if dotEnvErr != nil {
+		logger.Warn("ignoring .env file", "err", dotEnvErr)
+	}
+
+	opts, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	if opts.showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	opts.args = withStdinArg(opts.args, os.Stdin)
+	if len(opts.args) != 1 {
+		opts.usage()
 		os.Exit(1)
 	}
+	if opts.htmlPreview != "" {
+		input, err := notificationInput(opts.args[0], os.Stdin)
+		if err != nil {
+			logger.Error("error reading notification", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(runHTMLPreview(opts.htmlPreview, input))
+	}
 
-	jsonStr := os.Args[1]
+	switch opts.args[0] {
+	case "test":
+		os.Exit(runTestCommand())
+	case "check":
+		os.Exit(runCheckCommand(opts.probe))
+	case "replay", "flush":
+		os.Exit(runReplayCommand())
+	case "serve":
+		os.Exit(runServeCommand())
+	case "digest":
+		os.Exit(runDigestCommand())
+	case "version":
+		fmt.Println(versionString())
+		return
+	}
+
+	jsonStr, err := notificationInput(opts.args[0], os.Stdin)
+	if err != nil {
+		logger.Error("error reading notification", "err", err)
+		os.Exit(1)
+	}
 
 	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Printf("Config error: %v\n", err)
+		logger.Error("config error", "err", err)
 		os.Exit(1)
 	}
+	attachRequestID(cfg)
 
-	var notification CodexNotification
-	err = json.Unmarshal([]byte(jsonStr), &notification)
+	notifications, batch, err := parseNotifications(jsonStr)
 	if err != nil {
-		fmt.Printf("Error parsing JSON: %v\n", err)
+		logger.Error("error parsing notification JSON", "err", err)
 		os.Exit(1)
 	}
 
-	if notification.Type == "agent-turn-complete" {
-		if err := sendFeishuCard(notification, cfg); err != nil {
-			fmt.Printf("Failed to send notification: %v\n", err)
-			os.Exit(1)
+	ctx, signals := watchSignals(context.Background(), cfg.SignalGrace)
+	report := processBatch(ctx, cfg, notifications)
+	signals.stop()
+	report.OK = report.Failed == 0 && signals.interrupted() == nil
+	status := "ok"
+	switch {
+	case signals.interrupted() != nil:
+		status = "interrupted"
+	case report.Failed > 0:
+		status = "failed"
+	}
+	emitMetrics(cfg, report, status)
+	if cfg.Output == outputJSON {
+		if err := report.write(os.Stdout); err != nil {
+			logger.Error("write result", "err", err)
+		}
+	}
+	if batch {
+		if report.Queued > 0 {
+			cfg.printf(os.Stderr, "%d/%d sent (%d queued for digest, %d skipped, %d failed)\n", report.Sent, report.total(), report.Queued, report.Skipped, report.Failed)
+		} else {
+			cfg.printf(os.Stderr, "%d/%d sent (%d skipped, %d failed)\n", report.Sent, report.total(), report.Skipped, report.Failed)
 		}
 	}
+	if sig := signals.interrupted(); sig != nil {
+		logger.Error("interrupted by signal", "signal", sig.String(), "sent", report.Sent, "failed", report.Failed)
+		os.Exit(signalExitCode(sig))
+	}
+	if failed := report.Failed; failed > 0 {
+		if cfg.FailSilent {
+			logger.Warn("send failed, exiting 0 because FEISHU_FAIL_SILENT is set", "failed", failed)
+			return
+		}
+		os.Exit(1)
+	}
 }
 
-func loadConfig() (FeishuConfig, error) {
-	webhook := strings.TrimSpace(os.Getenv("FEISHU_WEBHOOK_URL"))
-	if webhook == "" {
-		return FeishuConfig{}, errors.New("FEISHU_WEBHOOK_URL is not set")
+// processBatch 依次处理一次运行中的全部通知并汇总结果; 单条通知的配置错误记为失败, 不影响其余通知。
+// 被信号中断后剩余通知写入死信目录
+func processBatch(ctx context.Context, cfg FeishuConfig, notifications []CodexNotification) runReport {
+	var report runReport
+	flushed := false
+	for i, notification := range notifications {
+		if ctx.Err() != nil {
+			// 已被信号中断: 不再发送剩余通知, 计为失败并保存到死信目录, 避免批量中的通知被静默丢弃
+			for _, rest := range notifications[i:] {
+				if !cfg.typeEnabled(rest.Type) {
+					continue
+				}
+				saveDeadLetter(cfg, rest, ctx.Err())
+				report.add(notificationReport{TurnID: rest.TurnID, Outcome: outcomeFailed})
+			}
+			break
+		}
+		rep, err := processNotification(ctx, cfg, notification)
+		if err != nil {
+			// 配置错误 (如严格模式下有 sink 配置有误) 只影响这一条通知: 记为失败, 继续处理批量中的其余通知
+			level := slog.LevelError
+			if cfg.FailSilent {
+				level = slog.LevelWarn
+			}
+			logger.Log(ctx, level, "config error", "turnID", notification.TurnID, "err", err)
+			rep.Outcome, rep.Error = outcomeFailed, err.Error()
+			if report.Error == "" {
+				report.Error = err.Error()
+			}
+		}
+		if rep.Outcome != outcomeIgnored {
+			report.add(rep)
+		}
+		if rep.Outcome == outcomeSent && !flushed {
+			// 发送成功说明网络已恢复, 顺带补发离线期间积压的通知
+			flushDeadLetters(ctx, cfg)
+			flushed = true
+		}
 	}
-	secret := strings.TrimSpace(os.Getenv("FEISHU_SECRET"))
-	return FeishuConfig{
-		WebhookURL: webhook,
-		Secret:     secret,
-	}, nil
+	return report
+}
+
+// parseNotifications 解析单个通知对象或通知数组 (批量包装器一次提交多个 turn)
+// 通过第一个非空白字符区分两种形式, batch 表示输入为数组
+func parseNotifications(jsonStr string) (notifications []CodexNotification, batch bool, err error) {
+	trimmed := strings.TrimLeft(jsonStr, " \t\r\n")
+	if strings.HasPrefix(trimmed, "[") {
+		var raws []json.RawMessage
+		if err := json.Unmarshal([]byte(trimmed), &raws); err != nil {
+			return nil, true, err
+		}
+		for _, raw := range raws {
+			var notification CodexNotification
+			if err := json.Unmarshal(raw, &notification); err != nil {
+				return nil, true, err
+			}
+			notification.Raw = raw
+			notifications = append(notifications, notification)
+		}
+		return notifications, true, nil
+	}
+	var notification CodexNotification
+	if err := json.Unmarshal([]byte(jsonStr), &notification); err != nil {
+		return nil, false, err
+	}
+	notification.Raw = json.RawMessage(trimmed)
+	return []CodexNotification{notification}, false, nil
+}
+
+// outcome 单条通知的处理结果
+type outcome int
+
+const (
+	outcomeIgnored outcome = iota // 未在 FEISHU_NOTIFY_TYPES 中启用的类型, 不处理
+	outcomeSkipped                // 被过滤 (工作路径不符、结果过短或重复)
+	outcomeSent
+	outcomeFailed
+	outcomeQueued // 写入摘要队列, 由 digest 子命令发送
+)
+
+// processNotification 过滤并发送单条通知; 仅配置错误作为 error 返回, 发送失败记为 outcomeFailed
+func processNotification(ctx context.Context, cfg FeishuConfig, notification CodexNotification) (notificationReport, error) {
+	rep := notificationReport{TurnID: notification.TurnID, Outcome: outcomeIgnored}
+	if !cfg.typeEnabled(notification.Type) {
+		return rep, nil
+	}
+	// 先于失败计数过滤, 被忽略的目录不参与升级告警
+	if cwdFiltered(notification, cfg) {
+		logger.Info("skipping notification from filtered cwd", "cwd", notification.Cwd)
+		rep.Outcome = outcomeSkipped
+		return rep, nil
+	}
+	// 重复触发的通知先于失败计数跳过, 同一 turn 不会被计为多次失败
+	if dup, err := seenRecently(cfg, dedupKey(notification), time.Now()); err != nil {
+		logger.Warn("dedup cache unavailable", "err", err)
+	} else if dup {
+		logger.Info("skipping duplicate notification", "turnID", notification.TurnID)
+		rep.Outcome = outcomeSkipped
+		return rep, nil
+	}
+	// 路由先于升级告警: 升级 Webhook (如有) 优先于路由结果
+	cfg = cfg.routeFor(notification.Cwd)
+	complete := notification.Type == typeTurnComplete
+	failed := failureEvent(notification.Type) || (complete && detectFailure(notification, cfg))
+	if complete && cfg.EscalateAfter > 0 {
+		count, err := recordOutcome(cfg, notification.Cwd, failed)
+		if err != nil {
+			logger.Warn("failure counter unavailable", "err", err)
+		} else if failed && count >= cfg.EscalateAfter {
+			cfg = cfg.escalate()
+		}
+	}
+	// 按内容处理后的结果计算长度, ANSI 控制码与行尾空白不应让 "Done." 越过阈值
+	if complete && !failed && resultTooShort(transformNotification(notification, cfg.ContentPipeline), cfg.MinResultLen) {
+		logger.Info("skipping notification with short result", "min", cfg.MinResultLen)
+		rep.Outcome = outcomeSkipped
+		return rep, nil
+	}
+	if complete && !failed {
+		if similar, score, err := similarToLast(cfg, notification, time.Now()); err != nil {
+			logger.Warn("similarity cache unavailable", "err", err)
+		} else if similar {
+			logger.Info("skipping notification similar to the previous one", "cwd", notification.Cwd, "similarity", score)
+			rep.Outcome = outcomeSkipped
+			return rep, nil
+		}
+	}
+	if cfg.Digest && !cfg.Escalated {
+		if err := spoolDigest(cfg, notification, failed, time.Now()); err != nil {
+			// 写入队列失败时立即发送, 不丢失通知
+			logger.Warn("digest spool unavailable, sending now", "err", err)
+		} else {
+			logger.Info("queued notification for digest", "turnID", notification.TurnID)
+			rememberSent(cfg, notification, complete)
+			rep.Outcome = outcomeQueued
+			return rep, nil
+		}
+	}
+	// 完整输出只上传一次, 所有 sink 共用同一个链接
+	cfg = withPasteLink(ctx, notification, cfg)
+	notifiers, err := configuredNotifiers(cfg)
+	if err != nil {
+		rep.Outcome = outcomeFailed
+		return rep, err
+	}
+	notifiers = routeFailure(cfg, failed, notifiers)
+	if err := sendJitter(ctx, cfg); err != nil {
+		// 等待期间被中断: 继续走发送流程, 由 dispatch 以 ctx 错误记为失败并写入死信
+		logger.Warn("send jitter interrupted", "err", err)
+	}
+	rep.Sinks, err = dispatch(ctx, notifiers, notification, cfg.Concurrency)
+	if err != nil {
+		level := slog.LevelError
+		if cfg.FailSilent {
+			level = slog.LevelWarn
+		}
+		logger.Log(ctx, level, "failed to send notification", "turnID", notification.TurnID, "err", err)
+		saveDeadLetter(cfg, notification, err)
+		rep.Outcome = outcomeFailed
+		return rep, nil
+	}
+	rememberSent(cfg, notification, complete)
+	rep.Outcome = outcomeSent
+	return rep, nil
+}
+
+// rememberSent 记录已发送 (或已写入摘要队列) 的通知, 供去重与相似内容过滤
+func rememberSent(cfg FeishuConfig, n CodexNotification, complete bool) {
+	if err := markSent(cfg, dedupKey(n), time.Now()); err != nil {
+		logger.Warn("update dedup cache", "err", err)
+	}
+	if complete {
+		if err := rememberFingerprint(cfg, n, time.Now()); err != nil {
+			logger.Warn("update similarity cache", "err", err)
+		}
+	}
+}
+
+// codexEnvVar 表示一个需要展示的 CODEX_* 环境变量
+type codexEnvVar struct {
+	Name  string
+	Value string
+}
+
+// sensitiveEnvName 匹配像是保存凭据的变量名, allowlist 用通配符 (如 CODEX_*) 放行时也不展示其值
+var sensitiveEnvName = regexp.MustCompile(`(?i)TOKEN|SECRET|KEY|PASSWORD|CREDENTIAL`)
+
+// collectCodexEnv 从环境中收集命中 allowlist 的 CODEX_* 变量, 按名称排序;
+// 凭据类变量的值替换为 [REDACTED], 其余变量的值中的常见密钥格式同样打码
+func collectCodexEnv(environ []string, allow []string) []codexEnvVar {
+	var vars []codexEnvVar
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, "CODEX_") || strings.TrimSpace(value) == "" {
+			continue
+		}
+		for _, pattern := range allow {
+			if matched, _ := path.Match(pattern, name); matched {
+				if sensitiveEnvName.MatchString(name) {
+					value = "[REDACTED]"
+				}
+				vars = append(vars, codexEnvVar{Name: name, Value: redactSecrets(value)})
+				break
+			}
+		}
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
+
+// ErrSign 表示签名计算失败
+var ErrSign = errors.New("failed to sign webhook request")
+
+// newSignHash 创建签名使用的 HMAC, 可替换以模拟写入失败
+var newSignHash = func(key []byte) hash.Hash {
+	return hmac.New(sha256.New, key)
 }
 
 // GenSign 生成飞书自定义机器人所需的签名
 // 算法: base64(hmac_sha256(key=timestamp+"\n"+secret, msg=""))
+// 注意消息体为空, 密钥才是拼接串; 修改实现后可用以下参考值核对 (与 Python hmac 计算结果一致):
+//
+//	GenSign("demo-secret", 1700000000) == "mYHw2R2SOm8Rw/sne3lQdmz4sOfntKR+1P/8RKKTwmA="
 func GenSign(secret string, timestamp int64) (string, error) {
 	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
 	var data []byte
-	h := hmac.New(sha256.New, []byte(stringToSign))
-	_, err := h.Write(data)
-	if err != nil {
-		return "", err
+	h := newSignHash([]byte(stringToSign))
+	if _, err := h.Write(data); err != nil {
+		return "", fmt.Errorf("%w: hmac write: %v", ErrSign, err)
 	}
 	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
 	return signature, nil
 }
 
-func sendFeishuCard(n CodexNotification, cfg FeishuConfig) error {
-	// 1. 准备基础数据
-	userIntent := "Unknown Task"
-	if len(n.InputMessages) > 0 {
-		userIntent = n.InputMessages[0]
+// signRequest 以 now 给出的时间计算签名字段; 未配置 secret 时返回空值
+// 时钟通过参数注入, 固定 now 即可得到确定的 timestamp 与 sign
+func signRequest(secret string, now func() time.Time) (timestamp, sign string, err error) {
+	if secret == "" {
+		return "", "", nil
 	}
+	ts := now().Unix()
+	if sign, err = GenSign(secret, ts); err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(ts, 10), sign, nil
+}
 
-	displayTitle := truncateRunes(userIntent, 30)
+func sendFeishuCard(ctx context.Context, client doer, now func() time.Time, n CodexNotification, cfg FeishuConfig) error {
+	// 1. 计算签名 (如果配置了 Secret)
+	timestampStr, sign, err := signRequest(cfg.Secret, now)
+	if err != nil {
+		// 不发送未签名的请求: 开启签名校验的机器人会直接拒绝
+		return fmt.Errorf("%w (FEISHU_SECRET is set, so the card was not sent unsigned; check the secret or unset it if the robot has signature verification disabled)", err)
+	}
 
-	// 2. 计算签名 (如果配置了 Secret)
-	var timestampStr, sign string
-	if cfg.Secret != "" {
-		ts := time.Now().Unix()
-		timestampStr = strconv.FormatInt(ts, 10)
-		var err error
-		sign, err = GenSign(cfg.Secret, ts)
-		if err != nil {
-			return fmt.Errorf("sign generation failed: %v", err)
+	// 2. 按消息格式组装消息体, 超出大小上限时收紧内容
+	msg, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		switch msgType, content := renderContent(n, cfg); c := content.(type) {
+		case FeishuTextContent:
+			return FeishuTextMsg{
+				Timestamp: timestampStr,
+				Sign:      sign,
+				MsgType:   msgType,
+				Content:   c,
+			}
+		case interactiveCard:
+			return FeishuCardMsg{
+				Timestamp: timestampStr, // 只有当配置了 secret 时，这才有意义，但传了也无妨
+				Sign:      sign,         // 签名
+				MsgType:   msgType,
+				Card:      c,
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// 3. 构建卡片元素
-	var elements []interface{}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, cfg.DryRunIndent, useColor(os.Stdout))
+	}
 
-	// 元素: 输入指令
-	inputContent := strings.Join(n.InputMessages, "\n")
-	elements = append(elements, FeishuDiv{
-		Tag: "div",
-		Text: &FeishuText{
-			Tag:     "lark_md",
-			Content: fmt.Sprintf("**📝 输入指令:**\n%s", inputContent),
-		},
-	})
+	payloadBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
 
-	elements = append(elements, FeishuHr{Tag: "hr"})
+	// 3. 发送请求 (失败时按配置重试), 重试使用同一个幂等键
+	cfg.IdempotencyKey = idempotencyKey(n)
+	return postWithRetry(ctx, client, cfg, payloadBytes)
+}
+
+// renderContent 按消息格式渲染消息内容, 返回飞书 msg_type 与对应内容 (interactiveCard 或 FeishuTextContent)
+// Webhook 与应用机器人两种发送方式共用这一渲染逻辑
+func renderContent(n CodexNotification, cfg FeishuConfig) (string, interface{}) {
+	n, cfg, failed := prepareNotification(n, cfg)
+	if cfg.MsgFormat == msgFormatText {
+		return "text", FeishuTextContent{Text: buildTextContent(n, cfg, failed)}
+	}
+	return "interactive", cardBuilderFor(cfg).build(n, cfg, failed)
+}
+
+// prepareNotification 渲染前的内容处理: 内容管道、输入去重、失败检测与预算截断, 各 sink 共用
+func prepareNotification(n CodexNotification, cfg FeishuConfig) (CodexNotification, FeishuConfig, bool) {
+	n = transformNotification(n, cfg.ContentPipeline)
+	if cfg.DedupInputs {
+		n.InputMessages = dedupConsecutive(n.InputMessages)
+	}
+	failed := failureEvent(n.Type) || detectFailure(n, cfg)
+	// 预算截断放在失败检测之后, 避免截掉关键词后改变卡片状态
+	n, cfg.PayloadTrimmed = applyBudgets(n, cfg)
+	return n, cfg, failed
+}
 
-	// 元素: 执行结果
+// taskName 返回标题中的任务摘要: 第一条非空输入指令的第一行; 没有输入时退回到工作路径的目录名
+// 多行指令只取首行, 避免截断后的标题从句子中间开始; 完整指令仍展示在正文中
+func taskName(n CodexNotification, cfg FeishuConfig) string {
+	for _, m := range n.InputMessages {
+		if m = strings.TrimSpace(m); m != "" {
+			line, _, _ := strings.Cut(m, "\n")
+			return strings.TrimSpace(line)
+		}
+	}
+	if base := filepath.Base(strings.TrimSpace(n.Cwd)); n.Cwd != "" && base != "/" && base != "." {
+		return base
+	}
+	return cfg.t(msgUnknownTask)
+}
+
+// inputText 用 sep 拼接输入指令, 全部为空时返回占位文案
+func inputText(n CodexNotification, cfg FeishuConfig, sep string) string {
+	for _, m := range n.InputMessages {
+		if strings.TrimSpace(m) != "" {
+			return strings.Join(n.InputMessages, sep)
+		}
+	}
+	return cfg.t(msgEmptyInput)
+}
+
+// cardTitle 生成标题, 取第一条输入指令作为任务摘要
+func cardTitle(n CodexNotification, cfg FeishuConfig, failed bool) string {
+	userIntent := taskName(n, cfg)
+	displayTitle := truncateText(userIntent, 30, truncateByRunes)
+
+	style := cfg.styleFor(n, failed)
+	title := cfg.tf(style.Title, cfg.AppName, displayTitle)
+	if style.Emoji != "" {
+		title = style.Emoji + " " + title
+	}
+	if cfg.Escalated {
+		title = "🚨 " + title
+	}
+	return title
+}
+
+// resultLimit 卡片中执行结果的最大长度, 按 cfg.TruncateBy 计量
+const resultLimit = 500
+
+// 执行结果的展示方式
+const (
+	resultModeTruncate = "truncate"  // 默认: 保留开头, 超出部分截断
+	resultModeHeadTail = "head_tail" // 保留开头与结尾各 N 行, 中间以省略标记代替
+
+	defaultHeadTailLines = 5
+)
+
+// resultText 返回截断后的执行结果, 为空时使用占位文本
+func resultText(n CodexNotification, cfg FeishuConfig, failed bool) string {
 	resultContent := strings.TrimSpace(n.LastAssistantMessage)
 	if resultContent == "" {
-		resultContent = "（无执行结果描述）"
-	}
-	resultContent = truncateRunes(resultContent, 500)
-	elements = append(elements, FeishuDiv{
-		Tag: "div",
-		Text: &FeishuText{
-			Tag:     "lark_md",
-			Content: fmt.Sprintf("**✅ 执行结果:**\n%s", resultContent),
-		},
-	})
+		resultContent = emptyResultPlaceholder(failed, cfg)
+	}
+	if cfg.ResultMode == resultModeHeadTail {
+		resultContent = headTailLines(resultContent, cfg.HeadTailLines, func(omitted int) string {
+			return cfg.tf(msgOmittedLines, omitted)
+		})
+		// 单行过长时仍需截断, 此时保留首尾而不是只保留开头
+		return truncateMiddle(resultContent, resultLimit, cfg.TruncateBy)
+	}
+	return truncateText(resultContent, resultLimit, cfg.TruncateBy)
+}
 
-	elements = append(elements, FeishuHr{Tag: "hr"})
-
-	// 元素: 路径与ID
-	elements = append(elements, FeishuDiv{
-		Tag: "div",
-		Fields: []FeishuField{
-			{
-				IsShort: true,
-				Text: FeishuText{
-					Tag:     "lark_md",
-					Content: fmt.Sprintf("**📂 工作路径:**\n`%s`", n.Cwd),
-				},
-			},
-			{
-				IsShort: true,
-				Text: FeishuText{
-					Tag:     "lark_md",
-					Content: fmt.Sprintf("**🆔 Thread ID:**\n`%s`", n.ThreadID),
-				},
-			},
-		},
-	})
+// threadDisplay 渲染 Thread ID: 配置了 FEISHU_THREAD_URL_TEMPLATE 时为指向会话的链接, 否则为行内代码
+// 链接目标中的 thread-id 会做 URL 转义, 链接文字保持原样
+func threadDisplay(threadID string, cfg FeishuConfig) string {
+	if cfg.ThreadURLTemplate == "" || threadID == "" {
+		return fmt.Sprintf("`%s`", threadID)
+	}
+	link := strings.ReplaceAll(cfg.ThreadURLTemplate, threadURLPlaceholder, url.PathEscape(threadID))
+	return fmt.Sprintf("[%s](%s)", threadID, link)
+}
+
+// buildCard 构建交互式卡片 (schema 1.0)
+func buildCard(n CodexNotification, cfg FeishuConfig, failed bool) FeishuCard {
+	var elements []interface{}
+	if cfg.CardTemplate != nil {
+		elements = templateElements(n, cfg, failed)
+	}
+	if elements == nil {
+		elements = layoutElements(n, cfg, failed)
+	}
+	if cfg.DebugRaw {
+		elements = append(elements, debugElement(n, cfg))
+	}
 
-	// 元素: 底部备注
-	elements = append(elements, FeishuNote{
-		Tag: "note",
-		Elements: []FeishuText{
-			{
+	return FeishuCard{
+		Config: FeishuCardConfig{WideScreenMode: true},
+		Header: FeishuHeader{
+			Template: cfg.styleFor(n, failed).Template,
+			Title: FeishuText{
 				Tag:     "plain_text",
-				Content: fmt.Sprintf("Generated by Codex at %s", time.Now().Format("15:04:05")),
+				Content: cardTitle(n, cfg, failed),
 			},
 		},
-	})
+		Elements: elements,
+	}
+}
 
-	// 4. 组装完整消息体
-	cardMsg := FeishuCardMsg{
-		Timestamp: timestampStr, // 只有当配置了 secret 时，这才有意义，但传了也无妨
-		Sign:      sign,         // 签名
-		MsgType:   "interactive",
-		Card: FeishuCard{
-			Config: FeishuCardConfig{WideScreenMode: true},
-			Header: FeishuHeader{
-				Template: "indigo",
-				Title: FeishuText{
-					Tag:     "plain_text",
-					Content: fmt.Sprintf("🤖 Codex 任务完成: %s", displayTitle),
-				},
-			},
-			Elements: elements,
-		},
+// layoutElements 按 FEISHU_LAYOUT 组装内置布局的卡片元素
+func layoutElements(n CodexNotification, cfg FeishuConfig, failed bool) []interface{} {
+	var elements []interface{}
+	for _, line := range alertLines(n, cfg) {
+		elements = append(elements, larkMarkdownDiv(line))
 	}
+	switch cfg.Layout {
+	case layoutCompact:
+		// 紧凑布局: 输入与结果紧挨着, 去掉分隔线与底部备注, 保留字段区
+		elements = append(elements, larkMarkdownDiv(fmt.Sprintf("**%s:** %s", cfg.t(msgLabelInput), inputText(n, cfg, " / "))))
+		elements = append(elements, resultSection(n, cfg, failed)...)
+		elements = append(elements, fieldsElement(n, cfg))
+		if cfg.ShowAckButton {
+			elements = append(elements, ackElement(n, cfg))
+		}
+	default:
+		elements = append(elements, larkMarkdownDiv(fmt.Sprintf("**%s:**\n%s", cfg.t(msgLabelInput), inputText(n, cfg, "\n"))))
+		elements = append(elements, FeishuHr{Tag: "hr"})
+		if section := resultSection(n, cfg, failed); len(section) > 0 {
+			elements = append(elements, section...)
+			elements = append(elements, FeishuHr{Tag: "hr"})
+		}
+		elements = append(elements, fieldsElement(n, cfg))
+		if cfg.ShowAckButton {
+			elements = append(elements, ackElement(n, cfg))
+		}
+		if footer := buildFooter(n, cfg, time.Now()); len(footer.Elements) > 0 {
+			elements = append(elements, footer)
+		}
+	}
+	return elements
+}
+
+// larkMarkdownDiv 构建只含一段 lark_md 文本的 div
+func larkMarkdownDiv(content string) FeishuDiv {
+	return FeishuDiv{Tag: "div", Text: &FeishuText{Tag: "lark_md", Content: content}}
+}
 
-	payloadBytes, err := json.Marshal(cardMsg)
+// alertLines 返回卡片顶部的提醒行: 按邮箱或关键词 @ 的用户与升级告警
+func alertLines(n CodexNotification, cfg FeishuConfig) []string {
+	var lines []string
+	if ids := keywordMentions(n, cfg); len(ids) > 0 {
+		tags := make([]string, len(ids))
+		for i, id := range ids {
+			tags[i] = mentionTag(id)
+		}
+		lines = append(lines, strings.Join(tags, " "))
+	}
+	if cfg.Escalated {
+		content := fmt.Sprintf("**%s**", cfg.t(msgEscalation))
+		for _, id := range cfg.EscalateMention {
+			content += " " + mentionTag(id)
+		}
+		lines = append(lines, content)
+	}
+	return lines
+}
+
+// resultSection 构建执行结果及其附属元素: 截断提示、完整输出与归档文档链接
+// 没有正文的通知类型 (agent-turn-start) 返回 nil
+func resultSection(n CodexNotification, cfg FeishuConfig, failed bool) []interface{} {
+	label, text := eventBody(n, cfg, failed)
+	if label == "" {
+		return nil
+	}
+	elements := resultElements(fmt.Sprintf("**%s:**", cfg.t(label)), text)
+	if cfg.PayloadTrimmed {
+		if cfg.Layout == layoutCompact {
+			elements = append(elements, FeishuDiv{
+				Tag:  "div",
+				Text: &FeishuText{Tag: "plain_text", Content: cfg.t(msgTrimmed)},
+			})
+		} else {
+			elements = append(elements, FeishuNote{
+				Tag:      "note",
+				Elements: []FeishuText{{Tag: "plain_text", Content: cfg.t(msgTrimmed)}},
+			})
+		}
+	}
+	for _, link := range resultLinks(cfg) {
+		elements = append(elements, larkMarkdownDiv(link))
+	}
+	return elements
+}
+
+// resultLinks 返回结果之后的链接行: 完整输出、归档文档与附件
+func resultLinks(cfg FeishuConfig) []string {
+	var links []string
+	if cfg.PasteURL != "" {
+		links = append(links, fmt.Sprintf("[%s](%s)", cfg.t(msgPasteLink), cfg.PasteURL))
+	}
+	if cfg.ArchiveDocURL != "" {
+		links = append(links, fmt.Sprintf("[%s](%s)", cfg.t(msgArchiveLink), cfg.ArchiveDocURL))
+	}
+	if cfg.AttachmentName != "" {
+		links = append(links, cfg.tf(msgAttachment, cfg.AttachmentName))
+	}
+	return links
+}
+
+// fieldsElement 构建路径、ID 等元数据字段区
+func fieldsElement(n CodexNotification, cfg FeishuConfig) FeishuDiv {
+	var fields []FeishuField
+	for _, f := range cardFields(n, cfg) {
+		fields = append(fields, FeishuField{
+			IsShort: true,
+			Text:    FeishuText{Tag: "lark_md", Content: fmt.Sprintf("**%s:**\n%s", f.Label, f.Value)},
+		})
+	}
+	return FeishuDiv{Tag: "div", Fields: fields}
+}
+
+// cardField 是字段区中的一项, Value 为 lark_md 文本
+type cardField struct {
+	Label string
+	Value string
+}
+
+// cardFields 返回字段区的内容: 工作路径、Thread ID、消息数、结果长度及可选的分支与 CODEX_* 变量
+func cardFields(n CodexNotification, cfg FeishuConfig) []cardField {
+	fields := []cardField{
+		{cfg.t(msgLabelCwd), fmt.Sprintf("`%s`", n.Cwd)},
+		{cfg.t(msgLabelThread), threadDisplay(n.ThreadID, cfg)},
+		{cfg.t(msgLabelMessages), strconv.Itoa(len(n.InputMessages))},
+	}
+	if n.Type == typeTurnComplete {
+		fields = append(fields, cardField{cfg.t(msgLabelLength), cfg.tf(msgLengthValue, utf8.RuneCountInString(strings.TrimSpace(n.LastAssistantMessage)))})
+	}
+	if cfg.ShowGit {
+		if ref := gitRef(n.Cwd); ref != "" {
+			fields = append(fields, cardField{cfg.t(msgLabelGit), fmt.Sprintf("`%s`", ref)})
+		}
+	}
+	if cfg.CodexEnvMeta {
+		for _, v := range collectCodexEnv(os.Environ(), cfg.CodexEnvAllow) {
+			fields = append(fields, cardField{"⚙️ " + v.Name, fmt.Sprintf("`%s`", v.Value)})
+		}
+	}
+	return fields
+}
+
+// buildTextContent 构建纯文本消息内容: 标题、输入指令、执行结果与工作路径
+func buildTextContent(n CodexNotification, cfg FeishuConfig, failed bool) string {
+	var b strings.Builder
+	b.WriteString(cardTitle(n, cfg, failed))
+	if ids := keywordMentions(n, cfg); len(ids) > 0 {
+		b.WriteString("\n")
+		for i, id := range ids {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(textMentionTag(id))
+		}
+	}
+	if cfg.Escalated {
+		b.WriteString("\n" + cfg.t(msgEscalation))
+		for _, id := range cfg.EscalateMention {
+			b.WriteString(" " + textMentionTag(id))
+		}
+	}
+	fmt.Fprintf(&b, "\n\n%s:\n%s", cfg.t(msgLabelInput), inputText(n, cfg, "\n"))
+	if label, text := eventBody(n, cfg, failed); label != "" {
+		fmt.Fprintf(&b, "\n\n%s:\n%s", cfg.t(label), text)
+	}
+	if cfg.PayloadTrimmed {
+		b.WriteString("\n" + cfg.t(msgTrimmed))
+	}
+	if cfg.PasteURL != "" {
+		fmt.Fprintf(&b, "\n%s: %s", cfg.t(msgPasteLink), cfg.PasteURL)
+	}
+	if cfg.ArchiveDocURL != "" {
+		fmt.Fprintf(&b, "\n%s: %s", cfg.t(msgArchiveLink), cfg.ArchiveDocURL)
+	}
+	if cfg.AttachmentName != "" {
+		b.WriteString("\n" + cfg.tf(msgAttachment, cfg.AttachmentName))
+	}
+	fmt.Fprintf(&b, "\n\n%s: %s", cfg.t(msgLabelCwd), n.Cwd)
+	if cfg.ShowGit {
+		if ref := gitRef(n.Cwd); ref != "" {
+			fmt.Fprintf(&b, "\n%s: %s", cfg.t(msgLabelGit), ref)
+		}
+	}
+	if cfg.DebugRaw {
+		fmt.Fprintf(&b, "\n\n%s:\n%s", cfg.t(msgLabelRaw), rawNotificationJSON(n))
+	}
+	return b.String()
+}
+
+// gzipBytes 使用 gzip 压缩数据
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HTTPStatusError 表示 Webhook 返回了非 200 状态码
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 服务端通过 Retry-After 要求的等待时间, 0 表示未指定
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("status: %d, resp: %s", e.StatusCode, e.Body)
+}
+
+// newWebhookRequest 构造发往 Webhook 的 POST 请求, 设置内容类型、请求 ID 与自定义请求头
+func newWebhookRequest(ctx context.Context, cfg FeishuConfig, payloadBytes []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.WebhookURL, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if cfg.RequestID != "" {
+		req.Header.Set("X-Request-Id", cfg.RequestID)
+	}
+	if cfg.IdempotencyKey != "" {
+		req.Header.Set(headerIdempotencyKey, cfg.IdempotencyKey)
 	}
+	signPayload(req.Header, cfg.PayloadSignSecret, payloadBytes, time.Now())
+	// 自定义请求头最后设置: 只有显式配置了 Content-Type 等同名头时才会覆盖默认值
+	for name, values := range cfg.ExtraHeaders {
+		req.Header[name] = values
+	}
+	return req, nil
+}
 
-	// 5. 发送请求
-	req, err := http.NewRequest("POST", cfg.WebhookURL, bytes.NewBuffer(payloadBytes))
+// postCard 发送一次请求并校验飞书返回
+func postCard(ctx context.Context, client doer, cfg FeishuConfig, payloadBytes []byte) error {
+	req, err := newWebhookRequest(ctx, cfg, payloadBytes)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	logger.Debug("sending feishu card", "webhook", redactWebhook(cfg.WebhookURL), "signed", cfg.Secret != "", "bytes", len(payloadBytes))
+	runMetrics.attempts.Add(1)
+	runMetrics.bytesSent.Add(int64(len(payloadBytes)))
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	logger.Debug("feishu responded", "status", resp.StatusCode)
+	skew, haveSkew := clockSkew(resp.Header.Get("Date"), time.Now())
+	if cfg.Secret != "" && haveSkew && (skew > clockSkewWarn || skew < -clockSkewWarn) {
+		logger.Warn("system clock differs from feishu server time, signed requests may be rejected", "skew", skew.Round(time.Second))
+	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status: %d, resp: %s", resp.StatusCode, string(bodyBytes))
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: serverRetryAfter(resp.Header, time.Now()),
+		}
 	}
 
 	var feishuResp FeishuResponse
 	if err := json.Unmarshal(bodyBytes, &feishuResp); err != nil {
 		return fmt.Errorf("decode feishu response: %w (payload: %s)", err, string(bodyBytes))
 	}
-	if feishuResp.Code != 0 || feishuResp.StatusCode != 0 {
-		return fmt.Errorf("feishu error code=%d statusCode=%d msg=%s statusMessage=%s", feishuResp.Code, feishuResp.StatusCode, feishuResp.Msg, feishuResp.StatusMessage)
+	if apiErr := feishuResp.apiError(); apiErr != nil {
+		logger.Warn("feishu rejected card", "code", apiErr.Code, "codeText", apiErr.CodeText, "statusCode", apiErr.StatusCode, "msg", apiErr.Msg)
+		if apiErr.signatureRejected() && haveSkew {
+			// 有服务器时间可比对时, 明确指出是时钟问题还是密钥问题
+			if skew > clockSkewWarn || skew < -clockSkewWarn {
+				logger.Warn("signature rejected, the system clock is likely skewed", "skew", skew.Round(time.Second))
+			} else {
+				logger.Warn("signature rejected although the clock is in sync, check FEISHU_SECRET", "skew", skew.Round(time.Second))
+			}
+		}
+		apiErr.RetryAfter = serverRetryAfter(resp.Header, time.Now())
+		return apiErr
 	}
 
+	logger.Info("feishu card sent", "webhook", redactWebhook(cfg.WebhookURL))
 	return nil
 }
 
-// truncateRunes 截断字符串到指定的 rune 长度, 过长时添加省略号
-func truncateRunes(s string, limit int) string {
-	if limit <= 0 {
-		return ""
-	}
-	runes := []rune(s)
-	if len(runes) <= limit {
-		return s
-	}
-	if limit <= 3 {
-		return string(runes[:limit])
+// 重定向策略
+const (
+	redirectNone     = "none"      // 不跟随重定向 (默认)
+	redirectSameHost = "same-host" // 仅跟随同主机重定向
+	redirectAll      = "all"       // 跟随所有重定向
+)
+
+// doer 是发送 HTTP 请求的最小接口, 由 *http.Client 实现; 测试时可替换为假实现
+type doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newHTTPClient 创建发送 Webhook 使用的 HTTP 客户端
+// 默认不跟随重定向, 避免已签名的负载被转发到意外的主机
+func newHTTPClient(cfg FeishuConfig) *http.Client {
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch cfg.FollowRedirects {
+	case redirectAll:
+		// 使用 Go 默认行为
+	case redirectSameHost:
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Host != via[0].URL.Host {
+				logger.Warn("refusing cross-host redirect", "to", req.URL.Host)
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+	default:
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
 	}
-	return string(runes[:limit-3]) + "..."
+	return client
 }
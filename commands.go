@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// runTestCommand 发送一张示例卡片, 用于验证 Webhook 与签名配置, 返回进程退出码
+func runTestCommand() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config error", "err", err)
+		return 1
+	}
+	attachRequestID(cfg)
+
+	if err := notify(context.Background(), cfg, sampleNotification()); err != nil {
+		var apiErr *FeishuAPIError
+		if errors.As(err, &apiErr) {
+			code, msg := strconv.Itoa(apiErr.Code), apiErr.Msg
+			if apiErr.CodeText != "" {
+				code = apiErr.CodeText
+			} else if apiErr.Code == 0 {
+				code, msg = strconv.Itoa(apiErr.StatusCode), apiErr.StatusMessage
+			}
+			fmt.Fprintf(os.Stderr, "Test card failed: feishu code=%s msg=%s\n", code, msg)
+			if errors.Is(err, ErrWebhookInvalid) {
+				fmt.Fprintf(os.Stderr, "Hint: %v\n", ErrWebhookInvalid)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Test card failed: %v\n", err)
+		}
+		return 1
+	}
+
+	cfg.printf(os.Stdout, "Test card sent successfully.\n")
+	return 0
+}
+
+// sampleNotification 构造一条模拟的 Codex 事件
+func sampleNotification() CodexNotification {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "/home/dev/projects/demo"
+	}
+	return CodexNotification{
+		Type:     "agent-turn-complete",
+		ThreadID: "0199a3c2-5f1e-7b40-9d2a-3e8c1f6b7a10",
+		TurnID:   "1",
+		Cwd:      cwd,
+		InputMessages: []string{
+			"为 README 补充安装与配置说明",
+		},
+		LastAssistantMessage: "已更新 README.md:\n- 新增「安装」章节, 说明如何通过 go build 构建\n- 补充 FEISHU_WEBHOOK_URL / FEISHU_SECRET 的配置示例\n\n这是一条由 `codex-notify test` 发送的测试消息。",
+	}
+}
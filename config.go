@@ -0,0 +1,1033 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+type FeishuConfig struct {
+	WebhookURL    string // 当前 sink 的 Webhook; 配置了多个时为第一个
+	Secret        string
+	Timeout       time.Duration
+	RequestID     string   // 请求 ID, 作为 X-Request-Id 发送并写入日志
+	CodexEnvMeta  bool     // 是否自动展示 CODEX_* 环境变量
+	CodexEnvAllow []string // 允许展示的变量名 (支持 path.Match 通配符)
+
+	WebhookURLs    []string // FEISHU_WEBHOOK_URL 中配置的全部 Webhook, 只有一个时为空
+	WebhookSecrets []string // 与 WebhookURLs 按顺序对应的 Secret, 为空时共用 Secret
+
+	MultiBackendStrict bool // 任一 sink 配置有误时直接失败, 而不是跳过
+	Concurrency        int  // 同时发送的 sink 数上限, 1 表示逐个发送
+
+	Backend     string // 发送方式: webhook (默认) / app / slack 等, 可选值由各 sink 注册
+	AppID       string // 自建应用 App ID
+	AppSecret   string // 自建应用 App Secret
+	ChatID      string // 自建应用发送的目标群 chat_id
+	OpenAPIBase string // 开放平台地址, 默认 https://open.feishu.cn
+
+	SlackWebhookURL    string // FEISHU_BACKEND=slack 时发送的 Slack Incoming Webhook
+	DingTalkWebhookURL string // FEISHU_BACKEND=dingtalk 时发送的钉钉自定义机器人 Webhook
+	DingTalkSecret     string // 钉钉机器人的加签密钥, 为空时不签名
+	WeComWebhookURL    string // FEISHU_BACKEND=wecom 时发送的企业微信群机器人 Webhook
+	TelegramBotToken   string // FEISHU_BACKEND=telegram 时使用的 Bot token
+	TelegramChatID     string // Telegram 目标会话的 chat_id (或 @channel)
+	TelegramAPIBase    string // Bot API 地址, 默认 https://api.telegram.org
+
+	GenericURL      string             // FEISHU_BACKEND=generic 时 POST 的地址
+	GenericHeaders  http.Header        // 通用 Webhook 的请求头, 不含 FEISHU_EXTRA_HEADERS
+	GenericTemplate *template.Template // 通用 Webhook 的请求体模板, 为 nil 时发送通知本身
+
+	SMTPHost     string   // FEISHU_BACKEND=email 时使用的 SMTP 服务器
+	SMTPPort     int      // SMTP 端口, 默认 587, 465 为隐式 TLS
+	SMTPUsername string   // SMTP 认证用户名, 为空时不认证
+	SMTPPassword string   // SMTP 认证密码
+	SMTPFrom     string   // 发件人, 默认为 SMTPUsername
+	SMTPTo       []string // 收件人
+
+	NtfyURL       string // ntfy 主题地址 (如 https://ntfy.sh/my-topic), 设置后额外推送到手机
+	NtfyToken     string // ntfy 访问令牌, 为空时匿名发布
+	PushoverToken string // Pushover 应用 token, 与 PushoverUser 同时设置后额外推送到手机
+	PushoverUser  string // Pushover 用户或分组 key
+
+	ArchiveDoc     bool   // 自建应用方式下将完整记录归档为云文档
+	DocFolderToken string // 归档文档所在文件夹 token (选填)
+	DocBaseURL     string // 文档链接前缀, 后接 document_id
+	ArchiveDocURL  string // 本次卡片附带的归档文档链接
+
+	AttachFullOutput bool   // 自建应用方式下, 结果被截断时将完整内容作为文件发送
+	AttachmentName   string // 本次卡片提到的附件文件名
+
+	PasteUpload string // 粘贴服务地址, 结果被截断时上传完整内容 (选填)
+	PasteURL    string // 本次卡片附带的完整输出链接
+
+	FollowRedirects string        // 重定向策略: none / same-host / all
+	MaxRetries      int           // 可重试错误的最大重试次数
+	RetryDelay      time.Duration // 首次重试前的等待时间
+	RetryBackoff    float64       // 每次重试后等待时间的倍数, 1 表示固定间隔
+	RetryMaxDelay   time.Duration // 退避后等待时间的上限
+	RetryJitter     float64       // 等待时间随机缩短的最大比例, 0 表示不随机
+	MaxRetryAfter   time.Duration // 服务端 Retry-After 的最大采纳值
+	SendJitter      time.Duration // 发送前随机等待的上限, 0 表示不等待
+	Gzip            bool          // 使用 gzip 压缩 Webhook 请求体
+	ExtraHeaders    http.Header   // 附加到 Webhook 请求上的自定义请求头
+
+	PayloadSignSecret string // 为 Webhook 请求体计算 X-Codex-Signature 的密钥 (选填)
+	IdempotencyKey    string // 本次请求的 X-Codex-Idempotency-Key, 由 thread-id、turn-id 与事件类型派生
+
+	ContentPipeline []contentTransform // 应用于输入与结果的文本变换, 按顺序执行
+	DedupInputs     bool               // 合并连续重复的输入指令
+
+	FailureKeywords    []string // 判定任务失败的关键词, 默认为空 (只看 status 与 CODEX_ERROR)
+	FailurePlaceholder string   // 失败且无执行结果时的占位文本, 为空时使用当前语言的默认文案
+	CodexError         string   // Codex 通过 CODEX_ERROR 传入的失败原因
+	StateDir           string   // 状态文件目录
+
+	EscalateAfter      int      // 同一 cwd 连续失败达到该次数时升级告警, 0 表示关闭
+	EscalateWebhookURL string   // 升级告警发送到的 Webhook (选填)
+	EscalateSecret     string   // 升级 Webhook 的签名 Secret (选填)
+	EscalateMention    []string // 升级时 @ 的 open_id, 支持 all
+	Escalated          bool     // 当前卡片是否为升级告警
+
+	FailureWebhookURL  string // 失败通知改发到的 Webhook (选填)
+	FailureSecret      string // 失败 Webhook 的签名 Secret (选填)
+	FailureAlsoDefault bool   // 失败通知同时发送到原有 sink, 而不是只发往失败 Webhook
+
+	MentionOnKeywords []keywordMention // 执行结果命中关键词时 @ 对应用户
+	MentionEmails     []string         // 每张卡片都 @ 的用户邮箱, 需要自建应用方式
+	EmailMentions     []string         // 本次卡片由邮箱解析出的 open_id
+
+	HeaderColor string               // 成功卡片的卡片头颜色, 失败卡片仍为红色 (选填)
+	TypeStyles  map[string]typeStyle // 按通知类型区分的卡片头样式
+
+	ThreadURLTemplate string // Thread ID 链接模板, 含 {thread_id} 占位符 (选填)
+	ShowGit           bool   // 展示工作路径的 git 分支与短提交
+	ShowAckButton     bool   // 卡片底部附加 "确认收到" 按钮
+	CallbackURL       string // 确认按钮的回调地址, 由用户自行提供服务
+
+	MaxPayloadBytes int  // 消息体字节数上限, 超出时逐步截断内容, 0 表示不检查
+	InputBudget     int  // 本次渲染的输入指令字符预算, 0 表示不限
+	ResultBudget    int  // 本次渲染的执行结果字符预算, 0 表示不限
+	PayloadTrimmed  bool // 本次渲染是否因预算截断了内容
+
+	TruncateBy truncateStrategy // 执行结果与事件详情的截断计量方式, 按字节限制的渠道 (企业微信) 改为按字节
+
+	FooterRelative bool           // 底部备注显示相对任务开始时间, 需要通知中带 started-at
+	FooterDate     bool           // 底部时间同时显示日期
+	Location       *time.Location // 底部时间使用的时区
+	FooterLines    []string       // 底部备注行, 见 footerLine* 常量
+	FooterTemplate string         // 时间行的自定义文案, 支持 {time} {host} {version} {thread_id} {app}
+
+	MinResultLen int           // 成功任务的执行结果少于该字符数时不发送, 0 表示不限制
+	DedupWindow  time.Duration // 同一 turn-id 在该时间窗口内只发送一次, 0 表示关闭
+	EditWindow   time.Duration // 自建应用方式下, 同一 turn-id 在该窗口内更新已发送的卡片, 0 表示关闭
+
+	SimilarThreshold float64 // 与同一工作路径上一张卡片的内容相似度达到该值时不发送, 0 表示关闭
+
+	CwdAllow []string // 只发送工作路径命中这些前缀或通配符的通知, 为空表示不限制
+	CwdDeny  []string // 不发送工作路径命中这些前缀或通配符的通知, 优先于 CwdAllow
+
+	Routes []route // 按工作路径选择 Webhook 的路由规则, 按顺序取第一条命中的
+
+	NotifyTypes map[string]bool // 需要处理的通知类型, 为 nil 时只处理 agent-turn-complete
+
+	DeadLetterDir   string // 发送失败时保存通知的目录, 供 replay 重发
+	DeadLetterFlush bool   // 发送成功后顺带重发死信目录中积压的通知
+
+	Digest bool // 通知写入摘要队列, 由 digest 子命令汇总发送
+
+	ServeAddr string // serve 子命令的监听地址: 回环地址的 host:port 或 unix:<path>
+
+	MsgFormat  string // 消息格式: card / text
+	CardSchema int    // 卡片 schema: 1 (默认) / 2
+	Lang       string // 卡片文案语言, 见 catalog
+	AppName    string // 标题与底部署名中的 agent 名称, 默认 Codex
+
+	DebugRaw     bool // 卡片末尾附加原始通知 JSON, 用于排查
+	DryRun       bool // 只输出卡片 JSON, 不实际发送
+	DryRunIndent int  // dry-run 输出的缩进空格数, 0 表示紧凑输出
+
+	Quiet      bool // 只输出错误信息
+	FailSilent bool // 发送失败时只记录 warn 日志, 进程仍以 0 退出
+
+	SignalGrace time.Duration // 收到 SIGTERM/SIGINT 后允许进行中的发送继续的时间, 0 表示立即放弃
+	Output      string        // 结果输出格式: text (默认, 不输出) / json
+	MetricsFile string        // 每次运行追加一行 JSON 指标的文件, 为空表示不写
+	Layout      string        // 卡片布局: rich (默认) / compact
+
+	ResultMode    string // 执行结果过长时的处理方式: truncate (默认) / head_tail
+	HeadTailLines int    // head_tail 模式下保留的首尾行数
+
+	// CardTemplate 自定义卡片元素模板 (FEISHU_CARD_TEMPLATE_FILE), 为 nil 时使用内置布局
+	CardTemplate *template.Template
+}
+
+// defaultCodexEnvAllow 默认允许展示的 CODEX_* 环境变量
+var defaultCodexEnvAllow = []string{
+	"CODEX_SANDBOX",
+	"CODEX_SANDBOX_NETWORK_DISABLED",
+	"CODEX_MODEL",
+	"CODEX_PROFILE",
+}
+
+// threadURLPlaceholder FEISHU_THREAD_URL_TEMPLATE 中的 thread-id 占位符
+const threadURLPlaceholder = "{thread_id}"
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 2
+	defaultRetryDelay = time.Second
+
+	defaultRetryBackoff  = 2.0
+	defaultRetryMaxDelay = 30 * time.Second
+	defaultRetryJitter   = 0.2
+	defaultMaxRetryAfter = 30 * time.Second
+	defaultConcurrency   = 4
+)
+
+func loadConfig() (FeishuConfig, error) {
+	src, err := newConfigSource()
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	// 是否至少配置了一个 sink 由 configuredNotifiers 检查
+	webhook, fromFile, err := src.fileValue("FEISHU_WEBHOOK_URL")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		webhook, err = expandEnv("FEISHU_WEBHOOK_URL", src.get("FEISHU_WEBHOOK_URL"))
+		if err != nil {
+			return FeishuConfig{}, err
+		}
+		if webhook != src.get("FEISHU_WEBHOOK_URL") {
+			// 展开后的地址需在此校验, 避免变量内容拼出畸形 URL
+			if webhook == "" {
+				return FeishuConfig{}, fmt.Errorf("FEISHU_WEBHOOK_URL: empty after variable expansion")
+			}
+			for _, u := range splitTargets(webhook) {
+				if err := validateWebhookURL(u); err != nil {
+					return FeishuConfig{}, fmt.Errorf("FEISHU_WEBHOOK_URL after variable expansion: %w", err)
+				}
+			}
+		}
+	}
+	// 文件中的值按原样使用, 不做变量展开
+	secret, fromFile, err := src.fileValue("FEISHU_SECRET")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		secret, err = expandEnv("FEISHU_SECRET", src.get("FEISHU_SECRET"))
+		if err != nil {
+			return FeishuConfig{}, err
+		}
+	}
+	// 多个 Webhook 时可逐个配置 Secret (按顺序对应), 只有一个 Secret 时所有 Webhook 共用
+	webhooks := splitTargets(webhook)
+	var webhookSecrets []string
+	if len(webhooks) > 1 {
+		webhook = webhooks[0]
+		if secrets := splitTargets(secret); len(secrets) > 1 {
+			if len(secrets) != len(webhooks) {
+				return FeishuConfig{}, fmt.Errorf("FEISHU_SECRET: %d secrets for %d webhooks (give one shared secret or one per webhook)", len(secrets), len(webhooks))
+			}
+			webhookSecrets, secret = secrets, secrets[0]
+		}
+	} else {
+		webhooks = nil
+	}
+
+	timeout, err := src.duration("FEISHU_TIMEOUT", defaultTimeout)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	multiBackendStrict, err := src.bool("FEISHU_MULTI_BACKEND_STRICT")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	concurrency, err := src.int("FEISHU_CONCURRENCY", defaultConcurrency)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if concurrency < 1 {
+		return FeishuConfig{}, fmt.Errorf("FEISHU_CONCURRENCY: must be at least 1, got %d", concurrency)
+	}
+	backend, err := parseBackend(src.get("FEISHU_BACKEND"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	openAPIBase, err := validateOpenAPIBase(src.get("FEISHU_OPEN_API_BASE"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	telegramBotToken, fromFile, err := src.fileValue("FEISHU_TELEGRAM_BOT_TOKEN")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		telegramBotToken = src.get("FEISHU_TELEGRAM_BOT_TOKEN")
+	}
+	telegramAPIBase, err := validateTelegramAPIBase(src.get("FEISHU_TELEGRAM_API_BASE"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	smtpPort, err := src.int("FEISHU_SMTP_PORT", defaultSMTPPort)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if smtpPort < 1 || smtpPort > 65535 {
+		return FeishuConfig{}, fmt.Errorf("FEISHU_SMTP_PORT: must be between 1 and 65535, got %d", smtpPort)
+	}
+	smtpPassword, fromFile, err := src.fileValue("FEISHU_SMTP_PASSWORD")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		smtpPassword = src.get("FEISHU_SMTP_PASSWORD")
+	}
+	ntfyToken, fromFile, err := src.fileValue("FEISHU_NTFY_TOKEN")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		ntfyToken = src.get("FEISHU_NTFY_TOKEN")
+	}
+	pushoverToken, fromFile, err := src.fileValue("FEISHU_PUSHOVER_TOKEN")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if !fromFile {
+		pushoverToken = src.get("FEISHU_PUSHOVER_TOKEN")
+	}
+
+	archiveDoc, err := src.bool("FEISHU_ARCHIVE_DOC")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	attachFullOutput, err := src.bool("FEISHU_ATTACH_FULL_OUTPUT")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	docBaseURL := src.get("FEISHU_DOC_BASE_URL")
+	if docBaseURL == "" {
+		docBaseURL = defaultDocBaseURL
+	}
+
+	followRedirects, err := parseRedirectPolicy(src.get("FEISHU_FOLLOW_REDIRECTS"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	maxRetries, err := src.int("FEISHU_MAX_RETRIES", defaultMaxRetries)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	retryDelay, err := src.duration("FEISHU_RETRY_DELAY", defaultRetryDelay)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	retryBackoff := defaultRetryBackoff
+	if raw := src.get("FEISHU_RETRY_BACKOFF"); raw != "" {
+		retryBackoff, err = strconv.ParseFloat(raw, 64)
+		if err != nil || retryBackoff < 1 {
+			return FeishuConfig{}, fmt.Errorf("FEISHU_RETRY_BACKOFF: invalid multiplier %q (want a number of at least 1)", raw)
+		}
+	}
+	retryMaxDelay, err := src.duration("FEISHU_RETRY_MAX_DELAY", defaultRetryMaxDelay)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	retryJitter := defaultRetryJitter
+	if src.get("FEISHU_RETRY_JITTER") != "" {
+		if retryJitter, err = src.ratio("FEISHU_RETRY_JITTER"); err != nil {
+			return FeishuConfig{}, err
+		}
+	}
+
+	maxRetryAfter, err := src.duration("FEISHU_MAX_RETRY_AFTER", defaultMaxRetryAfter)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	sendJitter, err := src.duration("FEISHU_SEND_JITTER", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	gzipBody, err := src.bool("FEISHU_GZIP")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	codexEnvMeta, err := src.bool("FEISHU_CODEX_ENV")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	codexEnvAllow := src.list("FEISHU_CODEX_ENV_ALLOW")
+	if len(codexEnvAllow) == 0 {
+		codexEnvAllow = defaultCodexEnvAllow
+	}
+
+	contentPipeline, err := parseContentPipeline(src.list("FEISHU_CONTENT_PIPELINE"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	deadLetterFlush, err := src.boolOr("FEISHU_DEADLETTER_FLUSH", true)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	digest, err := src.bool("FEISHU_DIGEST")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	serveAddr := src.get("FEISHU_SERVE_ADDR")
+	if serveAddr == "" {
+		serveAddr = defaultServeAddr
+	}
+
+	dedupInputs, err := src.bool("FEISHU_DEDUP_INPUTS")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	failureKeywords := src.list("FEISHU_FAILURE_KEYWORDS")
+
+	escalateAfter, err := src.int("FEISHU_ESCALATE_AFTER", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	escalateWebhookURL := src.get("FEISHU_ESCALATE_WEBHOOK_URL")
+	if escalateWebhookURL != "" {
+		if err := validateWebhookURL(escalateWebhookURL); err != nil {
+			return FeishuConfig{}, fmt.Errorf("FEISHU_ESCALATE_WEBHOOK_URL: %w", err)
+		}
+	}
+
+	footerRelative, err := src.bool("FEISHU_FOOTER_RELATIVE")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	footerDate, err := src.bool("FEISHU_FOOTER_DATE")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	showHost, err := src.bool("FEISHU_SHOW_HOST")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	footerLines, err := parseFooterLines(src.list("FEISHU_FOOTER_LINES"), showHost)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	minResultLen, err := src.int("FEISHU_MIN_RESULT_LEN", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	cwdAllow, err := parseCwdPatterns("FEISHU_CWD_ALLOW", src.list("FEISHU_CWD_ALLOW"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	cwdDeny, err := parseCwdPatterns("FEISHU_CWD_DENY", src.list("FEISHU_CWD_DENY"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	dedupWindow, err := src.duration("FEISHU_DEDUP_WINDOW", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	editWindow, err := src.duration("FEISHU_EDIT_WINDOW", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	similarityThreshold, err := src.ratio("FEISHU_SIMILARITY_THRESHOLD")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	mentionOnKeywords, err := parseKeywordMentions(src.list("FEISHU_MENTION_ON_KEYWORDS"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	failureWebhookURL := src.get("FEISHU_FAILURE_WEBHOOK_URL")
+	if failureWebhookURL != "" {
+		if err := validateWebhookURL(failureWebhookURL); err != nil {
+			return FeishuConfig{}, fmt.Errorf("FEISHU_FAILURE_WEBHOOK_URL: %w", err)
+		}
+	}
+	failureAlsoDefault, err := src.bool("FEISHU_FAILURE_ALSO_DEFAULT")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	routes, err := parseRoutes(src.list("FEISHU_ROUTES"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	var notifyTypes map[string]bool
+	if items := src.list("FEISHU_NOTIFY_TYPES"); len(items) > 0 {
+		notifyTypes = parseNotifyTypes(items)
+	}
+	mentionEmails := src.list("FEISHU_MENTION_EMAILS")
+	if len(mentionEmails) > 0 && backend != backendApp {
+		logger.Warn("FEISHU_MENTION_EMAILS requires FEISHU_BACKEND=app to resolve emails; email mentions are ignored")
+	}
+	headerColor, err := parseHeaderColor(src.get("FEISHU_HEADER_COLOR"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	typeStyles, err := parseTypeStyles(src.typeStyleOverrides(), headerColor)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	showGit, err := src.bool("FEISHU_SHOW_GIT")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	showAckButton, err := src.bool("FEISHU_SHOW_ACK_BUTTON")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	callbackURL := src.get("FEISHU_CALLBACK_URL")
+	if err := validateCallbackURL(callbackURL, showAckButton); err != nil {
+		return FeishuConfig{}, err
+	}
+	maxPayloadBytes, err := src.int("FEISHU_MAX_PAYLOAD_BYTES", defaultMaxPayloadBytes)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	threadURLTemplate := src.get("FEISHU_THREAD_URL_TEMPLATE")
+	if threadURLTemplate != "" && !strings.Contains(threadURLTemplate, threadURLPlaceholder) {
+		return FeishuConfig{}, fmt.Errorf("FEISHU_THREAD_URL_TEMPLATE: missing %s placeholder", threadURLPlaceholder)
+	}
+
+	msgFormat := strings.ToLower(src.get("FEISHU_MSG_FORMAT"))
+	switch msgFormat {
+	case "":
+		msgFormat = msgFormatCard
+	case msgFormatCard, msgFormatText:
+	default:
+		return FeishuConfig{}, fmt.Errorf("FEISHU_MSG_FORMAT: unknown format %q (want card or text)", msgFormat)
+	}
+
+	var cardSchema int
+	switch raw := src.get("FEISHU_CARD_SCHEMA"); raw {
+	case "", "1", "1.0":
+		cardSchema = cardSchemaV1
+	case "2", "2.0":
+		cardSchema = cardSchemaV2
+	default:
+		return FeishuConfig{}, fmt.Errorf("FEISHU_CARD_SCHEMA: unknown schema %q (want 1 or 2)", raw)
+	}
+
+	appName := src.get("FEISHU_APP_NAME")
+	if appName == "" {
+		appName = defaultAppName
+	}
+
+	lang, err := parseLang(src.get("FEISHU_LANG"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+
+	dryRun, err := src.bool("FEISHU_DRY_RUN")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	dryRunIndent, err := src.int("FEISHU_DRY_RUN_INDENT", 2)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	quiet, err := src.bool("FEISHU_QUIET")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	failSilent, err := src.bool("FEISHU_FAIL_SILENT")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	signalGrace, err := src.duration("FEISHU_SIGNAL_GRACE", 0)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	layout := strings.ToLower(src.get("FEISHU_LAYOUT"))
+	switch layout {
+	case "":
+		layout = layoutRich
+	case layoutRich, layoutCompact:
+	default:
+		return FeishuConfig{}, fmt.Errorf("FEISHU_LAYOUT: unknown layout %q (want rich or compact)", layout)
+	}
+	debugRaw, err := src.bool("FEISHU_DEBUG_RAW")
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	resultMode := strings.ToLower(src.get("FEISHU_RESULT_MODE"))
+	switch resultMode {
+	case "":
+		resultMode = resultModeTruncate
+	case resultModeTruncate, resultModeHeadTail:
+	default:
+		return FeishuConfig{}, fmt.Errorf("FEISHU_RESULT_MODE: unknown mode %q (want truncate or head_tail)", resultMode)
+	}
+	headTailLines, err := src.int("FEISHU_RESULT_HEAD_TAIL_LINES", defaultHeadTailLines)
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	if headTailLines < 1 {
+		return FeishuConfig{}, fmt.Errorf("FEISHU_RESULT_HEAD_TAIL_LINES: must be at least 1, got %d", headTailLines)
+	}
+	genericTemplate, err := loadGenericTemplate(src.get("FEISHU_GENERIC_TEMPLATE_FILE"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	cardTemplate, err := loadCardTemplate(src.get("FEISHU_CARD_TEMPLATE_FILE"))
+	if err != nil {
+		return FeishuConfig{}, err
+	}
+	output := strings.ToLower(src.get("FEISHU_OUTPUT"))
+	switch output {
+	case "":
+		output = outputText
+	case outputText, outputJSON:
+	default:
+		return FeishuConfig{}, fmt.Errorf("FEISHU_OUTPUT: unknown output %q (want text or json)", output)
+	}
+
+	return FeishuConfig{
+		WebhookURL:         webhook,
+		WebhookURLs:        webhooks,
+		WebhookSecrets:     webhookSecrets,
+		Secret:             secret,
+		Timeout:            timeout,
+		RequestID:          resolveRequestID(),
+		MultiBackendStrict: multiBackendStrict,
+		Concurrency:        concurrency,
+		Backend:            backend,
+		AppID:              src.get("FEISHU_APP_ID"),
+		AppSecret:          src.get("FEISHU_APP_SECRET"),
+		ChatID:             src.get("FEISHU_CHAT_ID"),
+		OpenAPIBase:        openAPIBase,
+		SlackWebhookURL:    src.get("FEISHU_SLACK_WEBHOOK_URL"),
+		DingTalkWebhookURL: src.get("FEISHU_DINGTALK_WEBHOOK_URL"),
+		DingTalkSecret:     src.get("FEISHU_DINGTALK_SECRET"),
+		WeComWebhookURL:    src.get("FEISHU_WECOM_WEBHOOK_URL"),
+		TelegramBotToken:   telegramBotToken,
+		TelegramChatID:     src.get("FEISHU_TELEGRAM_CHAT_ID"),
+		TelegramAPIBase:    telegramAPIBase,
+		GenericURL:         src.get("FEISHU_GENERIC_URL"),
+		GenericHeaders:     parseExtraHeaders("FEISHU_GENERIC_HEADERS", src.get("FEISHU_GENERIC_HEADERS")),
+		GenericTemplate:    genericTemplate,
+		SMTPHost:           src.get("FEISHU_SMTP_HOST"),
+		SMTPPort:           smtpPort,
+		SMTPUsername:       src.get("FEISHU_SMTP_USERNAME"),
+		SMTPPassword:       smtpPassword,
+		SMTPFrom:           src.get("FEISHU_SMTP_FROM"),
+		SMTPTo:             src.list("FEISHU_SMTP_TO"),
+		NtfyURL:            src.get("FEISHU_NTFY_URL"),
+		NtfyToken:          ntfyToken,
+		PushoverToken:      pushoverToken,
+		PushoverUser:       src.get("FEISHU_PUSHOVER_USER"),
+		ArchiveDoc:         archiveDoc,
+		AttachFullOutput:   attachFullOutput,
+		DocFolderToken:     src.get("FEISHU_DOC_FOLDER_TOKEN"),
+		DocBaseURL:         docBaseURL,
+		PasteUpload:        src.get("FEISHU_PASTE_UPLOAD"),
+		FollowRedirects:    followRedirects,
+		ExtraHeaders:       parseExtraHeaders("FEISHU_EXTRA_HEADERS", src.get("FEISHU_EXTRA_HEADERS")),
+		PayloadSignSecret:  src.get("FEISHU_PAYLOAD_SIGN_SECRET"),
+		MaxRetries:         maxRetries,
+		RetryDelay:         retryDelay,
+		RetryBackoff:       retryBackoff,
+		RetryMaxDelay:      retryMaxDelay,
+		RetryJitter:        retryJitter,
+		MaxRetryAfter:      maxRetryAfter,
+		SendJitter:         sendJitter,
+		Gzip:               gzipBody,
+		CodexEnvMeta:       codexEnvMeta,
+		CodexEnvAllow:      codexEnvAllow,
+		ContentPipeline:    contentPipeline,
+		DedupInputs:        dedupInputs,
+		FailureKeywords:    failureKeywords,
+		FailurePlaceholder: src.get("FEISHU_FAILURE_PLACEHOLDER"),
+		CodexError:         strings.TrimSpace(os.Getenv("CODEX_ERROR")),
+		StateDir:           src.get("FEISHU_STATE_DIR"),
+		EscalateAfter:      escalateAfter,
+		EscalateWebhookURL: escalateWebhookURL,
+		EscalateSecret:     src.get("FEISHU_ESCALATE_SECRET"),
+		FailureWebhookURL:  failureWebhookURL,
+		FailureSecret:      src.get("FEISHU_FAILURE_SECRET"),
+		FailureAlsoDefault: failureAlsoDefault,
+		EscalateMention:    src.list("FEISHU_ESCALATE_MENTION"),
+		MentionOnKeywords:  mentionOnKeywords,
+		MentionEmails:      mentionEmails,
+		HeaderColor:        headerColor,
+		TypeStyles:         typeStyles,
+		ThreadURLTemplate:  threadURLTemplate,
+		ShowGit:            showGit,
+		ShowAckButton:      showAckButton,
+		CallbackURL:        callbackURL,
+		MaxPayloadBytes:    maxPayloadBytes,
+		FooterRelative:     footerRelative,
+		FooterDate:         footerDate,
+		Location:           loadLocation(src.get("FEISHU_TIMEZONE")),
+		FooterLines:        footerLines,
+		FooterTemplate:     src.get("FEISHU_FOOTER_TEMPLATE"),
+		MinResultLen:       minResultLen,
+		DedupWindow:        dedupWindow,
+		EditWindow:         editWindow,
+		SimilarThreshold:   similarityThreshold,
+		CwdAllow:           cwdAllow,
+		CwdDeny:            cwdDeny,
+		Routes:             routes,
+		NotifyTypes:        notifyTypes,
+		DeadLetterDir:      src.get("FEISHU_DEADLETTER_DIR"),
+		DeadLetterFlush:    deadLetterFlush,
+		Digest:             digest,
+		ServeAddr:          serveAddr,
+		MsgFormat:          msgFormat,
+		CardSchema:         cardSchema,
+		Lang:               lang,
+		AppName:            appName,
+		DryRun:             dryRun,
+		DryRunIndent:       dryRunIndent,
+		Quiet:              quiet,
+		FailSilent:         failSilent,
+		SignalGrace:        signalGrace,
+		Output:             output,
+		MetricsFile:        src.get("FEISHU_METRICS_FILE"),
+		Layout:             layout,
+		ResultMode:         resultMode,
+		DebugRaw:           debugRaw,
+		HeadTailLines:      headTailLines,
+		CardTemplate:       cardTemplate,
+	}, nil
+}
+
+// parseRedirectPolicy 解析 FEISHU_FOLLOW_REDIRECTS, 兼容布尔写法
+func parseRedirectPolicy(raw string) (string, error) {
+	switch strings.ToLower(raw) {
+	case "", "0", "false", "no", redirectNone:
+		return redirectNone, nil
+	case redirectSameHost:
+		return redirectSameHost, nil
+	case "1", "true", "yes", redirectAll:
+		return redirectAll, nil
+	}
+	return "", fmt.Errorf("FEISHU_FOLLOW_REDIRECTS: unknown policy %q (want none, same-host or all)", raw)
+}
+
+// parseExtraHeaders 解析 FEISHU_EXTRA_HEADERS 等请求头配置 (env 为变量名, 用于警告): 以逗号或换行分隔的 "Key: Value",
+// 配置文件中也可写成 JSON 对象; 名称非法或缺少冒号的条目记录警告后跳过
+func parseExtraHeaders(env, raw string) http.Header {
+	if raw == "" {
+		return nil
+	}
+	var pairs [][2]string
+	var obj map[string]string
+	if strings.HasPrefix(raw, "{") && json.Unmarshal([]byte(raw), &obj) == nil {
+		for k, v := range obj {
+			pairs = append(pairs, [2]string{k, v})
+		}
+	} else {
+		for _, entry := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(entry, ":")
+			if !ok {
+				logger.Warn(env+": skipping entry without ':'", "entry", entry)
+				continue
+			}
+			pairs = append(pairs, [2]string{name, value})
+		}
+	}
+
+	headers := http.Header{}
+	for _, kv := range pairs {
+		name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !validHeaderName(name) || strings.ContainsAny(value, "\r\n") {
+			logger.Warn(env+": skipping invalid header", "name", name)
+			continue
+		}
+		headers.Add(name, value)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// validHeaderName 判断是否为合法的 HTTP 头名称 (RFC 7230 token)
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// configSource 按 "命令行参数 > 环境变量 > 配置文件 > 内置默认值" 的优先级提供配置项
+type configSource struct {
+	file map[string]string
+}
+
+// newConfigSource 读取配置文件, 文件不存在时视为空配置
+func newConfigSource() (configSource, error) {
+	p := configFilePath()
+	if p == "" {
+		return configSource{}, nil
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return configSource{}, nil
+	}
+	if err != nil {
+		return configSource{}, fmt.Errorf("read config file: %w", err)
+	}
+	parse := parseConfigFile
+	if strings.EqualFold(filepath.Ext(p), ".toml") {
+		parse = parseTOMLConfig
+	}
+	file, err := parse(data)
+	if err != nil {
+		return configSource{}, fmt.Errorf("parse config file %s: %w", p, err)
+	}
+	return configSource{file: file}, nil
+}
+
+// configFilePath 返回配置文件路径: FEISHU_CONFIG_FILE > $XDG_CONFIG_HOME > ~/.config
+// 默认目录下依次查找 config.json 与 config.toml, 都不存在时返回 config.json 的路径
+func configFilePath() string {
+	if p := strings.TrimSpace(os.Getenv("FEISHU_CONFIG_FILE")); p != "" {
+		return p
+	}
+	dir := strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME"))
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	jsonPath := filepath.Join(dir, "codex-feishu", "config.json")
+	if tomlPath := filepath.Join(dir, "codex-feishu", "config.toml"); !fileExists(jsonPath) && fileExists(tomlPath) {
+		return tomlPath
+	}
+	return jsonPath
+}
+
+// parseConfigFile 将 JSON 配置展开为字符串键值, 数组以逗号拼接, 对象保留原始 JSON
+func parseConfigFile(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch val := v.(type) {
+		case nil:
+			continue
+		case string:
+			out[k] = strings.TrimSpace(val)
+		case []interface{}:
+			items := make([]string, 0, len(val))
+			for _, item := range val {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[k] = strings.Join(items, ",")
+		case map[string]interface{}:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = string(b)
+		default:
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out, nil
+}
+
+// fileKey 将环境变量名映射为配置文件键名, 如 FEISHU_WEBHOOK_URL -> webhook_url
+func fileKey(env string) string {
+	return strings.ToLower(strings.TrimPrefix(env, "FEISHU_"))
+}
+
+// get 返回去除首尾空白后的配置值, 未配置时返回空字符串
+func (s configSource) get(env string) string {
+	if v := strings.TrimSpace(flagOverrides[env]); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+		return v
+	}
+	return s.file[fileKey(env)]
+}
+
+// bool 解析布尔型配置, 未设置时返回 false
+func (s configSource) bool(env string) (bool, error) {
+	return s.boolOr(env, false)
+}
+
+// boolOr 解析布尔型配置, 未设置时返回 def
+func (s configSource) boolOr(env string, def bool) (bool, error) {
+	raw := s.get(env)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid boolean %q", env, raw)
+	}
+	return v, nil
+}
+
+// int 解析非负整数配置
+func (s configSource) int(env string, def int) (int, error) {
+	raw := s.get(env)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%s: invalid non-negative integer %q", env, raw)
+	}
+	return v, nil
+}
+
+// ratio 解析 0 到 1 之间的比例配置, 未设置时返回 0
+func (s configSource) ratio(env string) (float64, error) {
+	raw := s.get(env)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 || v > 1 {
+		return 0, fmt.Errorf("%s: invalid ratio %q (want a number between 0 and 1)", env, raw)
+	}
+	return v, nil
+}
+
+// fileValue 读取 <env>_FILE 指向的文件 (如 FEISHU_SECRET_FILE), 去除首尾空白后返回, 避免密钥出现在环境变量与进程列表中。
+// 设置了 _FILE 时总是使用文件内容, 忽略 env 本身 (命令行参数、环境变量、.env 与配置文件);
+// 未设置 _FILE 时 fromFile 为 false, 由调用方读取 env
+func (s configSource) fileValue(env string) (value string, fromFile bool, err error) {
+	path := s.filePath(env)
+	if path == "" {
+		return "", false, nil
+	}
+	if strings.TrimSpace(flagOverrides[env]) != "" || (strings.TrimSpace(os.Getenv(env)) != "" && !dotEnvKeys[env]) {
+		logger.Warn("both a value and a file are configured, using the file", "var", env+"_FILE", "ignored", env)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", env, err)
+	}
+	value = strings.TrimSpace(string(data))
+	if value == "" {
+		return "", false, fmt.Errorf("%s_FILE: %s is empty", env, path)
+	}
+	return value, true, nil
+}
+
+// filePath 返回 <env>_FILE 配置的路径; 来自 .env 文件的 _FILE 不生效,
+// 避免工作目录中的 .env 让本工具读取任意文件并将其内容作为 Webhook 或密钥发送
+func (s configSource) filePath(env string) string {
+	name := env + "_FILE"
+	if v := strings.TrimSpace(flagOverrides[name]); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		if !dotEnvKeys[name] {
+			return v
+		}
+		logger.Warn("ignoring file reference from .env, set it in the environment instead", "var", name)
+	}
+	return s.file[fileKey(name)]
+}
+
+// list 按逗号拆分配置值
+func (s configSource) list(env string) []string {
+	return splitList(s.get(env))
+}
+
+// duration 解析非负的时长配置, 纯数字按秒处理
+func (s configSource) duration(env string, def time.Duration) (time.Duration, error) {
+	raw := s.get(env)
+	if raw == "" {
+		return def, nil
+	}
+	var d time.Duration
+	if secs, err := strconv.ParseFloat(raw, 64); err == nil && !math.IsNaN(secs) && !math.IsInf(secs, 0) {
+		d = time.Duration(secs * float64(time.Second))
+	} else if d, err = time.ParseDuration(raw); err != nil {
+		return 0, fmt.Errorf("%s: invalid duration %q", env, raw)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: duration must not be negative, got %q", env, raw)
+	}
+	return d, nil
+}
+
+// expandEnv 展开配置值中的 $VAR / ${VAR} 引用, 引用了未设置或为空的变量时报错
+func expandEnv(env, raw string) (string, error) {
+	if !strings.Contains(raw, "$") {
+		return raw, nil
+	}
+	var missing []string
+	expanded := os.Expand(raw, func(name string) string {
+		v := os.Getenv(name)
+		if v == "" {
+			missing = append(missing, "$"+name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%s: unresolved variable %s", env, strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// splitTargets 按逗号或空白 (含换行) 拆分 Webhook 与 Secret 列表
+func splitTargets(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+// splitList 按逗号拆分并去除空白项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
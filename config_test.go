@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile 在临时目录中写入文件并返回路径
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadTestDotEnv 按 content 写入 .env 文件并加载, 测试结束后删除其写入的变量
+func loadTestDotEnv(t *testing.T, content string) {
+	t.Helper()
+	t.Setenv("FEISHU_ENV_FILE", writeTestFile(t, ".env", content))
+	t.Cleanup(func() {
+		for name := range dotEnvKeys {
+			os.Unsetenv(name)
+		}
+	})
+	if err := loadDotEnv(); err != nil {
+		t.Fatalf("loadDotEnv: %v", err)
+	}
+}
+
+func TestSecretFromFile(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL_FILE": writeTestFile(t, "webhook", "https://open.feishu.cn/open-apis/bot/v2/hook/file\n"),
+		"FEISHU_SECRET_FILE":      writeTestFile(t, "secret", "  from-file\n"),
+	})
+	if cfg.WebhookURL != "https://open.feishu.cn/open-apis/bot/v2/hook/file" {
+		t.Errorf("WebhookURL = %q", cfg.WebhookURL)
+	}
+	if cfg.Secret != "from-file" {
+		t.Errorf("Secret = %q, want from-file", cfg.Secret)
+	}
+}
+
+func TestSecretFileBeatsEnv(t *testing.T) {
+	logs := captureLogger(t)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_SECRET":      "from-env",
+		"FEISHU_SECRET_FILE": writeTestFile(t, "secret", "from-file"),
+	})
+	if cfg.Secret != "from-file" {
+		t.Errorf("Secret = %q, want the file value", cfg.Secret)
+	}
+	if !strings.Contains(logs.String(), "using the file") {
+		t.Errorf("no warning about the ignored FEISHU_SECRET:\n%s", logs)
+	}
+}
+
+func TestWebhookFileBeatsEnv(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":      "https://open.feishu.cn/open-apis/bot/v2/hook/env",
+		"FEISHU_WEBHOOK_URL_FILE": writeTestFile(t, "webhook", "https://open.feishu.cn/open-apis/bot/v2/hook/file\n"),
+	})
+	if cfg.WebhookURL != "https://open.feishu.cn/open-apis/bot/v2/hook/file" {
+		t.Errorf("WebhookURL = %q, want the file value", cfg.WebhookURL)
+	}
+}
+
+func TestSecretFileBeatsFlag(t *testing.T) {
+	isolateEnv(t, map[string]string{"FEISHU_SECRET_FILE": writeTestFile(t, "secret", "from-file")})
+	flagOverrides["FEISHU_SECRET"] = "from-flag"
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Secret != "from-file" {
+		t.Errorf("Secret = %q, want the file value", cfg.Secret)
+	}
+}
+
+func TestSecretFileBeatsDotEnvValue(t *testing.T) {
+	isolateEnv(t, map[string]string{"FEISHU_SECRET_FILE": writeTestFile(t, "secret", "from-file")})
+	loadTestDotEnv(t, "FEISHU_SECRET=from-dotenv\n")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Secret != "from-file" {
+		t.Errorf("Secret = %q, want the file value", cfg.Secret)
+	}
+}
+
+func TestDotEnvFileReferenceIgnored(t *testing.T) {
+	isolateEnv(t, nil)
+	loadTestDotEnv(t, "FEISHU_SECRET_FILE="+writeTestFile(t, "secret", "from-file")+"\n")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Secret != "" {
+		t.Errorf("Secret = %q, want _FILE from .env to be ignored", cfg.Secret)
+	}
+}
+
+func TestSecretFileErrors(t *testing.T) {
+	for name, path := range map[string]string{
+		"missing": filepath.Join(t.TempDir(), "nope"),
+		"empty":   writeTestFile(t, "empty", "\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			isolateEnv(t, map[string]string{"FEISHU_SECRET_FILE": path})
+			_, err := loadConfig()
+			if err == nil || !strings.Contains(err.Error(), "FEISHU_SECRET_FILE") {
+				t.Errorf("loadConfig error = %v, want a FEISHU_SECRET_FILE error", err)
+			}
+		})
+	}
+}
+
+func TestNegativeDurationsRejected(t *testing.T) {
+	for _, env := range []string{"FEISHU_TIMEOUT", "FEISHU_RETRY_DELAY", "FEISHU_RETRY_MAX_DELAY", "FEISHU_SIGNAL_GRACE", "FEISHU_SEND_JITTER"} {
+		for _, raw := range []string{"-1s", "-2"} {
+			isolateEnv(t, map[string]string{env: raw})
+			_, err := loadConfig()
+			if err == nil || !strings.Contains(err.Error(), env) {
+				t.Errorf("%s=%s: error = %v, want a rejection", env, raw, err)
+			}
+		}
+	}
+}
+
+func TestDurationFormats(t *testing.T) {
+	for raw, want := range map[string]string{"1.5": "1.5s", "250ms": "250ms", "0": "0s"} {
+		cfg := testConfig(t, map[string]string{"FEISHU_TIMEOUT": raw})
+		if got := cfg.Timeout.String(); got != want {
+			t.Errorf("FEISHU_TIMEOUT=%s: got %s, want %s", raw, got, want)
+		}
+	}
+	isolateEnv(t, map[string]string{"FEISHU_TIMEOUT": "NaN"})
+	if _, err := loadConfig(); err == nil {
+		t.Error("FEISHU_TIMEOUT=NaN accepted")
+	}
+}
+
+func TestEscalateWebhookURLValidated(t *testing.T) {
+	isolateEnv(t, map[string]string{"FEISHU_ESCALATE_WEBHOOK_URL": "not a url"})
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "FEISHU_ESCALATE_WEBHOOK_URL") {
+		t.Errorf("loadConfig error = %v, want an invalid FEISHU_ESCALATE_WEBHOOK_URL", err)
+	}
+}
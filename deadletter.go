@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// deadLetter 是写入死信目录的记录
+type deadLetter struct {
+	FailedAt     time.Time         `json:"failed_at"`
+	Error        string            `json:"error"`
+	Notification CodexNotification `json:"notification"`
+}
+
+// writeDeadLetter 将发送失败的通知写入死信目录, 文件名唯一以支持并发运行
+func writeDeadLetter(dir string, n CodexNotification, sendErr error) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(deadLetter{
+		FailedAt:     time.Now().UTC(),
+		Error:        sendErr.Error(),
+		Notification: n,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	// 先写临时文件再 rename, 避免 replay 读到写了一半的文件
+	tmp, err := os.CreateTemp(dir, "notification-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	final := strings.TrimSuffix(tmp.Name(), ".tmp") + ".json"
+	if err := os.Rename(tmp.Name(), final); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return final, nil
+}
+
+// saveDeadLetter 在配置了死信目录时保存未送达的通知, 写入失败只记录日志
+func saveDeadLetter(cfg FeishuConfig, n CodexNotification, sendErr error) {
+	if cfg.DeadLetterDir == "" {
+		return
+	}
+	if file, err := writeDeadLetter(cfg.DeadLetterDir, n, sendErr); err != nil {
+		logger.Error("write dead letter", "err", err)
+	} else {
+		logger.Info("notification saved for replay", "turnID", n.TurnID, "file", file)
+	}
+}
+
+// runReplayCommand 重新发送死信目录中的通知 (replay 与 flush 子命令), 成功的记录会被删除, 返回进程退出码
+func runReplayCommand() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config error", "err", err)
+		return 1
+	}
+	attachRequestID(cfg)
+	if cfg.DeadLetterDir == "" {
+		logger.Error("FEISHU_DEADLETTER_DIR is not set")
+		return 1
+	}
+
+	sent, failed, err := replayDeadLetters(context.Background(), cfg, false)
+	if err != nil {
+		logger.Error("list dead letters", "err", err)
+		return 1
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "Replayed %d/%d dead letters\n", sent, sent+failed)
+		return 1
+	}
+	cfg.printf(os.Stdout, "Replayed %d/%d dead letters\n", sent, sent+failed)
+	return 0
+}
+
+// replayDeadLetters 依次重发死信目录中的全部通知, 返回成功与失败的数量;
+// stopOffline 为 true 时, 遇到网络错误等可重试的失败即停止, 余下的留待下次
+func replayDeadLetters(ctx context.Context, cfg FeishuConfig, stopOffline bool) (sent, failed int, err error) {
+	files, err := filepath.Glob(filepath.Join(cfg.DeadLetterDir, "notification-*.json"))
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := replayDeadLetter(ctx, file, cfg)
+		if err != nil {
+			logger.Error("replay failed", "file", file, "err", err)
+			failed++
+			if stopOffline && retryable(err) {
+				break
+			}
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, failed, nil
+}
+
+// flushDeadLetters 重发积压的死信, 供离线期间失败的通知补发; 只记录日志, 不影响本次运行的结果
+func flushDeadLetters(ctx context.Context, cfg FeishuConfig) {
+	if cfg.DeadLetterDir == "" || !cfg.DeadLetterFlush || cfg.DryRun {
+		return
+	}
+	sent, failed, err := replayDeadLetters(ctx, cfg, true)
+	if err != nil {
+		logger.Warn("list dead letters", "err", err)
+		return
+	}
+	if sent > 0 || failed > 0 {
+		logger.Info("flushed queued notifications", "sent", sent, "failed", failed)
+	}
+}
+
+// replayDeadLetter 认领并重发单个死信文件; 返回 false 表示文件已被其它进程认领
+func replayDeadLetter(ctx context.Context, file string, cfg FeishuConfig) (bool, error) {
+	// 通过 rename 认领文件, 防止并发 replay 重复发送
+	claimed := fmt.Sprintf("%s.replaying-%d", file, os.Getpid())
+	if err := os.Rename(file, claimed); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var dl deadLetter
+	data, err := os.ReadFile(claimed)
+	if err == nil {
+		err = json.Unmarshal(data, &dl)
+	}
+	if err == nil {
+		err = notify(ctx, cfg.routeFor(dl.Notification.Cwd), dl.Notification)
+	}
+	if err != nil {
+		// 归还文件, 留待下次重试
+		if rerr := os.Rename(claimed, file); rerr != nil {
+			logger.Warn("restore dead letter", "file", file, "err", rerr)
+		}
+		return false, err
+	}
+	return true, os.Remove(claimed)
+}
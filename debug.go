@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ================= 原始通知调试字段 =================
+// FEISHU_DEBUG_RAW 开启后, 卡片末尾附加 Codex 传入的原始通知 JSON (格式化后的代码块),
+// 用于排查卡片内容异常。通知中含有完整的输入与结果, 默认关闭, 避免泄露到共享群。
+
+// debugRawLimit 调试字段中 JSON 的最大字符数
+const debugRawLimit = 2000
+
+// rawNotificationJSON 返回格式化后的原始通知 JSON; 没有原始数据 (如从死信重放) 时序列化解析后的结构
+// 反引号转义为 \u0060, 仍是合法 JSON, 且不会提前结束代码块
+func rawNotificationJSON(n CodexNotification) string {
+	var b bytes.Buffer
+	if len(n.Raw) == 0 || json.Indent(&b, n.Raw, "", "  ") != nil {
+		b.Reset()
+		data, err := json.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return ""
+		}
+		b.Write(data)
+	}
+	return truncateRunes(strings.ReplaceAll(b.String(), "`", `\u0060`), debugRawLimit)
+}
+
+// debugCodeBlock 将原始通知渲染为 markdown 代码块
+func debugCodeBlock(n CodexNotification) string {
+	return fmt.Sprintf("```json\n%s\n```", rawNotificationJSON(n))
+}
+
+// debugElement 构建 1.0 卡片末尾的调试字段
+func debugElement(n CodexNotification, cfg FeishuConfig) FeishuMarkdown {
+	return FeishuMarkdown{Tag: "markdown", Content: fmt.Sprintf("**%s:**\n%s", cfg.t(msgLabelRaw), debugCodeBlock(n))}
+}
+
+// debugElementV2 构建 2.0 卡片末尾默认折叠的调试面板
+func debugElementV2(n CodexNotification, cfg FeishuConfig) FeishuCollapsiblePanel {
+	return FeishuCollapsiblePanel{
+		Tag:      "collapsible_panel",
+		Header:   FeishuPanelHeader{Title: FeishuText{Tag: "markdown", Content: fmt.Sprintf("**%s**", cfg.t(msgLabelRaw))}},
+		Elements: []interface{}{markdownV2(debugCodeBlock(n))},
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const debugInput = `{"type":"agent-turn-complete","thread-id":"thread-1","turn-id":"turn-1","cwd":"/work/demo",` +
+	`"input-messages":["run ` + "`go test`" + `"],"last-assistant-message":"said \"ok\"\n<done>"}`
+
+// debugBlock 返回卡片中调试字段的内容, 没有时返回空字符串
+func debugBlock(card FeishuCard) string {
+	for _, e := range card.Elements {
+		if md, ok := e.(FeishuMarkdown); ok && strings.Contains(md.Content, "```json") {
+			return md.Content
+		}
+	}
+	return ""
+}
+
+func TestDebugRawOnlyWhenEnabled(t *testing.T) {
+	notifications, _, err := parseNotifications(debugInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := notifications[0]
+
+	if block := debugBlock(buildCard(n, testConfig(t, nil), false)); block != "" {
+		t.Errorf("debug field present without FEISHU_DEBUG_RAW: %q", block)
+	}
+
+	cfg := testConfig(t, map[string]string{"FEISHU_DEBUG_RAW": "1"})
+	block := debugBlock(buildCard(n, cfg, false))
+	if block == "" {
+		t.Fatal("debug field missing with FEISHU_DEBUG_RAW=1")
+	}
+	_, code, _ := strings.Cut(block, "```json\n")
+	code = strings.TrimSuffix(code, "\n```")
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(code), &decoded); err != nil {
+		t.Fatalf("debug field is not valid JSON: %v\n%s", err, code)
+	}
+	if decoded["last-assistant-message"] != "said \"ok\"\n<done>" || decoded["input-messages"].([]interface{})[0] != "run `go test`" {
+		t.Errorf("decoded debug JSON = %v", decoded)
+	}
+	if strings.Count(block, "```") != 2 {
+		t.Error("backtick in the payload closes the code block early")
+	}
+
+	// 整张卡片仍可序列化为合法 JSON
+	if b, err := json.Marshal(buildCard(n, cfg, false)); err != nil || !json.Valid(b) {
+		t.Errorf("card with debug field does not marshal: %v", err)
+	}
+}
+
+func TestRawNotificationJSONLimit(t *testing.T) {
+	n := testNotification()
+	n.LastAssistantMessage = strings.Repeat("长", 3*debugRawLimit)
+	if got := len([]rune(rawNotificationJSON(n))); got > debugRawLimit {
+		t.Errorf("debug JSON is %d runes, want at most %d", got, debugRawLimit)
+	}
+	// 没有原始数据时序列化解析后的结构
+	if got := rawNotificationJSON(testNotification()); !json.Valid([]byte(got)) || !strings.Contains(got, "thread-1") {
+		t.Errorf("fallback debug JSON = %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ================= 摘要模式 =================
+// FEISHU_DIGEST=1 时, 通过各项过滤的通知不立即发送, 而是写入状态目录下的摘要队列 (digest/, 每条一个文件);
+// 由定时任务运行 digest 子命令, 将队列汇总为一张卡片发往飞书 Webhook,
+// 卡片头显示自上次摘要以来的任务数与失败数。全部 Webhook 发送成功后才删除已汇总的记录,
+// 汇总期间新入队的通知留待下一次。升级告警不进入队列, 照常立即发送。
+
+// digestListLimit 摘要卡片中逐条列出的任务数上限, 其余只计数
+const digestListLimit = 20
+
+// digestEntry 摘要队列中的一条记录
+type digestEntry struct {
+	QueuedAt     time.Time         `json:"queued_at"`
+	Failed       bool              `json:"failed"`
+	Notification CodexNotification `json:"notification"`
+}
+
+// digestState 摘要的发送记录
+type digestState struct {
+	SentAt time.Time `json:"sent_at"`
+}
+
+// digestDir 返回摘要队列目录
+func digestDir(cfg FeishuConfig) string {
+	return filepath.Join(stateDir(cfg), "digest")
+}
+
+// spoolDigest 将通知写入摘要队列; 文件名以入队时间开头, 按名称排序即为入队顺序
+func spoolDigest(cfg FeishuConfig, n CodexNotification, failed bool, now time.Time) error {
+	name := fmt.Sprintf("%020d-%s.json", now.UnixNano(), newRequestID()[:8])
+	return writeStateFile(filepath.Join(digestDir(cfg), name), digestEntry{QueuedAt: now.UTC(), Failed: failed, Notification: n})
+}
+
+// readDigestSpool 按入队顺序读取摘要队列, 返回记录与对应的文件; 无法解析的文件记录日志后跳过
+func readDigestSpool(cfg FeishuConfig) ([]digestEntry, []string, error) {
+	files, err := filepath.Glob(filepath.Join(digestDir(cfg), "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+	var entries []digestEntry
+	var read []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			logger.Warn("skipping unreadable digest entry", "file", file, "err", err)
+			continue
+		}
+		var e digestEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			logger.Warn("skipping corrupt digest entry", "file", file, "err", err)
+			continue
+		}
+		entries = append(entries, e)
+		read = append(read, file)
+	}
+	return entries, read, nil
+}
+
+// digestCounts 统计记录中的任务数与失败数
+func digestCounts(entries []digestEntry) (tasks, failures int) {
+	for _, e := range entries {
+		if e.Failed {
+			failures++
+		}
+	}
+	return len(entries), failures
+}
+
+// buildDigestCard 构建摘要卡片: 卡片头为任务数与失败数, 正文逐条列出任务, 底部注明统计的起始时间
+// since 为上次摘要的发送时间, 为零值时取最早一条记录的入队时间
+func buildDigestCard(entries []digestEntry, since time.Time, cfg FeishuConfig) FeishuCard {
+	tasks, failures := digestCounts(entries)
+	template := "green"
+	if failures > 0 {
+		template = "red"
+	}
+	var lines []string
+	for i, e := range entries {
+		if i == digestListLimit {
+			lines = append(lines, cfg.tf(msgDigestMore, len(entries)-digestListLimit))
+			break
+		}
+		mark := "✅"
+		if e.Failed {
+			mark = "❌"
+		}
+		line := fmt.Sprintf("%s **%s**", mark, truncateText(taskName(e.Notification, cfg), 30, truncateByRunes))
+		if cwd := strings.TrimSpace(e.Notification.Cwd); cwd != "" {
+			line += fmt.Sprintf(" · `%s`", cwd)
+		}
+		lines = append(lines, line)
+	}
+	if since.IsZero() && len(entries) > 0 {
+		since = entries[0].QueuedAt
+	}
+	return FeishuCard{
+		Config: FeishuCardConfig{WideScreenMode: true},
+		Header: FeishuHeader{
+			Title:    FeishuText{Tag: "plain_text", Content: cfg.tf(msgDigestTitle, cfg.AppName, tasks, failures)},
+			Template: template,
+		},
+		Elements: []interface{}{
+			larkMarkdownDiv(strings.Join(lines, "\n")),
+			FeishuNote{Tag: "note", Elements: []FeishuText{
+				{Tag: "plain_text", Content: cfg.tf(msgDigestSince, since.In(cfg.location()).Format("2006-01-02 15:04"))},
+			}},
+		},
+	}
+}
+
+// sendDigest 将摘要卡片发送到全部飞书 Webhook, 返回汇总的错误
+func sendDigest(ctx context.Context, cfg FeishuConfig, card FeishuCard) error {
+	targets := cfg.webhookTargets()
+	if len(targets) == 0 {
+		return errors.New("digest cards are sent to FEISHU_WEBHOOK_URL, which is not set")
+	}
+	var errs []error
+	for _, t := range targets {
+		c := cfg
+		c.WebhookURL, c.Secret, c.WebhookURLs, c.WebhookSecrets = t.URL, t.Secret, nil, nil
+		timestamp, sign, err := signRequest(c.Secret, time.Now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		msg := FeishuCardMsg{Timestamp: timestamp, Sign: sign, MsgType: "interactive", Card: card}
+		if c.DryRun {
+			errs = append(errs, writeDryRun(os.Stdout, msg, c.DryRunIndent, useColor(os.Stdout)))
+			continue
+		}
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if err := postWithRetry(ctx, newHTTPClient(c), c, payload); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", redactWebhook(t.URL), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runDigest 汇总摘要队列并发送, 成功后删除已汇总的记录并更新发送时间; 队列为空时不发送
+func runDigest(ctx context.Context, cfg FeishuConfig, now time.Time) (tasks, failures int, err error) {
+	entries, files, err := readDigestSpool(cfg)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read digest spool: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, 0, nil
+	}
+	statePath := filepath.Join(stateDir(cfg), "digest.json")
+	var last digestState
+	if err := readStateFile(statePath, &last); err != nil {
+		logger.Warn("read digest state", "err", err)
+	}
+	tasks, failures = digestCounts(entries)
+	if err := sendDigest(ctx, cfg, buildDigestCard(entries, last.SentAt, cfg)); err != nil {
+		return tasks, failures, err
+	}
+	if cfg.DryRun {
+		return tasks, failures, nil
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			logger.Warn("remove digest entry", "file", file, "err", err)
+		}
+	}
+	if err := writeStateFile(statePath, digestState{SentAt: now.UTC()}); err != nil {
+		logger.Warn("write digest state", "err", err)
+	}
+	return tasks, failures, nil
+}
+
+// runDigestCommand 运行 digest 子命令, 返回进程退出码
+func runDigestCommand() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config error", "err", err)
+		return 1
+	}
+	attachRequestID(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	tasks, failures, err := runDigest(ctx, cfg, time.Now())
+	if err != nil {
+		if cfg.FailSilent {
+			logger.Warn("send digest failed, exiting 0 because FEISHU_FAIL_SILENT is set", "err", err)
+			return 0
+		}
+		logger.Error("send digest failed", "err", err)
+		return 1
+	}
+	if tasks == 0 {
+		cfg.printf(os.Stderr, "Digest spool is empty\n")
+		return 0
+	}
+	cfg.printf(os.Stderr, "Sent digest of %d notifications (%d failed)\n", tasks, failures)
+	return 0
+}
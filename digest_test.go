@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// digestCardTitle 返回请求体中摘要卡片的标题
+func digestCardTitle(t *testing.T, body []byte) string {
+	t.Helper()
+	var msg struct {
+		Card FeishuCard `json:"card"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("decode digest card: %v", err)
+	}
+	return msg.Card.Header.Title.Content
+}
+
+func TestDigestCountsSinceLastDigest(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": srv.URL + "/hook",
+		"FEISHU_DIGEST":      "1",
+	})
+	ctx := context.Background()
+
+	report := processBatch(ctx, cfg, []CodexNotification{
+		turnNotification(1, false),
+		turnNotification(2, true),
+		turnNotification(3, false),
+	})
+	if report.Queued != 3 || report.Sent != 0 || len(*got) != 0 {
+		t.Fatalf("report = %+v with %d requests, want 3 queued and nothing sent", report, len(*got))
+	}
+	entries, _, err := readDigestSpool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks, failures := digestCounts(entries); tasks != 3 || failures != 1 {
+		t.Fatalf("spool counts = %d tasks, %d failures; want 3 and 1", tasks, failures)
+	}
+
+	tasks, failures, err := runDigest(ctx, cfg, time.Now())
+	if err != nil || tasks != 3 || failures != 1 {
+		t.Fatalf("runDigest = %d, %d, %v", tasks, failures, err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d digest requests, want 1", len(*got))
+	}
+	if title := digestCardTitle(t, (*got)[0].Body); !strings.Contains(title, "3 个任务, 1 个失败") {
+		t.Errorf("digest title = %q", title)
+	}
+
+	// 第二次摘要只统计上次摘要之后入队的通知
+	processBatch(ctx, cfg, []CodexNotification{turnNotification(4, true)})
+	if _, _, err := runDigest(ctx, cfg, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 2 {
+		t.Fatalf("got %d digest requests, want 2", len(*got))
+	}
+	if title := digestCardTitle(t, (*got)[1].Body); !strings.Contains(title, "1 个任务, 1 个失败") {
+		t.Errorf("second digest title = %q", title)
+	}
+
+	// 队列为空时不发送
+	if tasks, _, err := runDigest(ctx, cfg, time.Now()); err != nil || tasks != 0 || len(*got) != 2 {
+		t.Errorf("empty spool: tasks=%d err=%v requests=%d", tasks, err, len(*got))
+	}
+}
+
+func TestDigestKeepsSpoolOnFailure(t *testing.T) {
+	srv, _ := feishuStub(t, http.StatusOK, `{"code":9499,"msg":"Bad Request"}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": srv.URL + "/hook",
+		"FEISHU_DIGEST":      "1",
+		"FEISHU_MAX_RETRIES": "0",
+	})
+	processBatch(context.Background(), cfg, []CodexNotification{turnNotification(1, false)})
+	if _, _, err := runDigest(context.Background(), cfg, time.Now()); err == nil {
+		t.Fatal("runDigest succeeded against a rejecting webhook")
+	}
+	if entries, _, _ := readDigestSpool(cfg); len(entries) != 1 {
+		t.Errorf("spool has %d entries after a failed digest, want 1", len(entries))
+	}
+}
+
+func TestDigestEscalationBypassesSpool(t *testing.T) {
+	env, main, escalated := escalationEnv(t, 1)
+	env["FEISHU_DIGEST"] = "1"
+	cfg := testConfig(t, env)
+	rep, err := processNotification(context.Background(), cfg, turnNotification(1, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Outcome != outcomeSent || len(*escalated) != 1 || len(*main) != 0 {
+		t.Errorf("outcome=%v main=%d escalated=%d, want the escalation sent immediately", rep.Outcome, len(*main), len(*escalated))
+	}
+	if entries, _, _ := readDigestSpool(cfg); len(entries) != 0 {
+		t.Errorf("escalated failure was spooled")
+	}
+}
+
+func TestDigestCardListLimit(t *testing.T) {
+	cfg := testConfig(t, nil)
+	entries := make([]digestEntry, digestListLimit+5)
+	for i := range entries {
+		entries[i] = digestEntry{QueuedAt: time.Unix(1700000000, 0), Notification: turnNotification(i, false)}
+	}
+	entries[0].Failed = true
+	card := buildDigestCard(entries, time.Time{}, cfg)
+	if card.Header.Template != "red" {
+		t.Errorf("template = %q, want red with a failure", card.Header.Template)
+	}
+	div := card.Elements[0].(FeishuDiv)
+	lines := strings.Split(div.Text.Content, "\n")
+	if len(lines) != digestListLimit+1 || !strings.Contains(lines[digestListLimit], "另有 5 个任务") {
+		t.Errorf("got %d lines, last %q", len(lines), lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ================= 钉钉自定义机器人 sink =================
+// FEISHU_BACKEND=dingtalk 时改为发送到钉钉自定义机器人 (FEISHU_DINGTALK_WEBHOOK_URL),
+// 内容与飞书卡片相同, 以 markdown 消息发送。机器人开启了 "加签" 时设置 FEISHU_DINGTALK_SECRET:
+// 签名为 base64(hmac_sha256(key=secret, msg=timestamp+"\n"+secret)), timestamp 为毫秒,
+// 二者作为 timestamp 与 sign 查询参数附加到 Webhook 上。注意与飞书的算法不同 (飞书以拼接串为密钥)。
+
+const backendDingTalk = "dingtalk"
+
+// dingTalkRateLimitCodes 钉钉机器人的频率限制错误码 (每分钟最多 20 条)
+var dingTalkRateLimitCodes = map[int]bool{
+	130101: true, // send too fast
+}
+
+func init() {
+	registerBackend(backendDingTalk, func(cfg *FeishuConfig, webhook, secret string) {
+		cfg.DingTalkWebhookURL, cfg.DingTalkSecret = webhook, secret
+	})
+	registerNotifier("dingtalk", newDingTalkNotifier)
+}
+
+type dingTalkNotifier struct {
+	cfg    FeishuConfig
+	client doer
+	now    func() time.Time // 签名时间戳的时钟
+}
+
+func newDingTalkNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendDingTalk {
+		return nil, nil
+	}
+	if cfg.DingTalkWebhookURL == "" {
+		return nil, errors.New("FEISHU_BACKEND=dingtalk requires FEISHU_DINGTALK_WEBHOOK_URL")
+	}
+	if err := validateWebhookURL(cfg.DingTalkWebhookURL); err != nil {
+		return nil, fmt.Errorf("FEISHU_DINGTALK_WEBHOOK_URL: %w", err)
+	}
+	return []Notifier{&dingTalkNotifier{cfg: cfg, client: newHTTPClient(cfg), now: time.Now}}, nil
+}
+
+func (d *dingTalkNotifier) Name() string { return "dingtalk" }
+
+func (d *dingTalkNotifier) Target() string {
+	return redactQueryToken(d.cfg.DingTalkWebhookURL, "access_token")
+}
+
+// dingTalkMessage markdown 消息; title 只用于会话列表与通知预览
+type dingTalkMessage struct {
+	MsgType  string           `json:"msgtype"`
+	Markdown dingTalkMarkdown `json:"markdown"`
+}
+
+type dingTalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+func (d *dingTalkNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := d.cfg
+	msg, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		n, cfg, failed := prepareNotification(n, cfg)
+		return dingTalkMessage{
+			MsgType:  "markdown",
+			Markdown: dingTalkMarkdown{Title: cardTitle(n, cfg, failed), Text: markdownReport(n, cfg, failed)},
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	endpoint, err := signDingTalkURL(cfg.DingTalkWebhookURL, cfg.DingTalkSecret, d.now())
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, d.client, cfg, endpoint, d.Target(), nil, payload, errcodeChecker("dingtalk", dingTalkRateLimitCodes))
+}
+
+// dingTalkSign 计算钉钉加签: base64(hmac_sha256(key=secret, msg=timestamp+"\n"+secret)), timestamp 为毫秒
+func dingTalkSign(secret string, timestampMillis int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s", timestampMillis, secret)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signDingTalkURL 为 Webhook 附加 timestamp 与 sign 查询参数; secret 为空时原样返回
+// 钉钉要求时间戳与服务器时间相差不超过 1 小时, 重试沿用同一签名
+func signDingTalkURL(webhook, secret string, now time.Time) (string, error) {
+	if secret == "" {
+		return webhook, nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "", fmt.Errorf("FEISHU_DINGTALK_WEBHOOK_URL: %w", err)
+	}
+	ts := now.UnixMilli()
+	q := u.Query()
+	q.Set("timestamp", strconv.FormatInt(ts, 10))
+	q.Set("sign", dingTalkSign(secret, ts))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
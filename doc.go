@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ================= 完整记录归档到飞书云文档 (docx) =================
+// 仅自建应用方式可用: 以应用身份创建文档, 写入完整的输入与结果, 并在卡片中附上链接。
+
+const (
+	// docBlocksPerRequest 单次创建子块的数量上限
+	docBlocksPerRequest = 50
+
+	defaultDocBaseURL = "https://feishu.cn/docx/"
+)
+
+// docTextBlock 是 docx 文本块 (block_type=2)
+type docTextBlock struct {
+	BlockType int         `json:"block_type"`
+	Text      docTextBody `json:"text"`
+}
+
+type docTextBody struct {
+	Elements []docTextElement `json:"elements"`
+}
+
+type docTextElement struct {
+	TextRun docTextRun `json:"text_run"`
+}
+
+type docTextRun struct {
+	Content string `json:"content"`
+}
+
+// transcriptLines 生成归档文档的正文, 每行对应一个文本块
+func transcriptLines(n CodexNotification, cfg FeishuConfig) []string {
+	var lines []string
+	lines = append(lines, cfg.t(msgLabelInput)+":")
+	for _, msg := range n.InputMessages {
+		lines = append(lines, strings.Split(msg, "\n")...)
+	}
+	lines = append(lines, "", cfg.t(msgLabelResult)+":")
+	lines = append(lines, strings.Split(n.LastAssistantMessage, "\n")...)
+	lines = append(lines,
+		"",
+		fmt.Sprintf("%s: %s", cfg.t(msgLabelCwd), n.Cwd),
+		fmt.Sprintf("%s: %s", cfg.t(msgLabelThread), n.ThreadID),
+		fmt.Sprintf("Turn ID: %s", n.TurnID),
+	)
+	return lines
+}
+
+// createArchiveDoc 创建包含完整记录的云文档并返回访问链接
+func (a *appNotifier) createArchiveDoc(ctx context.Context, n CodexNotification) (string, error) {
+	title := fmt.Sprintf("%s · %s", cardTitle(n, a.cfg, false), time.Now().In(a.cfg.location()).Format("2006-01-02 15:04"))
+	body, err := json.Marshal(map[string]string{"title": title, "folder_token": a.cfg.DocFolderToken})
+	if err != nil {
+		return "", err
+	}
+	data, err := a.callWithToken(ctx, "POST", a.cfg.OpenAPIBase+"/open-apis/docx/v1/documents", body)
+	if err != nil {
+		return "", fmt.Errorf("create document: %w", err)
+	}
+	var created struct {
+		Document struct {
+			DocumentID string `json:"document_id"`
+		} `json:"document"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil || created.Document.DocumentID == "" {
+		return "", fmt.Errorf("create document: unexpected response %s", string(data))
+	}
+	docID := created.Document.DocumentID
+
+	// 文档根块的 block_id 与 document_id 相同
+	endpoint := fmt.Sprintf("%s/open-apis/docx/v1/documents/%s/blocks/%s/children",
+		a.cfg.OpenAPIBase, url.PathEscape(docID), url.PathEscape(docID))
+	lines := transcriptLines(n, a.cfg)
+	for start := 0; start < len(lines); start += docBlocksPerRequest {
+		end := start + docBlocksPerRequest
+		if end > len(lines) {
+			end = len(lines)
+		}
+		blocks := make([]docTextBlock, 0, end-start)
+		for _, line := range lines[start:end] {
+			blocks = append(blocks, docTextBlock{
+				BlockType: 2,
+				Text:      docTextBody{Elements: []docTextElement{{TextRun: docTextRun{Content: line}}}},
+			})
+		}
+		body, err := json.Marshal(map[string]interface{}{"children": blocks, "index": -1})
+		if err != nil {
+			return "", err
+		}
+		if _, err := a.callWithToken(ctx, "POST", endpoint, body); err != nil {
+			return "", fmt.Errorf("write document: %w", err)
+		}
+	}
+	return a.cfg.DocBaseURL + docID, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ================= .env 文件 =================
+// 启动时读取 FEISHU_ENV_FILE 指定的文件 (默认当前目录下的 .env), 将其中的变量写入进程环境,
+// 已存在的环境变量不会被覆盖。文件不存在时忽略。
+
+// dotEnvKeys 由 .env 文件写入进程环境的变量名, 供配置读取时区分来源
+var dotEnvKeys = map[string]bool{}
+
+// dotEnvPath 返回 .env 文件路径
+func dotEnvPath() string {
+	if p := strings.TrimSpace(os.Getenv("FEISHU_ENV_FILE")); p != "" {
+		return p
+	}
+	return ".env"
+}
+
+// loadDotEnv 读取 .env 文件并设置尚未存在的环境变量
+func loadDotEnv() error {
+	path := dotEnvPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	vars, err := parseDotEnv(data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, kv := range vars {
+		if _, set := os.LookupEnv(kv[0]); set {
+			continue
+		}
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return err
+		}
+		dotEnvKeys[kv[0]] = true
+	}
+	return nil
+}
+
+// parseDotEnv 解析 KEY=VALUE 行, 忽略空行与 # 注释, 支持 export 前缀与引号
+// 双引号内支持 \n \t \" \\ 转义, 单引号内按原样保留; 未加引号的值以 " #" 开始的部分视为注释
+func parseDotEnv(data []byte) ([][2]string, error) {
+	var vars [][2]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: want KEY=VALUE", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated double quote", lineNo)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	return vars, scanner.Err()
+}
+
+// closingQuote 返回双引号字符串中未转义的结束引号位置, 找不到时返回 -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	ansiKey   = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// writeDryRun 以缩进格式输出卡片 JSON, color 为 true 时为键名着色
+func writeDryRun(w io.Writer, v interface{}, indent int, color bool) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent > 0 {
+		enc.SetIndent("", strings.Repeat(" ", indent))
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if color {
+		out = colorizeJSONKeys(out)
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// colorizeJSONKeys 为 JSON 文本中的对象键名加上 ANSI 颜色
+func colorizeJSONKeys(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			out.WriteByte(data[i])
+			continue
+		}
+		// 找到字符串结尾, 跳过转义字符
+		end := i + 1
+		for end < len(data) && data[end] != '"' {
+			if data[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(data) {
+			out.Write(data[i:])
+			break
+		}
+		next := end + 1
+		for next < len(data) && (data[next] == ' ' || data[next] == '\t' || data[next] == '\n' || data[next] == '\r') {
+			next++
+		}
+		if next < len(data) && data[next] == ':' {
+			out.WriteString(ansiKey)
+			out.Write(data[i : end+1])
+			out.WriteString(ansiReset)
+		} else {
+			out.Write(data[i : end+1])
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// useColor 判断是否对输出着色: 仅在终端中且未设置 NO_COLOR 时启用
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal 判断文件是否为字符设备 (终端)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteDryRunIndent(t *testing.T) {
+	v := map[string]interface{}{"msg_type": "interactive", "card": map[string]string{"a": "<b>"}}
+	tests := []struct {
+		indent int
+		want   string
+	}{
+		{0, `{"card":{"a":"<b>"},"msg_type":"interactive"}` + "\n"},
+		{2, "{\n  \"card\": {\n    \"a\": \"<b>\"\n  },\n  \"msg_type\": \"interactive\"\n}\n"},
+		{4, "{\n    \"card\": {\n        \"a\": \"<b>\"\n    },\n    \"msg_type\": \"interactive\"\n}\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeDryRun(&buf, v, tt.indent, false); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("indent %d:\n%s\nwant:\n%s", tt.indent, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestColorizeJSONKeys(t *testing.T) {
+	in := `{"key": "value: \"quoted\"", "list": ["a", "b:"], "n": {"inner":1}}`
+	got := string(colorizeJSONKeys([]byte(in)))
+	want := `{` + ansiKey + `"key"` + ansiReset + `: "value: \"quoted\"", ` +
+		ansiKey + `"list"` + ansiReset + `: ["a", "b:"], ` +
+		ansiKey + `"n"` + ansiReset + `: {` + ansiKey + `"inner"` + ansiReset + `:1}}`
+	if got != want {
+		t.Errorf("colorizeJSONKeys:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+// 输出不是终端时不着色
+func TestDryRunNoColorWhenNotTTY(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	t.Setenv("NO_COLOR", "")
+	if useColor(w) {
+		t.Error("useColor is true for a pipe")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if useColor(f) {
+		t.Error("useColor is true for a regular file")
+	}
+
+	var buf bytes.Buffer
+	if err := writeDryRun(&buf, map[string]string{"a": "b"}, 2, useColor(w)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("dry-run output to a pipe contains ANSI codes: %q", buf.String())
+	}
+}
+
+func TestNoColorEnv(t *testing.T) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("no controlling terminal:", err)
+	}
+	defer tty.Close()
+	t.Setenv("NO_COLOR", "1")
+	if useColor(tty) {
+		t.Error("useColor is true with NO_COLOR set")
+	}
+}
+
+func TestDryRunIndentConfig(t *testing.T) {
+	if cfg := testConfig(t, nil); cfg.DryRunIndent != 2 {
+		t.Errorf("default indent = %d, want 2", cfg.DryRunIndent)
+	}
+	if cfg := testConfig(t, map[string]string{"FEISHU_DRY_RUN_INDENT": "0"}); cfg.DryRunIndent != 0 {
+		t.Errorf("FEISHU_DRY_RUN_INDENT=0: indent = %d", cfg.DryRunIndent)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// ================= 编辑窗口: 更新而非重复发送 =================
+// 自建应用方式下, 同一 turn-id 的新通知若在 FEISHU_EDIT_WINDOW 内到达,
+// 则通过 PATCH /open-apis/im/v1/messages/{message_id} 更新已发送的卡片, 保持群聊整洁。
+
+// sentMessage 记录 turn-id 最近一次发送的消息
+type sentMessage struct {
+	MessageID string `json:"message_id"`
+	SentAt    int64  `json:"sent_at"`
+}
+
+func (a *appNotifier) messagesPath() string {
+	return filepath.Join(stateDir(a.cfg), "messages.json")
+}
+
+// loadSentMessages 读取已发送消息记录, 并清理超出编辑窗口的条目
+func (a *appNotifier) loadSentMessages(now time.Time) map[string]sentMessage {
+	sent := map[string]sentMessage{}
+	if err := readStateFile(a.messagesPath(), &sent); err != nil {
+		logger.Debug("ignoring unreadable message cache", "err", err)
+		return map[string]sentMessage{}
+	}
+	cutoff := now.Add(-a.cfg.EditWindow).Unix()
+	for id, m := range sent {
+		if m.SentAt < cutoff {
+			delete(sent, id)
+		}
+	}
+	return sent
+}
+
+// updateRecent 若 turn-id 在编辑窗口内已发送过卡片, 则原地更新, 返回是否已更新
+func (a *appNotifier) updateRecent(ctx context.Context, turnID string, cardJSON []byte) (bool, error) {
+	if a.cfg.EditWindow <= 0 || turnID == "" {
+		return false, nil
+	}
+	prev, ok := a.loadSentMessages(time.Now())[turnID]
+	if !ok {
+		return false, nil
+	}
+	body, err := json.Marshal(map[string]string{"content": string(cardJSON)})
+	if err != nil {
+		return false, err
+	}
+	endpoint := fmt.Sprintf("%s/open-apis/im/v1/messages/%s", a.cfg.OpenAPIBase, url.PathEscape(prev.MessageID))
+	err = withRetry(ctx, a.cfg, func() error {
+		_, err := a.callWithToken(ctx, "PATCH", endpoint, body)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	logger.Info("updated previous card", "turnID", turnID, "messageID", prev.MessageID)
+	return true, nil
+}
+
+// rememberMessage 记录新发送卡片的 message_id; 编辑窗口内的更新不刷新发送时间,
+// 因此窗口始终从第一张卡片发送时起算
+func (a *appNotifier) rememberMessage(turnID string, data json.RawMessage) {
+	if a.cfg.EditWindow <= 0 || turnID == "" {
+		return
+	}
+	var resp struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.MessageID == "" {
+		logger.Debug("send response has no message_id", "data", string(data))
+		return
+	}
+	now := time.Now()
+	sent := a.loadSentMessages(now)
+	sent[turnID] = sentMessage{MessageID: resp.MessageID, SentAt: now.Unix()}
+	if err := writeStateFile(a.messagesPath(), sent); err != nil {
+		logger.Warn("record sent message", "err", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pathMessageOM1 = "/open-apis/im/v1/messages/om_1"
+
+// editStub 模拟发送与更新消息接口, 新消息的 message_id 为 om_1
+func editStub(t *testing.T, patchResp string) (*appNotifier, *[]openAPICall) {
+	t.Helper()
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathMessages:    `{"code":0,"data":{"message_id":"om_1"}}`,
+		"PATCH " + pathMessageOM1: patchResp,
+	})
+	return newTestAppNotifier(t, srv, map[string]string{"FEISHU_EDIT_WINDOW": "10m"}), calls
+}
+
+func TestEditWithinWindow(t *testing.T) {
+	a, calls := editStub(t, `{"code":0,"data":{}}`)
+	first := testNotification()
+	second := testNotification()
+	second.LastAssistantMessage = "all tests passed after the retry"
+	for _, n := range []CodexNotification{first, second} {
+		if err := a.Send(context.Background(), n); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	posts := callsTo(*calls, "POST", pathMessages)
+	patches := callsTo(*calls, "PATCH", pathMessageOM1)
+	if len(posts) != 1 || len(patches) != 1 {
+		t.Fatalf("got %d sends and %d updates, want 1 and 1", len(posts), len(patches))
+	}
+	// 只有共享卡片才能被更新
+	if !strings.Contains(string(posts[0].Body), `\"update_multi\":true`) {
+		t.Errorf("first card is not shared: %s", posts[0].Body)
+	}
+	if !strings.Contains(string(patches[0].Body), "after the retry") {
+		t.Errorf("update does not carry the new card: %s", patches[0].Body)
+	}
+}
+
+func TestEditPastWindow(t *testing.T) {
+	a, calls := editStub(t, `{"code":0,"data":{}}`)
+	n := testNotification()
+	stale := map[string]sentMessage{n.TurnID: {MessageID: "om_1", SentAt: time.Now().Add(-11 * time.Minute).Unix()}}
+	if err := writeStateFile(a.messagesPath(), stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(context.Background(), n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if posts, patches := callsTo(*calls, "POST", pathMessages), callsTo(*calls, "PATCH", pathMessageOM1); len(posts) != 1 || len(patches) != 0 {
+		t.Errorf("got %d sends and %d updates, want a new card", len(posts), len(patches))
+	}
+	if sent := a.loadSentMessages(time.Now()); sent[n.TurnID].SentAt < time.Now().Add(-time.Minute).Unix() {
+		t.Errorf("new card not recorded: %+v", sent)
+	}
+}
+
+// 更新失败 (如消息已被撤回) 时退回发送新卡片
+func TestEditUpdateFailureSendsNew(t *testing.T) {
+	a, calls := editStub(t, `{"code":230011,"msg":"the message is recalled"}`)
+	for i := 0; i < 2; i++ {
+		if err := a.Send(context.Background(), testNotification()); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if posts := callsTo(*calls, "POST", pathMessages); len(posts) != 2 {
+		t.Errorf("got %d sends, want 2 after the failed update", len(posts))
+	}
+}
+
+func TestEditWindowDisabled(t *testing.T) {
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathMessages: `{"code":0,"data":{"message_id":"om_1"}}`,
+	})
+	a := newTestAppNotifier(t, srv, nil)
+	for i := 0; i < 2; i++ {
+		if err := a.Send(context.Background(), testNotification()); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if posts := callsTo(*calls, "POST", pathMessages); len(posts) != 2 || len(*calls) != 2 {
+		t.Errorf("calls = %+v, want two new cards", *calls)
+	}
+	if _, err := os.Stat(a.messagesPath()); !os.IsNotExist(err) {
+		t.Errorf("message cache written without FEISHU_EDIT_WINDOW: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================= SMTP 邮件 sink =================
+// FEISHU_BACKEND=email 时通过 SMTP 发送一封 HTML 邮件 (附纯文本版本), 内容与飞书卡片相同, 适合隔夜的长任务。
+// 端口 465 使用隐式 TLS, 其它端口在服务器支持时升级 STARTTLS; 配置了用户名时使用 PLAIN 认证,
+// net/smtp 拒绝在未加密的连接上发送密码 (localhost 除外)。
+// SMTP 的 4xx 回复 (灰名单、限流等) 与连接错误按 FEISHU_MAX_RETRIES 重试。
+
+const backendEmail = "email"
+
+const (
+	defaultSMTPPort = 587
+	smtpsPort       = 465 // 隐式 TLS 端口
+)
+
+func init() {
+	registerBackend(backendEmail, nil)
+	registerNotifier("email", newEmailNotifier)
+}
+
+type emailNotifier struct {
+	cfg  FeishuConfig
+	from *mail.Address
+	to   []*mail.Address
+}
+
+func newEmailNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendEmail {
+		return nil, nil
+	}
+	if cfg.SMTPHost == "" || len(cfg.SMTPTo) == 0 {
+		return nil, errors.New("FEISHU_BACKEND=email requires FEISHU_SMTP_HOST and FEISHU_SMTP_TO")
+	}
+	rawFrom := cfg.SMTPFrom
+	if rawFrom == "" {
+		rawFrom = cfg.SMTPUsername
+	}
+	if rawFrom == "" {
+		return nil, errors.New("FEISHU_BACKEND=email requires FEISHU_SMTP_FROM (or FEISHU_SMTP_USERNAME)")
+	}
+	from, err := mail.ParseAddress(rawFrom)
+	if err != nil {
+		return nil, fmt.Errorf("FEISHU_SMTP_FROM: %w", err)
+	}
+	e := &emailNotifier{cfg: cfg, from: from}
+	for _, raw := range cfg.SMTPTo {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil {
+			return nil, fmt.Errorf("FEISHU_SMTP_TO: %q: %w", raw, err)
+		}
+		e.to = append(e.to, addr)
+	}
+	return []Notifier{e}, nil
+}
+
+func (e *emailNotifier) Name() string { return "email" }
+
+func (e *emailNotifier) Target() string {
+	return "smtp://" + net.JoinHostPort(e.cfg.SMTPHost, strconv.Itoa(e.cfg.SMTPPort))
+}
+
+// emailPreview FEISHU_DRY_RUN 时输出的邮件内容
+type emailPreview struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	HTML    string   `json:"html"`
+}
+
+func (e *emailNotifier) Send(ctx context.Context, n CodexNotification) error {
+	n, cfg, failed := prepareNotification(n, e.cfg)
+	subject := cardTitle(n, cfg, failed)
+	body := buildEmailHTML(n, cfg, failed)
+	if cfg.DryRun {
+		preview := emailPreview{From: e.from.String(), Subject: subject, HTML: body}
+		for _, addr := range e.to {
+			preview.To = append(preview.To, addr.String())
+		}
+		return writeDryRun(os.Stdout, preview, cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	msg, err := e.buildMessage(subject, markdownReport(n, cfg, failed), body, idempotencyKey(n), time.Now())
+	if err != nil {
+		return err
+	}
+	return withRetry(ctx, cfg, func() error {
+		logger.Debug("sending notification", "endpoint", e.Target(), "bytes", len(msg))
+		runMetrics.attempts.Add(1)
+		runMetrics.bytesSent.Add(int64(len(msg)))
+		return e.deliver(ctx, msg)
+	})
+}
+
+// buildMessage 组装 multipart/alternative 邮件: 纯文本与 HTML 两个版本, 均为 quoted-printable 编码
+// key 非空时用作 Message-ID, 同一 turn 的重复发送可被邮件客户端识别
+func (e *emailNotifier) buildMessage(subject, text, htmlBody, key string, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	to := make([]string, len(e.to))
+	for i, addr := range e.to {
+		to[i] = addr.String()
+	}
+	var msg bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&msg, "%s: %s\r\n", name, value) }
+	header("From", e.from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	if key != "" {
+		header("Message-ID", fmt.Sprintf("<%s@codex-notify>", key))
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// deliver 建立一次 SMTP 会话发送邮件; 整个会话受 FEISHU_TIMEOUT 与 ctx 的截止时间约束
+func (e *emailNotifier) deliver(ctx context.Context, msg []byte) error {
+	host := e.cfg.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(e.cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: e.cfg.Timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if e.cfg.SMTPPort == smtpsPort {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if e.cfg.Timeout > 0 && (!ok || time.Now().Add(e.cfg.Timeout).Before(deadline)) {
+		deadline, ok = time.Now().Add(e.cfg.Timeout), true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if e.cfg.SMTPPort != smtpsPort {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if e.cfg.SMTPUsername != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.SMTPUsername, e.cfg.SMTPPassword, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range e.to {
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailHeaderColors 飞书卡片头颜色模板对应的邮件标题栏颜色
+var emailHeaderColors = map[string]string{
+	"blue":      "#3370ff",
+	"wathet":    "#4aa3f2",
+	"turquoise": "#14c0b8",
+	"green":     "#34c724",
+	"yellow":    "#d9a400",
+	"orange":    "#ff8800",
+	"red":       "#f54a45",
+	"carmine":   "#e0316c",
+	"violet":    "#b550e0",
+	"purple":    "#7f3bf5",
+	"indigo":    "#4954e6",
+	"grey":      "#8f959e",
+}
+
+// buildEmailHTML 按飞书卡片的内容顺序生成 HTML 邮件正文, 标题栏颜色与卡片头一致
+func buildEmailHTML(n CodexNotification, cfg FeishuConfig, failed bool) string {
+	color, ok := emailHeaderColors[cfg.styleFor(n, failed).Template]
+	if !ok {
+		color = "#646a73"
+	}
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><body style="margin:0;font-family:-apple-system,'Segoe UI',Helvetica,Arial,sans-serif;font-size:14px;color:#1f2329">`)
+	fmt.Fprintf(&b, `<div style="background:%s;color:#fff;padding:12px 16px;font-size:18px;font-weight:bold">%s</div>`, color, html.EscapeString(cardTitle(n, cfg, failed)))
+	b.WriteString(`<div style="padding:4px 16px 16px">`)
+	if cfg.Escalated {
+		fmt.Fprintf(&b, `<p><strong>%s</strong></p>`, html.EscapeString(cfg.t(msgEscalation)))
+	}
+	section := func(label, content string) {
+		fmt.Fprintf(&b, `<h3 style="margin:16px 0 8px">%s</h3><div>%s</div>`, html.EscapeString(label), htmlMarkdown(content))
+	}
+	section(cfg.t(msgLabelInput), inputText(n, cfg, "\n"))
+	if label, text := eventBody(n, cfg, failed); label != "" {
+		section(cfg.t(label), text)
+		if cfg.PayloadTrimmed {
+			fmt.Fprintf(&b, `<p><em>%s</em></p>`, html.EscapeString(cfg.t(msgTrimmed)))
+		}
+		for _, link := range resultLinks(cfg) {
+			fmt.Fprintf(&b, `<p>%s</p>`, htmlMarkdown(link))
+		}
+	}
+	b.WriteString(`<hr style="border:none;border-top:1px solid #dee0e3;margin:16px 0"><table style="border-collapse:collapse">`)
+	for _, f := range cardFields(n, cfg) {
+		fmt.Fprintf(&b, `<tr><th style="text-align:left;padding:2px 16px 2px 0;white-space:nowrap">%s</th><td>%s</td></tr>`, html.EscapeString(f.Label), htmlMarkdown(f.Value))
+	}
+	b.WriteString(`</table>`)
+	var footer []string
+	for _, t := range buildFooter(n, cfg, time.Now()).Elements {
+		footer = append(footer, html.EscapeString(t.Content))
+	}
+	if len(footer) > 0 {
+		fmt.Fprintf(&b, `<p style="color:#8f959e;font-size:12px">%s</p>`, strings.Join(footer, " · "))
+	}
+	if cfg.DebugRaw {
+		section(cfg.t(msgLabelRaw), debugCodeBlock(n))
+	}
+	b.WriteString(`</div></body></html>`)
+	return b.String()
+}
+
+// markdownCode 行内代码
+var markdownCode = regexp.MustCompile("`([^`\n]+)`")
+
+// htmlMarkdown 将卡片中使用的 Markdown 转为 HTML: 代码块、行内代码、粗体与 http(s) 链接保留, 其余文本转义
+func htmlMarkdown(s string) string {
+	var parts []string
+	for _, seg := range splitFencedCode(s) {
+		if seg.Code {
+			parts = append(parts, `<pre style="background:#f5f6f7;padding:8px;overflow:auto"><code>`+html.EscapeString(seg.Text)+`</code></pre>`)
+			continue
+		}
+		text := html.EscapeString(seg.Text)
+		text = markdownCode.ReplaceAllString(text, `<code style="background:#f5f6f7">$1</code>`)
+		text = markdownBold.ReplaceAllString(text, "<strong>$1</strong>")
+		text = markdownLink.ReplaceAllStringFunc(text, func(m string) string {
+			sub := markdownLink.FindStringSubmatch(m)
+			if !strings.HasPrefix(sub[2], "https://") && !strings.HasPrefix(sub[2], "http://") {
+				return m
+			}
+			return fmt.Sprintf(`<a href="%s">%s</a>`, sub[2], sub[1])
+		})
+		parts = append(parts, strings.ReplaceAll(text, "\n", "<br>\n"))
+	}
+	return strings.Join(parts, "\n")
+}
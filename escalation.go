@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// detectFailure 判断任务是否失败: 通知带有 status 字段时以其为准;
+// 否则看 Codex 是否设置了 CODEX_ERROR, 或执行结果中是否出现 FEISHU_FAILURE_KEYWORDS 中的关键词。
+// 关键词默认为空: "fixed the error handling"、"no tests failed" 这类正常结果不应被判为失败
+func detectFailure(n CodexNotification, cfg FeishuConfig) bool {
+	switch normalizeStatus(n.Status) {
+	case statusError:
+		return true
+	case statusSuccess, statusCancelled:
+		return false
+	}
+	if cfg.CodexError != "" {
+		return true
+	}
+	result := strings.ToLower(n.LastAssistantMessage)
+	for _, kw := range cfg.FailureKeywords {
+		if containsKeyword(result, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsKeyword 判断 text 中是否出现 kw; 以字母或数字开头/结尾的关键词需落在单词边界上,
+// 如 error 不匹配 errors、ErrorBoundary 或 terrors, 中文等没有空格分词的关键词按子串匹配
+func containsKeyword(text, kw string) bool {
+	if kw == "" {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(kw)
+	last, _ := utf8.DecodeLastRuneInString(kw)
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], kw)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(kw)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !(isWordRune(first) && isWordRune(before)) && !(isWordRune(last) && isWordRune(after)) {
+			return true
+		}
+		offset = start + len(string(first))
+	}
+}
+
+// isWordRune 判断 r 是否为构成英文单词的字符; 中文等表意文字不算, 以便按子串匹配
+func isWordRune(r rune) bool {
+	return r == '_' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// emptyResultPlaceholder 返回执行结果为空时的占位文本, 失败时优先展示 CODEX_ERROR 中的原因
+func emptyResultPlaceholder(failed bool, cfg FeishuConfig) string {
+	if !failed {
+		return cfg.t(msgEmptyResult)
+	}
+	if cfg.CodexError != "" {
+		return cfg.tf(msgFailureReason, cfg.CodexError)
+	}
+	if cfg.FailurePlaceholder != "" {
+		return cfg.FailurePlaceholder
+	}
+	return cfg.t(msgFailureEmpty)
+}
+
+// stateDir 返回存放运行状态文件的目录, 默认为用户缓存目录 (如 ~/.cache/codex-feishu)。
+// 目录中有 tenant_access_token 缓存, 不能放在其他本地用户可以抢先创建的系统临时目录下
+func stateDir(cfg FeishuConfig) string {
+	if cfg.StateDir != "" {
+		return cfg.StateDir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "codex-feishu")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("codex-feishu-%d", os.Getuid()))
+}
+
+// ensureStateDir 以 0700 权限创建状态目录, 并拒绝不安全的已有目录, 见 checkStateDir
+func ensureStateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return checkStateDir(dir)
+}
+
+// checkStateDir 拒绝属于其他用户或组/其他用户可写的状态目录, 避免他人读取或植入令牌与缓存; 目录不存在时返回 nil
+func checkStateDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("state directory %s is not a directory", dir)
+	}
+	return checkDirPrivate(dir, info)
+}
+
+// readStateFile 读取 JSON 状态文件, 文件不存在时保持 v 不变
+func readStateFile(path string, v interface{}) error {
+	if err := checkStateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeStateFile 通过临时文件 + rename 原子写入 JSON 状态文件
+func writeStateFile(path string, v interface{}) error {
+	if err := ensureStateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 状态文件锁: 并发的 hook 调用各自读取、修改再写回同一状态文件时, 临时文件 + rename 只保证文件完整,
+// 后写入者仍会覆盖先写入者的更新。读改写期间独占创建 <path>.lock, 超过 stateLockStale 的锁视为持有者已退出
+const (
+	stateLockTimeout = 5 * time.Second
+	stateLockStale   = 30 * time.Second
+)
+
+// lockStateFile 获取状态文件锁, 返回释放函数
+func lockStateFile(path string) (func(), error) {
+	if err := ensureStateDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	lock := path + ".lock"
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > stateLockStale {
+			logger.Warn("removing stale state lock", "lock", lock)
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another process", filepath.Base(path))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// updateStateFile 持有文件锁读取状态文件到 v, 调用 update 修改后写回
+func updateStateFile(path string, v interface{}, update func()) error {
+	unlock, err := lockStateFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := readStateFile(path, v); err != nil {
+		return err
+	}
+	update()
+	return writeStateFile(path, v)
+}
+
+// 失败计数按最近一次失败的时间清理: 超过 failureCountTTL 未再失败的目录不再计数,
+// 目录数超过 maxFailureCounts 时丢弃最久未失败的记录, 避免 failures.json 随工作路径无限增长
+const (
+	failureCountTTL  = 7 * 24 * time.Hour
+	maxFailureCounts = 256
+)
+
+// failureCount 某个工作路径的连续失败次数与最近一次失败的时间
+type failureCount struct {
+	Count  int   `json:"count"`
+	LastAt int64 `json:"last_at"`
+}
+
+// UnmarshalJSON 兼容旧版只记录次数的格式; 旧记录没有时间, 除非再次失败, 写回时按过期清理
+func (c *failureCount) UnmarshalJSON(data []byte) error {
+	if n, err := strconv.Atoi(string(data)); err == nil {
+		*c = failureCount{Count: n}
+		return nil
+	}
+	type plain failureCount
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// recordOutcome 更新 cwd 对应的连续失败次数并返回更新后的值, 成功时清零
+func recordOutcome(cfg FeishuConfig, cwd string, failed bool) (int, error) {
+	path := filepath.Join(stateDir(cfg), "failures.json")
+	now := time.Now()
+	counts := map[string]failureCount{}
+	err := updateStateFile(path, &counts, func() {
+		if failed {
+			counts[cwd] = failureCount{Count: counts[cwd].Count + 1, LastAt: now.Unix()}
+		} else {
+			delete(counts, cwd)
+		}
+		pruneFailureCounts(counts, now)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("update failure counter: %w", err)
+	}
+	return counts[cwd].Count, nil
+}
+
+// pruneFailureCounts 清理过期的失败计数, 并将记录数限制在 maxFailureCounts 以内
+func pruneFailureCounts(counts map[string]failureCount, now time.Time) {
+	cutoff := now.Add(-failureCountTTL).Unix()
+	for cwd, c := range counts {
+		if c.LastAt < cutoff {
+			delete(counts, cwd)
+		}
+	}
+	if len(counts) <= maxFailureCounts {
+		return
+	}
+	cwds := make([]string, 0, len(counts))
+	for cwd := range counts {
+		cwds = append(cwds, cwd)
+	}
+	sort.Slice(cwds, func(i, j int) bool { return counts[cwds[i]].LastAt < counts[cwds[j]].LastAt })
+	for _, cwd := range cwds[:len(cwds)-maxFailureCounts] {
+		delete(counts, cwd)
+	}
+}
+
+// escalate 返回用于升级告警的配置副本: 切换到升级 Webhook (如有) 并标记卡片为紧急
+func (cfg FeishuConfig) escalate() FeishuConfig {
+	if cfg.EscalateWebhookURL != "" {
+		// 路由规则可能切换了后端, 升级告警总是发往飞书 Webhook
+		cfg.Backend = backendWebhook
+		cfg.WebhookURL = cfg.EscalateWebhookURL
+		cfg.Secret = cfg.EscalateSecret
+		cfg.WebhookURLs, cfg.WebhookSecrets = nil, nil
+	}
+	cfg.Escalated = true
+	return cfg
+}
+
+// ================= 失败通知路由 =================
+// 配置 FEISHU_FAILURE_WEBHOOK_URL 后, 判定为失败的通知改发到该 Webhook (如值班群),
+// 开启 FEISHU_FAILURE_ALSO_DEFAULT 时原有 sink 照常发送, 失败 Webhook 额外收到一份。
+
+// failureNotifier 是发往失败 Webhook 的 sink, 与普通 Webhook 区分名称以便在结果中识别
+type failureNotifier struct {
+	*feishuNotifier
+}
+
+func (f failureNotifier) Name() string { return "feishu-failure" }
+
+// routeFailure 决定失败通知的发送目标; 未配置失败 Webhook、任务成功,
+// 或升级告警已切换到专用 Webhook 时, 原样返回 notifiers
+func routeFailure(cfg FeishuConfig, failed bool, notifiers []Notifier) []Notifier {
+	if !failed || cfg.FailureWebhookURL == "" || (cfg.Escalated && cfg.EscalateWebhookURL != "") {
+		return notifiers
+	}
+	failureCfg := cfg
+	failureCfg.Backend = backendWebhook
+	failureCfg.WebhookURL = cfg.FailureWebhookURL
+	failureCfg.Secret = cfg.FailureSecret
+	failureCfg.WebhookURLs, failureCfg.WebhookSecrets = nil, nil
+	target := failureNotifier{&feishuNotifier{cfg: failureCfg, client: newHTTPClient(failureCfg), now: time.Now}}
+	if cfg.FailureAlsoDefault {
+		return append(notifiers, target)
+	}
+	return []Notifier{target}
+}
+
+// mentionTag 将 open_id (或 all) 渲染为 lark_md 中的 @ 标签
+func mentionTag(id string) string {
+	return fmt.Sprintf("<at id=%s></at>", id)
+}
+
+// textMentionTag 将 open_id (或 all) 渲染为纯文本消息中的 @ 标签
+func textMentionTag(id string) string {
+	return fmt.Sprintf(`<at user_id="%s"></at>`, id)
+}
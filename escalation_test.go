@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// escalationEnv 返回主 Webhook 与升级 Webhook 分别指向两个模拟服务器的配置
+func escalationEnv(t *testing.T, after int) (env map[string]string, main, escalated *[]capturedRequest) {
+	t.Helper()
+	mainSrv, main := feishuStub(t, http.StatusOK, `{"code":0}`)
+	escSrv, escalated := feishuStub(t, http.StatusOK, `{"code":0}`)
+	return map[string]string{
+		"FEISHU_WEBHOOK_URL":          mainSrv.URL + "/hook/main",
+		"FEISHU_ESCALATE_AFTER":       fmt.Sprint(after),
+		"FEISHU_ESCALATE_WEBHOOK_URL": escSrv.URL + "/hook/oncall",
+		"FEISHU_MAX_RETRIES":          "0",
+	}, main, escalated
+}
+
+// turnNotification 返回第 i 个 turn 的通知, failed 时结果中带失败关键词
+func turnNotification(i int, failed bool) CodexNotification {
+	n := testNotification()
+	n.TurnID = fmt.Sprintf("turn-%d", i)
+	if failed {
+		n.Status = "error"
+		n.LastAssistantMessage = "go test failed: 3 tests"
+	}
+	return n
+}
+
+func TestEscalationAfterConsecutiveFailures(t *testing.T) {
+	env, main, escalated := escalationEnv(t, 3)
+	cfg := testConfig(t, env)
+	ctx := context.Background()
+
+	steps := []struct {
+		failed        bool
+		wantMain      int
+		wantEscalated int
+	}{
+		{true, 1, 0},
+		{true, 2, 0},
+		{true, 2, 1}, // 第 3 次连续失败升级
+		{true, 2, 2}, // 之后的连续失败继续升级
+		{false, 3, 2},
+		{true, 4, 2}, // 成功后计数清零
+	}
+	for i, step := range steps {
+		rep, err := processNotification(ctx, cfg, turnNotification(i, step.failed))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if rep.Outcome != outcomeSent {
+			t.Fatalf("step %d: outcome = %v, want sent", i, rep.Outcome)
+		}
+		if len(*main) != step.wantMain || len(*escalated) != step.wantEscalated {
+			t.Errorf("step %d: main=%d escalated=%d, want %d and %d",
+				i, len(*main), len(*escalated), step.wantMain, step.wantEscalated)
+		}
+	}
+}
+
+func TestEscalationIgnoresDuplicateTurns(t *testing.T) {
+	env, main, escalated := escalationEnv(t, 3)
+	env["FEISHU_DEDUP_WINDOW"] = "1h"
+	cfg := testConfig(t, env)
+	ctx := context.Background()
+
+	// 同一 turn 重复触发 notify 时只计一次失败
+	for i := 0; i < 3; i++ {
+		if _, err := processNotification(ctx, cfg, turnNotification(1, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*main) != 1 || len(*escalated) != 0 {
+		t.Fatalf("after duplicates: main=%d escalated=%d, want 1 and 0", len(*main), len(*escalated))
+	}
+	if _, err := processNotification(ctx, cfg, turnNotification(2, true)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*escalated) != 0 {
+		t.Fatalf("second distinct failure escalated, duplicates were counted")
+	}
+	if _, err := processNotification(ctx, cfg, turnNotification(3, true)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*escalated) != 1 {
+		t.Errorf("third distinct failure: escalated=%d, want 1", len(*escalated))
+	}
+}
+
+func TestRecordOutcomeResetsOnSuccess(t *testing.T) {
+	cfg := testConfig(t, nil)
+	for want := 1; want <= 3; want++ {
+		got, err := recordOutcome(cfg, "/work/a", true)
+		if err != nil || got != want {
+			t.Fatalf("recordOutcome = %d, %v; want %d", got, err, want)
+		}
+	}
+	if got, _ := recordOutcome(cfg, "/work/b", true); got != 1 {
+		t.Errorf("other cwd count = %d, want 1", got)
+	}
+	if got, _ := recordOutcome(cfg, "/work/a", false); got != 0 {
+		t.Errorf("count after success = %d, want 0", got)
+	}
+	if got, _ := recordOutcome(cfg, "/work/a", true); got != 1 {
+		t.Errorf("count after reset = %d, want 1", got)
+	}
+}
+
+// 并发的 hook 调用各自计入一次失败
+func TestRecordOutcomeConcurrent(t *testing.T) {
+	cfg := testConfig(t, nil)
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := recordOutcome(cfg, "/work/a", true); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := recordOutcome(cfg, "/work/a", true); got != n+1 {
+		t.Errorf("count after %d concurrent failures = %d, want %d", n, got, n+1)
+	}
+}
+
+func TestPruneFailureCounts(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	counts := map[string]failureCount{
+		"/stale":  {Count: 3, LastAt: now.Add(-failureCountTTL - time.Hour).Unix()},
+		"/recent": {Count: 1, LastAt: now.Unix()},
+	}
+	for i := 0; i < maxFailureCounts+10; i++ {
+		counts[fmt.Sprintf("/work/%d", i)] = failureCount{Count: 1, LastAt: now.Add(-time.Duration(i) * time.Minute).Unix()}
+	}
+	pruneFailureCounts(counts, now)
+	if len(counts) != maxFailureCounts {
+		t.Errorf("kept %d counters, want %d", len(counts), maxFailureCounts)
+	}
+	if _, ok := counts["/stale"]; ok {
+		t.Error("stale counter kept")
+	}
+	if _, ok := counts["/recent"]; !ok {
+		t.Error("most recent counter dropped")
+	}
+	if _, ok := counts[fmt.Sprintf("/work/%d", maxFailureCounts+9)]; ok {
+		t.Error("oldest counter kept over the limit")
+	}
+}
+
+// 旧版 failures.json 只记录次数: 再次失败的目录接着计数, 其余旧记录写回时按过期清理
+func TestRecordOutcomeLegacyFormat(t *testing.T) {
+	cfg := testConfig(t, nil)
+	path := filepath.Join(stateDir(cfg), "failures.json")
+	if err := writeStateFile(path, map[string]int{"/work/a": 5, "/work/b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := recordOutcome(cfg, "/work/a", true); err != nil || got != 6 {
+		t.Fatalf("recordOutcome on legacy file = %d, %v; want 6", got, err)
+	}
+	counts := map[string]failureCount{}
+	if err := readStateFile(path, &counts); err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts["/work/a"].LastAt == 0 {
+		t.Errorf("counters after rewrite = %+v", counts)
+	}
+}
+
+// 默认不按关键词判定失败, 正常结果中出现 error / failed 等词不会让卡片变红
+func TestDetectFailureDefault(t *testing.T) {
+	cfg := testConfig(t, nil)
+	for _, result := range []string{
+		"Fixed the error handling in the parser.",
+		"No tests failed.",
+		"Added a failure counter and exception logging.",
+		"Removed the panic from main.",
+		"修复了错误提示, 之前失败的用例已通过",
+	} {
+		n := testNotification()
+		n.LastAssistantMessage = result
+		if detectFailure(n, cfg) {
+			t.Errorf("detectFailure(%q) = true with the default config", result)
+		}
+	}
+
+	n := testNotification()
+	n.Status = "error"
+	if !detectFailure(n, cfg) {
+		t.Error("status=error not detected as a failure")
+	}
+	if cfg := testConfig(t, map[string]string{"CODEX_ERROR": "oom"}); !detectFailure(testNotification(), cfg) {
+		t.Error("CODEX_ERROR not detected as a failure")
+	}
+}
+
+func TestDetectFailureKeywords(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_FAILURE_KEYWORDS": "error,Build Failed,失败,exit-1"})
+	tests := []struct {
+		result string
+		want   bool
+	}{
+		{"compile ERROR: undefined x", true},
+		{"error", true},
+		{"(error)", true},
+		{"build failed on linux", true},
+		{"测试失败", true},
+		{"process exit-1", true},
+		{"3 errors fixed", false},
+		{"wrap it in an ErrorBoundary", false},
+		{"no terrorism here", false},
+		{"error_code is documented", false},
+		{"prebuild failed_step", false},
+		{"process exit-10", false},
+	}
+	for _, tt := range tests {
+		n := testNotification()
+		n.LastAssistantMessage = tt.result
+		if got := detectFailure(n, cfg); got != tt.want {
+			t.Errorf("detectFailure(%q) = %v, want %v", tt.result, got, tt.want)
+		}
+	}
+	// status 字段优先于关键词
+	n := testNotification()
+	n.LastAssistantMessage, n.Status = "error", "success"
+	if detectFailure(n, cfg) {
+		t.Error("keyword overrode status=success")
+	}
+}
+
+func TestEmptyResultPlaceholder(t *testing.T) {
+	tests := []struct {
+		env    map[string]string
+		failed bool
+		want   string
+	}{
+		{nil, false, "（无执行结果描述）"},
+		{nil, true, "任务失败，但未提供错误信息"},
+		{map[string]string{"FEISHU_LANG": "en"}, true, "Task failed without an error message"},
+		{map[string]string{"CODEX_ERROR": " sandbox denied write "}, true, "任务失败：sandbox denied write"},
+		{map[string]string{"FEISHU_FAILURE_PLACEHOLDER": "见 CI 日志"}, true, "见 CI 日志"},
+		{map[string]string{"FEISHU_FAILURE_PLACEHOLDER": "见 CI 日志", "CODEX_ERROR": "oom"}, true, "任务失败：oom"},
+		{map[string]string{"CODEX_ERROR": "oom"}, false, "（无执行结果描述）"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, tt.env)
+		if got := emptyResultPlaceholder(tt.failed, cfg); got != tt.want {
+			t.Errorf("env %v, failed=%v: placeholder = %q, want %q", tt.env, tt.failed, got, tt.want)
+		}
+	}
+}
+
+// 失败且没有执行结果时, 卡片展示失败专用的占位文本
+func TestFailureEmptyCard(t *testing.T) {
+	cfg := testConfig(t, nil)
+	n := testNotification()
+	n.LastAssistantMessage = ""
+	n.Status = "error"
+	_, content := renderContent(n, cfg)
+	body, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "任务失败，但未提供错误信息") {
+		t.Errorf("card does not show the failure placeholder: %s", body)
+	}
+	if strings.Contains(string(body), cfg.t(msgEmptyResult)) {
+		t.Errorf("card shows the success placeholder: %s", body)
+	}
+}
+
+func TestStateDirDefault(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG_CACHE_HOME only applies on Linux")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	if got, want := stateDir(FeishuConfig{}), filepath.Join(cache, "codex-feishu"); got != want {
+		t.Errorf("stateDir = %q, want %q", got, want)
+	}
+	if got := stateDir(FeishuConfig{StateDir: "/srv/state"}); got != "/srv/state" {
+		t.Errorf("stateDir with FEISHU_STATE_DIR = %q", got)
+	}
+
+	path := filepath.Join(stateDir(FeishuConfig{}), "dedup.json")
+	if err := writeStateFile(path, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("state dir mode = %o, want 700", perm)
+	}
+}
+
+func TestStateDirRejectsUnsafe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions")
+	}
+	dir := filepath.Join(t.TempDir(), "state")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "tenant_token.json")
+	if err := writeStateFile(path, map[string]string{"token": "t-1"}); err != nil {
+		t.Fatalf("private state dir rejected: %v", err)
+	}
+
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := readStateFile(path, &got); err == nil || !strings.Contains(err.Error(), "writable by group or others") {
+		t.Errorf("readStateFile in a world-writable dir: err = %v", err)
+	}
+	if err := writeStateFile(path, got); err == nil {
+		t.Error("writeStateFile accepted a world-writable dir")
+	}
+	if _, err := lockStateFile(path); err == nil {
+		t.Error("lockStateFile accepted a world-writable dir")
+	}
+
+	if os.Getuid() != 0 {
+		return
+	}
+	// 以 root 运行时可以把目录交给其他用户, 检查属主
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(dir, 12345, 12345); err != nil {
+		t.Fatal(err)
+	}
+	if err := readStateFile(path, &got); err == nil || !strings.Contains(err.Error(), "owned by another user") {
+		t.Errorf("readStateFile in a dir owned by another user: err = %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ================= 通知类型 =================
+// 除 agent-turn-complete 外, 还为以下类型提供专用卡片:
+//   - agent-turn-start: 任务开始, 只展示输入指令
+//   - approval-requested: 等待审批, 展示待执行的命令与说明
+//   - error / turn-aborted: 出错或被中止, 展示错误信息
+// FEISHU_NOTIFY_TYPES 控制处理哪些类型 (默认只处理 agent-turn-complete), all 表示全部; 其余类型忽略。
+// 失败计数、结果长度与相似内容过滤只作用于 agent-turn-complete。
+
+// Codex 通知类型
+const (
+	typeTurnComplete = "agent-turn-complete"
+	typeTurnStart    = "agent-turn-start"
+	typeApproval     = "approval-requested"
+	typeError        = "error"
+	typeTurnAborted  = "turn-aborted"
+)
+
+// notifyTypesAll FEISHU_NOTIFY_TYPES 中表示全部类型的取值
+const notifyTypesAll = "all"
+
+// commandLine 待审批的命令, Codex 可能传入字符串或参数数组
+type commandLine []string
+
+func (c *commandLine) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = commandLine{s}
+		return nil
+	}
+	var args []string
+	if err := json.Unmarshal(data, &args); err != nil {
+		return fmt.Errorf("command: want a string or an array of strings: %w", err)
+	}
+	*c = args
+	return nil
+}
+
+// String 以空格拼接参数, 含空白的参数加引号
+func (c commandLine) String() string {
+	parts := make([]string, len(c))
+	for i, arg := range c {
+		if strings.ContainsAny(arg, " \t\n") && len(c) > 1 {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseNotifyTypes 解析 FEISHU_NOTIFY_TYPES, 类型名按 normalizeType 统一;
+// 未知类型仍会处理 (使用通用样式), 但记录警告以便发现拼写错误
+func parseNotifyTypes(items []string) map[string]bool {
+	types := map[string]bool{}
+	for _, item := range items {
+		typ := normalizeType(strings.TrimSpace(item))
+		if _, known := defaultTypeStyles[typ]; !known && typ != notifyTypesAll {
+			logger.Warn("FEISHU_NOTIFY_TYPES: unknown notification type", "type", typ)
+		}
+		types[typ] = true
+	}
+	return types
+}
+
+// typeEnabled 判断该类型的通知是否需要处理
+func (cfg FeishuConfig) typeEnabled(typ string) bool {
+	if cfg.NotifyTypes == nil {
+		return typ == typeTurnComplete
+	}
+	return cfg.NotifyTypes[notifyTypesAll] || cfg.NotifyTypes[normalizeType(typ)]
+}
+
+// failureEvent 判断通知类型本身是否表示失败
+func failureEvent(typ string) bool {
+	switch normalizeType(typ) {
+	case typeError, typeTurnAborted:
+		return true
+	}
+	return false
+}
+
+// eventBody 返回卡片正文区的标题与内容: agent-turn-complete 与未知类型为执行结果, 其它类型为对应详情;
+// agent-turn-start 没有正文, label 为空
+func eventBody(n CodexNotification, cfg FeishuConfig, failed bool) (label msgKey, text string) {
+	switch normalizeType(n.Type) {
+	case typeTurnStart:
+		return "", ""
+	case typeApproval:
+		var parts []string
+		if cmd := n.Command.String(); cmd != "" {
+			parts = append(parts, fmt.Sprintf("```\n%s\n```", cmd))
+		}
+		if msg := strings.TrimSpace(n.Message); msg != "" {
+			parts = append(parts, msg)
+		}
+		if len(parts) == 0 {
+			parts = append(parts, cfg.t(msgEmptyDetail))
+		}
+		return msgLabelApproval, truncateText(strings.Join(parts, "\n"), resultLimit, cfg.TruncateBy)
+	case typeError, typeTurnAborted:
+		detail := strings.TrimSpace(n.Message)
+		if detail == "" {
+			detail = strings.TrimSpace(n.LastAssistantMessage)
+		}
+		if detail == "" {
+			detail = emptyResultPlaceholder(true, cfg)
+		}
+		return msgLabelError, truncateText(detail, resultLimit, cfg.TruncateBy)
+	}
+	return msgLabelResult, resultText(n, cfg, failed)
+}
+
+// dedupKey 返回去重缓存的键: agent-turn-complete 沿用 turn-id, 其它类型加类型前缀,
+// 避免同一 turn 的开始与完成通知互相去重
+func dedupKey(n CodexNotification) string {
+	if n.TurnID == "" || n.Type == typeTurnComplete {
+		return n.TurnID
+	}
+	return normalizeType(n.Type) + ":" + n.TurnID
+}
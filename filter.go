@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parseCwdPatterns 校验工作路径规则, 展开开头的 ~ 并规范化路径
+func parseCwdPatterns(name string, raw []string) ([]string, error) {
+	var patterns []string
+	for _, p := range raw {
+		if p == "~" || strings.HasPrefix(p, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("%s: expand %q: %w", name, p, err)
+			}
+			p = home + p[1:]
+		}
+		p = filepath.Clean(p)
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q: %w", name, p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// cwdMatches 判断工作路径是否命中任一规则
+// 不含通配符的规则按路径前缀匹配 (以目录为单位, /work 不匹配 /workspace);
+// 通配符规则匹配路径本身或其任一上级目录, 因此 /tmp/* 也覆盖 /tmp/a/b
+func cwdMatches(cwd string, patterns []string) bool {
+	if cwd == "" {
+		return false
+	}
+	cwd = filepath.Clean(cwd)
+	for _, p := range patterns {
+		if !strings.ContainsAny(p, "*?[") {
+			if cwd == p || strings.HasPrefix(cwd, strings.TrimSuffix(p, string(filepath.Separator))+string(filepath.Separator)) {
+				return true
+			}
+			continue
+		}
+		for dir := cwd; ; dir = filepath.Dir(dir) {
+			if matched, _ := filepath.Match(p, dir); matched {
+				return true
+			}
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// cwdFiltered 判断通知是否因工作路径被过滤: 命中黑名单, 或未命中非空的白名单
+func cwdFiltered(n CodexNotification, cfg FeishuConfig) bool {
+	if cwdMatches(n.Cwd, cfg.CwdDeny) {
+		return true
+	}
+	return len(cfg.CwdAllow) > 0 && !cwdMatches(n.Cwd, cfg.CwdAllow)
+}
+
+// resultTooShort 判断执行结果 (去除首尾空白后) 是否短于 min 个字符
+func resultTooShort(n CodexNotification, min int) bool {
+	if min <= 0 {
+		return false
+	}
+	return utf8.RuneCountInString(strings.TrimSpace(n.LastAssistantMessage)) < min
+}
+
+// dedupPath 返回 turn-id 去重缓存文件路径
+func dedupPath(cfg FeishuConfig) string {
+	return filepath.Join(stateDir(cfg), "dedup.json")
+}
+
+// loadDedupCache 读取去重缓存并清理超出窗口的记录
+func loadDedupCache(cfg FeishuConfig, now time.Time) (map[string]int64, error) {
+	seen := map[string]int64{}
+	if err := readStateFile(dedupPath(cfg), &seen); err != nil {
+		return nil, err
+	}
+	pruneDedupCache(seen, cfg, now)
+	return seen, nil
+}
+
+// pruneDedupCache 删除超出去重窗口的记录
+func pruneDedupCache(seen map[string]int64, cfg FeishuConfig, now time.Time) {
+	cutoff := now.Add(-cfg.DedupWindow).Unix()
+	for id, ts := range seen {
+		if ts < cutoff {
+			delete(seen, id)
+		}
+	}
+}
+
+// seenRecently 判断 turn-id 是否在去重窗口内已发送过
+func seenRecently(cfg FeishuConfig, turnID string, now time.Time) (bool, error) {
+	if cfg.DedupWindow <= 0 || turnID == "" {
+		return false, nil
+	}
+	seen, err := loadDedupCache(cfg, now)
+	if err != nil {
+		return false, err
+	}
+	_, ok := seen[turnID]
+	return ok, nil
+}
+
+// markSent 记录 turn-id 已发送, 并顺带写回清理后的缓存
+func markSent(cfg FeishuConfig, turnID string, now time.Time) error {
+	if cfg.DedupWindow <= 0 || turnID == "" {
+		return nil
+	}
+	seen := map[string]int64{}
+	return updateStateFile(dedupPath(cfg), &seen, func() {
+		pruneDedupCache(seen, cfg, now)
+		seen[turnID] = now.Unix()
+	})
+}
+
+// ================= 相似内容节流 =================
+// 同一工作路径下, 与上一张已发送卡片的内容词集相似度 (Jaccard) 达到阈值时不再发送。
+// 超过 similarityTTL 的指纹不再参与比较, 并在写入时清理
+
+// similarityTTL 内容指纹的有效期
+const similarityTTL = 24 * time.Hour
+
+// fingerprint 记录某个工作路径最近一次发送内容的词集
+type fingerprint struct {
+	Tokens []string `json:"tokens"`
+	SentAt int64    `json:"sent_at"`
+}
+
+// similarityPath 返回内容指纹缓存文件路径
+func similarityPath(cfg FeishuConfig) string {
+	return filepath.Join(stateDir(cfg), "similarity.json")
+}
+
+// contentTokens 将输入指令与执行结果拆分为小写词集; 汉字等无空格分词的文字按单字计
+func contentTokens(n CodexNotification) []string {
+	text := strings.ToLower(strings.Join(n.InputMessages, "\n") + "\n" + n.LastAssistantMessage)
+	set := map[string]bool{}
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			set[word.String()] = true
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			set[string(r)] = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	tokens := make([]string, 0, len(set))
+	for t := range set {
+		tokens = append(tokens, t)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// jaccard 计算两个词集的 Jaccard 相似度, 两者皆空时视为相同
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	in := make(map[string]bool, len(a))
+	for _, t := range a {
+		in[t] = true
+	}
+	common := 0
+	for _, t := range b {
+		if in[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// similarToLast 判断内容是否与该工作路径上一次 (有效期内) 发送的内容高度相似
+func similarToLast(cfg FeishuConfig, n CodexNotification, now time.Time) (bool, float64, error) {
+	if cfg.SimilarThreshold <= 0 {
+		return false, 0, nil
+	}
+	last := map[string]fingerprint{}
+	if err := readStateFile(similarityPath(cfg), &last); err != nil {
+		return false, 0, err
+	}
+	prev, ok := last[n.Cwd]
+	if !ok || prev.SentAt < now.Add(-similarityTTL).Unix() {
+		return false, 0, nil
+	}
+	score := jaccard(prev.Tokens, contentTokens(n))
+	return score >= cfg.SimilarThreshold, score, nil
+}
+
+// rememberFingerprint 记录该工作路径最近一次发送内容的词集
+func rememberFingerprint(cfg FeishuConfig, n CodexNotification, now time.Time) error {
+	if cfg.SimilarThreshold <= 0 {
+		return nil
+	}
+	last := map[string]fingerprint{}
+	return updateStateFile(similarityPath(cfg), &last, func() {
+		cutoff := now.Add(-similarityTTL).Unix()
+		for cwd, fp := range last {
+			if fp.SentAt < cutoff {
+				delete(last, cwd)
+			}
+		}
+		last[n.Cwd] = fingerprint{Tokens: contentTokens(n), SentAt: now.Unix()}
+	})
+}
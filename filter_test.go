@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_DEDUP_WINDOW": "10m"})
+	now := time.Unix(1700000000, 0)
+
+	if dup, err := seenRecently(cfg, "turn-1", now); err != nil || dup {
+		t.Fatalf("unseen turn: dup=%v err=%v", dup, err)
+	}
+	if err := markSent(cfg, "turn-1", now); err != nil {
+		t.Fatal(err)
+	}
+	if dup, _ := seenRecently(cfg, "turn-1", now.Add(5*time.Minute)); !dup {
+		t.Error("turn sent 5m ago not treated as duplicate")
+	}
+	if dup, _ := seenRecently(cfg, "turn-1", now.Add(11*time.Minute)); dup {
+		t.Error("turn sent 11m ago still treated as duplicate")
+	}
+
+	// 写入时清理超出窗口的记录
+	if err := markSent(cfg, "turn-2", now.Add(20*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]int64{}
+	if err := readStateFile(dedupPath(cfg), &seen); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := seen["turn-1"]; ok || len(seen) != 1 {
+		t.Errorf("dedup cache = %v, want only turn-2", seen)
+	}
+}
+
+func TestDedupDisabled(t *testing.T) {
+	cfg := testConfig(t, nil)
+	if err := markSent(cfg, "turn-1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if dup, _ := seenRecently(cfg, "turn-1", time.Now()); dup {
+		t.Error("dedup active without FEISHU_DEDUP_WINDOW")
+	}
+	if _, err := os.Stat(dedupPath(cfg)); !os.IsNotExist(err) {
+		t.Errorf("dedup cache written while disabled: %v", err)
+	}
+}
+
+// 并发的 hook 调用不会互相覆盖去重记录
+func TestMarkSentConcurrent(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_DEDUP_WINDOW": "1h"})
+	now := time.Now()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := markSent(cfg, turnNotification(i, false).TurnID, now); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	seen, err := loadDedupCache(cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != n {
+		t.Errorf("dedup cache has %d entries, want %d", len(seen), n)
+	}
+	if _, err := os.Stat(dedupPath(cfg) + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestStateLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path+".lock", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * stateLockStale)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockStateFile(path)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	unlock()
+}
+
+func TestSimilarityPrunedByAge(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_SIMILARITY_THRESHOLD": "0.9"})
+	now := time.Unix(1700000000, 0)
+	a := testNotification()
+	a.Cwd = "/work/a"
+	b := testNotification()
+	b.Cwd = "/work/b"
+
+	if err := rememberFingerprint(cfg, a, now); err != nil {
+		t.Fatal(err)
+	}
+	if similar, _, _ := similarToLast(cfg, a, now.Add(time.Hour)); !similar {
+		t.Error("identical content within the TTL not treated as similar")
+	}
+	if similar, _, _ := similarToLast(cfg, a, now.Add(similarityTTL+time.Minute)); similar {
+		t.Error("fingerprint older than the TTL still suppresses the card")
+	}
+
+	if err := rememberFingerprint(cfg, b, now.Add(similarityTTL+time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	last := map[string]fingerprint{}
+	if err := readStateFile(similarityPath(cfg), &last); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := last["/work/a"]; ok || len(last) != 1 {
+		t.Errorf("similarity cache keeps %d entries, want only /work/b", len(last))
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{nil, nil, 1},
+		{[]string{"a"}, nil, 0},
+		{[]string{"a", "b"}, []string{"a", "b"}, 1},
+		{[]string{"a", "b"}, []string{"b", "c"}, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("jaccard(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMinResultLen(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":    srv.URL + "/hook",
+		"FEISHU_MIN_RESULT_LEN": "6",
+	})
+	tests := []struct {
+		result string
+		failed bool
+		want   outcome
+	}{
+		{"Done.", false, outcomeSkipped},                // 5 个字符, 低于阈值
+		{"  Done.\n\n", false, outcomeSkipped},          // 按去除首尾空白后的长度计算
+		{"Done!!", false, outcomeSent},                  // 恰好等于阈值
+		{"完成了", false, outcomeSkipped},                  // 按字符而非字节计
+		{"err", true, outcomeSent},                      // 失败即使很短也发送
+		{"\x1b[32mDone.\x1b[0m", false, outcomeSkipped}, // 默认流水线先去除 ANSI 控制码
+	}
+	for i, tt := range tests {
+		n := turnNotification(i, false)
+		n.LastAssistantMessage = tt.result
+		if tt.failed {
+			n.Status = "error"
+		}
+		rep, err := processNotification(context.Background(), cfg, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.Outcome != tt.want {
+			t.Errorf("result %q: outcome = %v, want %v", tt.result, rep.Outcome, tt.want)
+		}
+	}
+	if len(*got) != 2 {
+		t.Errorf("sent %d cards, want 2", len(*got))
+	}
+}
+
+func TestResultTooShortDisabled(t *testing.T) {
+	n := testNotification()
+	n.LastAssistantMessage = ""
+	if resultTooShort(n, 0) {
+		t.Error("empty result skipped without FEISHU_MIN_RESULT_LEN")
+	}
+}
+
+func TestSimilarContentSuppressed(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":          srv.URL + "/hook",
+		"FEISHU_SIMILARITY_THRESHOLD": "0.8",
+	})
+	result := "ran the unit tests for the parser package and all forty two cases passed after fixing the tokenizer"
+	tests := []struct {
+		name   string
+		result string
+		cwd    string
+		failed bool
+		want   outcome
+	}{
+		{"first", result, "/work/a", false, outcomeSent},
+		{"near duplicate", result + " again", "/work/a", false, outcomeSkipped},
+		{"other project", result, "/work/b", false, outcomeSent},
+		{"failure", result, "/work/a", true, outcomeSent},
+		{"dissimilar", "deployed the staging environment and rotated the database credentials", "/work/a", false, outcomeSent},
+	}
+	for i, tt := range tests {
+		n := turnNotification(i, false)
+		n.InputMessages = []string{"run the tests"}
+		n.LastAssistantMessage = tt.result
+		n.Cwd = tt.cwd
+		if tt.failed {
+			n.Status = "error"
+		}
+		rep, err := processNotification(context.Background(), cfg, n)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if rep.Outcome != tt.want {
+			t.Errorf("%s: outcome = %v, want %v", tt.name, rep.Outcome, tt.want)
+		}
+	}
+	if len(*got) != 4 {
+		t.Errorf("sent %d cards, want 4", len(*got))
+	}
+}
+
+func TestContentTokens(t *testing.T) {
+	n := testNotification()
+	n.InputMessages = []string{"Fix the BUG"}
+	n.LastAssistantMessage = "fixed: the bug, 修复完成"
+	want := []string{"bug", "fix", "fixed", "the", "修", "复", "完", "成"}
+	got := contentTokens(n)
+	if len(got) != len(want) {
+		t.Fatalf("contentTokens = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("contentTokens = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestCwdMatches(t *testing.T) {
+	tests := []struct {
+		cwd      string
+		patterns []string
+		want     bool
+	}{
+		{"/work/app", []string{"/work"}, true},
+		{"/work", []string{"/work"}, true},
+		{"/workspace/app", []string{"/work"}, false}, // 前缀按目录匹配
+		{"/tmp/scratch-1/src", []string{"/tmp/scratch-*"}, true},
+		{"/tmp/scratch-1", []string{"/tmp/scratch-?"}, true},
+		{"/tmp/other", []string{"/tmp/scratch-*"}, false},
+		{"/home/u/proj/api", []string{"/home/*/proj"}, true},
+		{"/work/app/../other", []string{"/work/other"}, true},
+		{"", []string{"/"}, false},
+		{"/work/app", nil, false},
+	}
+	for _, tt := range tests {
+		if got := cwdMatches(tt.cwd, tt.patterns); got != tt.want {
+			t.Errorf("cwdMatches(%q, %q) = %v, want %v", tt.cwd, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+// 黑名单优先于白名单; 白名单非空时未命中的路径被过滤
+func TestCwdAllowDeny(t *testing.T) {
+	tests := []struct {
+		allow, deny string
+		cwd         string
+		filtered    bool
+	}{
+		{"", "", "/anywhere", false},
+		{"/work", "", "/work/app", false},
+		{"/work", "", "/tmp/scratch", true},
+		{"", "/tmp/*", "/tmp/scratch", true},
+		{"", "/tmp/*", "/work/app", false},
+		{"/work", "/work/sandbox", "/work/sandbox/x", true},
+		{"/work", "/work/sandbox", "/work/app", false},
+		{"/work/*", "/work/*", "/work/app", true},
+		{"/work", "", "", true},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, map[string]string{"FEISHU_CWD_ALLOW": tt.allow, "FEISHU_CWD_DENY": tt.deny})
+		n := testNotification()
+		n.Cwd = tt.cwd
+		if got := cwdFiltered(n, cfg); got != tt.filtered {
+			t.Errorf("allow=%q deny=%q cwd=%q: filtered = %v, want %v", tt.allow, tt.deny, tt.cwd, got, tt.filtered)
+		}
+	}
+}
+
+func TestCwdFilterSkipsSend(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": srv.URL + "/hook",
+		"FEISHU_CWD_DENY":    "/work",
+	})
+	rep, err := processNotification(context.Background(), cfg, testNotification())
+	if err != nil || rep.Outcome != outcomeSkipped || len(*got) != 0 {
+		t.Errorf("outcome=%v err=%v requests=%d, want skipped without sending", rep.Outcome, err, len(*got))
+	}
+}
+
+func TestParseCwdPatterns(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory:", err)
+	}
+	got, err := parseCwdPatterns("FEISHU_CWD_ALLOW", []string{"~/src", "/work/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != filepath.Join(home, "src") || got[1] != "/work" {
+		t.Errorf("patterns = %q", got)
+	}
+	if _, err := parseCwdPatterns("FEISHU_CWD_DENY", []string{"/tmp/[a-"}); err == nil {
+		t.Error("malformed glob accepted")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 底部备注行, 通过 FEISHU_FOOTER_LINES 按顺序启用
+const (
+	footerLineTime    = "time"    // 生成时间 (或相对开始时间)
+	footerLineVersion = "version" // 通知程序版本
+	footerLineUser    = "user"    // 用户@主机名
+	footerLineHash    = "hash"    // 输入与结果的内容摘要, 便于对照去重
+)
+
+var footerLineNames = map[string]bool{
+	footerLineTime:    true,
+	footerLineVersion: true,
+	footerLineUser:    true,
+	footerLineHash:    true,
+}
+
+// parseFooterLines 校验并返回底部备注行; showHost 为兼容 FEISHU_SHOW_HOST, 会追加 user 行
+func parseFooterLines(names []string, showHost bool) ([]string, error) {
+	if len(names) == 0 {
+		names = []string{footerLineTime}
+	}
+	var lines []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == "none" {
+			continue
+		}
+		if !footerLineNames[name] {
+			return nil, fmt.Errorf("FEISHU_FOOTER_LINES: unknown line %q (want time, version, user or hash)", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			lines = append(lines, name)
+		}
+	}
+	if showHost && !seen[footerLineUser] {
+		lines = append(lines, footerLineUser)
+	}
+	return lines, nil
+}
+
+// buildFooter 构建卡片底部备注, 每个启用的行对应一个 plain_text 元素
+func buildFooter(n CodexNotification, cfg FeishuConfig, now time.Time) FeishuNote {
+	note := FeishuNote{Tag: "note"}
+	for _, line := range cfg.FooterLines {
+		var content string
+		switch line {
+		case footerLineTime:
+			content = footerTime(n, cfg, now)
+		case footerLineVersion:
+			content = fmt.Sprintf("🏷️ %s %s (%s)", binaryName, version, commit)
+		case footerLineUser:
+			content = fmt.Sprintf("💻 %s@%s", currentUser(), hostname())
+		case footerLineHash:
+			content = fmt.Sprintf("#️⃣ %s", contentHash(n))
+		}
+		note.Elements = append(note.Elements, FeishuText{Tag: "plain_text", Content: content})
+	}
+	return note
+}
+
+// footerTime 返回时间行文案; 配置了 FEISHU_FOOTER_TEMPLATE 时按模板渲染
+func footerTime(n CodexNotification, cfg FeishuConfig, now time.Time) string {
+	if cfg.FooterTemplate != "" {
+		return renderFooterTemplate(cfg.FooterTemplate, n, cfg, now)
+	}
+	if cfg.FooterRelative {
+		if started, ok := parseStartedAt(n.StartedAt); ok {
+			return cfg.tf(msgFooterStarted, cfg.AppName, formatRelative(cfg, now, started))
+		}
+	}
+	return cfg.tf(msgFooterAt, cfg.AppName, footerClock(cfg, now))
+}
+
+// footerClock 返回底部使用的时钟时间, FEISHU_FOOTER_DATE 时带日期
+func footerClock(cfg FeishuConfig, now time.Time) string {
+	layout := "15:04:05 MST"
+	if cfg.FooterDate {
+		layout = "2006-01-02 15:04:05 MST"
+	}
+	return now.In(cfg.location()).Format(layout)
+}
+
+var footerPlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// renderFooterTemplate 替换模板中的 {time} {host} {version} {thread_id} {app} 占位符, 未知占位符原样保留
+// {time} 在 FEISHU_FOOTER_RELATIVE 开启且有 started-at 时为相对时间
+func renderFooterTemplate(tmpl string, n CodexNotification, cfg FeishuConfig, now time.Time) string {
+	return footerPlaceholder.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		switch ph {
+		case "{time}":
+			if cfg.FooterRelative {
+				if started, ok := parseStartedAt(n.StartedAt); ok {
+					return formatRelative(cfg, now, started)
+				}
+			}
+			return footerClock(cfg, now)
+		case "{host}":
+			return hostname()
+		case "{version}":
+			return version
+		case "{thread_id}":
+			return n.ThreadID
+		case "{app}":
+			return cfg.AppName
+		}
+		return ph
+	})
+}
+
+// contentHash 返回输入指令与执行结果的 SHA-256 摘要前 12 位
+func contentHash(n CodexNotification) string {
+	h := sha256.New()
+	for _, msg := range n.InputMessages {
+		io.WriteString(h, msg)
+		h.Write([]byte{0})
+	}
+	io.WriteString(h, n.LastAssistantMessage)
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// hostname 返回主机名, 获取失败时返回 unknown-host
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		logger.Debug("hostname lookup failed", "err", err)
+		return "unknown-host"
+	}
+	return h
+}
+
+// currentUser 返回当前系统用户名, 获取失败时依次尝试 USER / USERNAME 环境变量
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown-user"
+}
+
+// location 返回底部时间使用的时区, 未配置时使用本地时区
+func (cfg FeishuConfig) location() *time.Location {
+	if cfg.Location != nil {
+		return cfg.Location
+	}
+	return time.Local
+}
+
+// loadLocation 加载 IANA 时区, 为空或加载失败时回退到本地时区
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("unknown FEISHU_TIMEZONE, falling back to local time", "timezone", name, "err", err)
+		return time.Local
+	}
+	return loc
+}
+
+// parseStartedAt 解析 RFC3339 格式的任务开始时间
+func parseStartedAt(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		logger.Debug("ignoring invalid started-at", "value", s, "err", err)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// formatRelative 将 t 相对 now 的时间差格式化为 "刚刚 / N 分钟前" 风格
+func formatRelative(cfg FeishuConfig, now, t time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return cfg.t(msgJustNow)
+	case d < time.Hour:
+		return cfg.tf(msgMinutesAgo, int(d/time.Minute))
+	case d < 24*time.Hour:
+		return cfg.tf(msgHoursAgo, int(d/time.Hour))
+	default:
+		return cfg.tf(msgDaysAgo, int(d/(24*time.Hour)))
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// footerNow 固定的底部时间, 2024-03-01 12:34:56 UTC
+var footerNow = time.Date(2024, 3, 1, 12, 34, 56, 0, time.UTC)
+
+// footerTexts 返回底部备注各行的文本
+func footerTexts(note FeishuNote) []string {
+	var texts []string
+	for _, e := range note.Elements {
+		texts = append(texts, e.Content)
+	}
+	return texts
+}
+
+func TestFooterClock(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"FEISHU_TIMEZONE": "UTC"}, "Generated by Codex at 12:34:56 UTC"},
+		{map[string]string{"FEISHU_TIMEZONE": "Asia/Shanghai"}, "Generated by Codex at 20:34:56 CST"},
+		{map[string]string{"FEISHU_TIMEZONE": "UTC", "FEISHU_FOOTER_DATE": "1", "FEISHU_APP_NAME": "Agent"}, "Generated by Agent at 2024-03-01 12:34:56 UTC"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, tt.env)
+		if got := footerTexts(buildFooter(testNotification(), cfg, footerNow)); !reflect.DeepEqual(got, []string{tt.want}) {
+			t.Errorf("footer with %v = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+	if got := footerClock(FeishuConfig{Location: shanghai}, footerNow); got != "20:34:56 CST" {
+		t.Errorf("footerClock = %q", got)
+	}
+}
+
+func TestFooterLines(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_TIMEZONE":     "UTC",
+		"FEISHU_FOOTER_LINES": "version,hash,time",
+	})
+	got := footerTexts(buildFooter(testNotification(), cfg, footerNow))
+	if len(got) != 3 {
+		t.Fatalf("footer = %q, want 3 lines", got)
+	}
+	if want := "🏷️ " + binaryName + " " + version + " (" + commit + ")"; got[0] != want {
+		t.Errorf("version line = %q, want %q", got[0], want)
+	}
+	if want := "#️⃣ " + contentHash(testNotification()); got[1] != want {
+		t.Errorf("hash line = %q, want %q", got[1], want)
+	}
+	if got[2] != "Generated by Codex at 12:34:56 UTC" {
+		t.Errorf("time line = %q", got[2])
+	}
+}
+
+func TestParseFooterLines(t *testing.T) {
+	tests := []struct {
+		names    []string
+		showHost bool
+		want     []string
+	}{
+		{nil, false, []string{"time"}},
+		{nil, true, []string{"time", "user"}},
+		{[]string{"none"}, false, nil},
+		{[]string{"Hash", "time", "hash"}, false, []string{"hash", "time"}},
+		{[]string{"user", "time"}, true, []string{"user", "time"}},
+	}
+	for _, tt := range tests {
+		got, err := parseFooterLines(tt.names, tt.showHost)
+		if err != nil {
+			t.Fatalf("parseFooterLines(%q, %v): %v", tt.names, tt.showHost, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFooterLines(%q, %v) = %q, want %q", tt.names, tt.showHost, got, tt.want)
+		}
+	}
+	if _, err := parseFooterLines([]string{"started"}, false); err == nil {
+		t.Error("unknown footer line accepted")
+	}
+}
+
+func TestRenderFooterTemplate(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_TIMEZONE": "UTC", "FEISHU_APP_NAME": "Agent"})
+	n := testNotification()
+	got := renderFooterTemplate("{app} {version} · {thread_id} · {time} {unknown}", n, cfg, footerNow)
+	want := "Agent " + version + " · " + n.ThreadID + " · 12:34:56 UTC {unknown}"
+	if got != want {
+		t.Errorf("renderFooterTemplate = %q, want %q", got, want)
+	}
+	if got := renderFooterTemplate("{host}", n, cfg, footerNow); got != hostname() {
+		t.Errorf("{host} = %q, want %q", got, hostname())
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := testNotification()
+	b := testNotification()
+	if contentHash(a) != contentHash(b) || len(contentHash(a)) != 12 {
+		t.Fatalf("contentHash not stable: %q %q", contentHash(a), contentHash(b))
+	}
+	// 输入的分隔不同, 摘要也不同
+	a.InputMessages = []string{"ab", "c"}
+	b.InputMessages = []string{"a", "bc"}
+	if contentHash(a) == contentHash(b) {
+		t.Error("contentHash ignores input boundaries")
+	}
+	if !strings.HasPrefix(versionString(), binaryName+" ") {
+		t.Errorf("versionString() = %q", versionString())
+	}
+}
+
+func TestFooterNoteElements(t *testing.T) {
+	user := "💻 " + currentUser() + "@" + hostname()
+	tests := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{}, []string{"Generated by Codex at 12:34:56 UTC"}},
+		{map[string]string{"FEISHU_SHOW_HOST": "1"}, []string{"Generated by Codex at 12:34:56 UTC", user}},
+		{map[string]string{"FEISHU_FOOTER_LINES": "user,time"}, []string{user, "Generated by Codex at 12:34:56 UTC"}},
+		{map[string]string{"FEISHU_FOOTER_LINES": "none"}, nil},
+	}
+	for _, tt := range tests {
+		tt.env["FEISHU_TIMEZONE"] = "UTC"
+		cfg := testConfig(t, tt.env)
+		note := buildFooter(testNotification(), cfg, footerNow)
+		if got := footerTexts(note); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("footer with %v = %q, want %q", tt.env, got, tt.want)
+		}
+		for _, e := range note.Elements {
+			if e.Tag != "plain_text" {
+				t.Errorf("footer element tag = %q, want plain_text", e.Tag)
+			}
+		}
+	}
+}
+
+// 所有行都关闭时卡片中不出现空的 note 元素
+func TestFooterOmittedFromCard(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_FOOTER_LINES": "none"})
+	for _, e := range buildCard(testNotification(), cfg, false).Elements {
+		if _, ok := e.(FeishuNote); ok {
+			t.Fatal("card contains a footer note with no lines")
+		}
+	}
+	cfg = testConfig(t, map[string]string{"FEISHU_FOOTER_LINES": "time,hash"})
+	var notes []FeishuNote
+	for _, e := range buildCard(testNotification(), cfg, false).Elements {
+		if note, ok := e.(FeishuNote); ok {
+			notes = append(notes, note)
+		}
+	}
+	if len(notes) != 1 || len(notes[0].Elements) != 2 {
+		t.Errorf("card footer notes = %+v, want one note with 2 lines", notes)
+	}
+}
+
+func TestCustomFooterTemplate(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_TIMEZONE":        "UTC",
+		"FEISHU_FOOTER_TEMPLATE": "Sent from {host} by notifier {version} at {time} · %s {thread_id} {bogus}",
+	})
+	n := testNotification()
+	want := "Sent from " + hostname() + " by notifier " + version + " at 12:34:56 UTC · %s " + n.ThreadID + " {bogus}"
+	if got := footerTexts(buildFooter(n, cfg, footerNow)); !reflect.DeepEqual(got, []string{want}) {
+		t.Errorf("footer = %q, want %q", got, want)
+	}
+
+	var note FeishuNote
+	for _, e := range buildCard(n, cfg, false).Elements {
+		if fn, ok := e.(FeishuNote); ok {
+			note = fn
+		}
+	}
+	if len(note.Elements) != 1 || !strings.HasPrefix(note.Elements[0].Content, "Sent from ") {
+		t.Errorf("card footer = %+v, want the custom template", note)
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	zh := testConfig(t, nil)
+	en := testConfig(t, map[string]string{"FEISHU_LANG": "en"})
+	tests := []struct {
+		ago    time.Duration
+		zh, en string
+	}{
+		{0, "刚刚", "just now"},
+		{59 * time.Second, "刚刚", "just now"},
+		{time.Minute, "1 分钟前", "1 min ago"},
+		{2*time.Minute + 30*time.Second, "2 分钟前", "2 min ago"},
+		{59 * time.Minute, "59 分钟前", "59 min ago"},
+		{time.Hour, "1 小时前", "1 h ago"},
+		{23*time.Hour + 59*time.Minute, "23 小时前", "23 h ago"},
+		{24 * time.Hour, "1 天前", "1 days ago"},
+		{10*24*time.Hour + 5*time.Hour, "10 天前", "10 days ago"},
+	}
+	for _, tt := range tests {
+		started := footerNow.Add(-tt.ago)
+		if got := formatRelative(zh, footerNow, started); got != tt.zh {
+			t.Errorf("formatRelative(%v) = %q, want %q", tt.ago, got, tt.zh)
+		}
+		if got := formatRelative(en, footerNow, started); got != tt.en {
+			t.Errorf("formatRelative(%v, en) = %q, want %q", tt.ago, got, tt.en)
+		}
+	}
+}
+
+func TestFooterRelative(t *testing.T) {
+	n := testNotification()
+	n.StartedAt = footerNow.Add(-2 * time.Minute).Format(time.RFC3339)
+
+	cfg := testConfig(t, map[string]string{"FEISHU_TIMEZONE": "UTC"})
+	if got := footerTexts(buildFooter(n, cfg, footerNow)); !reflect.DeepEqual(got, []string{"Generated by Codex at 12:34:56 UTC"}) {
+		t.Errorf("footer without FEISHU_FOOTER_RELATIVE = %q", got)
+	}
+
+	cfg = testConfig(t, map[string]string{"FEISHU_TIMEZONE": "UTC", "FEISHU_FOOTER_RELATIVE": "1"})
+	if got := footerTexts(buildFooter(n, cfg, footerNow)); !reflect.DeepEqual(got, []string{"Generated by Codex · 开始于 2 分钟前"}) {
+		t.Errorf("relative footer = %q", got)
+	}
+	cfg.FooterTemplate = "{app} · {time}"
+	if got := footerTexts(buildFooter(n, cfg, footerNow)); !reflect.DeepEqual(got, []string{"Codex · 2 分钟前"}) {
+		t.Errorf("relative footer template = %q", got)
+	}
+
+	// 缺少或无法解析 started-at 时退回时钟时间
+	cfg.FooterTemplate = ""
+	for _, startedAt := range []string{"", "2024-03-01 12:00"} {
+		n.StartedAt = startedAt
+		if got := footerTexts(buildFooter(n, cfg, footerNow)); !reflect.DeepEqual(got, []string{"Generated by Codex at 12:34:56 UTC"}) {
+			t.Errorf("footer with started-at %q = %q, want the clock time", startedAt, got)
+		}
+	}
+}
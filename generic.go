@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// ================= 通用 Webhook sink =================
+// FEISHU_BACKEND=generic 时向任意地址 (FEISHU_GENERIC_URL) POST 一个 JSON 请求体, 用于对接内部系统。
+// 请求体由 FEISHU_GENERIC_TEMPLATE_FILE 指定的 text/template 渲染, 上下文与卡片模板相同
+// (通知的全部字段以及 .Failed / .Result / .Title), 可用 escape、json、truncate、join 等辅助函数;
+// 未配置模板时发送通知本身加上这些派生字段。FEISHU_GENERIC_HEADERS 为附加请求头, 格式同 FEISHU_EXTRA_HEADERS。
+// 任意 2xx 状态码视为成功。
+
+const backendGeneric = "generic"
+
+func init() {
+	registerBackend(backendGeneric, func(cfg *FeishuConfig, webhook, _ string) {
+		cfg.GenericURL = webhook
+	})
+	registerNotifier("generic", newGenericNotifier)
+}
+
+type genericNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newGenericNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendGeneric {
+		return nil, nil
+	}
+	if cfg.GenericURL == "" {
+		return nil, errors.New("FEISHU_BACKEND=generic requires FEISHU_GENERIC_URL")
+	}
+	if err := validateWebhookURL(cfg.GenericURL); err != nil {
+		return nil, fmt.Errorf("FEISHU_GENERIC_URL: %w", err)
+	}
+	return []Notifier{&genericNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (g *genericNotifier) Name() string { return "generic" }
+
+func (g *genericNotifier) Target() string { return redactWebhook(g.cfg.GenericURL) }
+
+// loadGenericTemplate 读取并解析请求体模板; 与卡片模板不同, 没有内置布局可以回退, 因此语法错误也是配置错误
+func loadGenericTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("FEISHU_GENERIC_TEMPLATE_FILE: %w", err)
+	}
+	tmpl, err := template.New(path).Funcs(cardTemplateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("FEISHU_GENERIC_TEMPLATE_FILE: %w", err)
+	}
+	return tmpl, nil
+}
+
+// genericBody 未配置模板时的请求体: 通知字段与派生字段
+type genericBody struct {
+	CodexNotification
+	Failed bool   `json:"failed"`
+	Result string `json:"result"`
+	Title  string `json:"title"`
+}
+
+// renderGenericBody 按模板渲染请求体, 渲染结果必须是合法的 JSON
+func renderGenericBody(n CodexNotification, cfg FeishuConfig) ([]byte, error) {
+	n, cfg, failed := prepareNotification(n, cfg)
+	_, result := eventBody(n, cfg, failed)
+	data := cardTemplateData{CodexNotification: n, Failed: failed, Result: result, Title: cardTitle(n, cfg, failed)}
+	if cfg.GenericTemplate == nil {
+		return json.Marshal(genericBody{CodexNotification: n, Failed: failed, Result: result, Title: data.Title})
+	}
+	var buf bytes.Buffer
+	if err := cfg.GenericTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("generic template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("generic template did not render valid JSON: %s", truncateText(buf.String(), 200, truncateByRunes))
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *genericNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := g.cfg
+	payload, err := renderGenericBody(n, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, json.RawMessage(payload), cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	return postJSON(ctx, g.client, cfg, cfg.GenericURL, g.Target(), cfg.GenericHeaders, payload, nil)
+}
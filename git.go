@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitTimeout 单次获取 git 信息的总超时, 避免 git 卡住通知程序
+const gitTimeout = 2 * time.Second
+
+// gitRef 返回工作路径的 "分支@短提交", 非 git 目录、detached HEAD 或找不到 git 时返回空字符串
+func gitRef(cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	branch, err := gitOutput(ctx, cwd, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil || branch == "" || branch == "HEAD" {
+		logger.Debug("git branch unavailable", "cwd", cwd, "branch", branch, "err", err)
+		return ""
+	}
+	commit, err := gitOutput(ctx, cwd, "rev-parse", "--short", "HEAD")
+	if err != nil || commit == "" {
+		logger.Debug("git commit unavailable", "cwd", cwd, "err", err)
+		return ""
+	}
+	return branch + "@" + commit
+}
+
+// gitOutput 执行 git -C <cwd> 子命令并返回去除空白的输出
+func gitOutput(ctx context.Context, cwd string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", cwd}, args...)...)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// msgKey 是卡片文案在消息目录中的键
+type msgKey string
+
+const (
+	msgTitleDone     msgKey = "title.done"
+	msgTitleFailed   msgKey = "title.failed"
+	msgTitleEvent    msgKey = "title.event"
+	msgTitleCancel   msgKey = "title.cancelled"
+	msgTitleStart    msgKey = "title.started"
+	msgTitleApproval msgKey = "title.approval"
+	msgTitleError    msgKey = "title.error"
+	msgTitleAborted  msgKey = "title.aborted"
+	msgUnknownTask   msgKey = "title.unknown"
+	msgLabelInput    msgKey = "label.input"
+	msgLabelResult   msgKey = "label.result"
+	msgLabelApproval msgKey = "label.approval"
+	msgLabelError    msgKey = "label.error"
+	msgLabelCwd      msgKey = "label.cwd"
+	msgLabelThread   msgKey = "label.thread"
+	msgLabelGit      msgKey = "label.git"
+	msgLabelMessages msgKey = "label.messages"
+	msgLabelLength   msgKey = "label.length"
+	msgLabelRaw      msgKey = "label.raw"
+	msgLengthValue   msgKey = "value.length"
+	msgEscalation    msgKey = "escalation"
+	msgArchiveLink   msgKey = "link.archive"
+	msgPasteLink     msgKey = "link.paste"
+	msgAttachment    msgKey = "link.attachment"
+	msgTrimmed       msgKey = "note.trimmed"
+	msgOmittedLines  msgKey = "note.omitted_lines"
+	msgAckButton     msgKey = "button.ack"
+	msgEmptyInput    msgKey = "input.empty"
+	msgEmptyResult   msgKey = "result.empty"
+	msgEmptyDetail   msgKey = "result.empty_detail"
+	msgFailureEmpty  msgKey = "result.failure_empty"
+	msgFailureReason msgKey = "result.failure_reason"
+	msgFooterAt      msgKey = "footer.at"
+	msgFooterStarted msgKey = "footer.started"
+	msgJustNow       msgKey = "time.just_now"
+	msgMinutesAgo    msgKey = "time.minutes_ago"
+	msgHoursAgo      msgKey = "time.hours_ago"
+	msgDaysAgo       msgKey = "time.days_ago"
+	msgDigestTitle   msgKey = "digest.title"
+	msgDigestMore    msgKey = "digest.more"
+	msgDigestSince   msgKey = "digest.since"
+)
+
+// defaultLang 默认语言, 保持与早期版本一致
+const defaultLang = "zh"
+
+// defaultAppName 标题与底部署名中默认的 agent 名称
+const defaultAppName = "Codex"
+
+// catalog 多语言消息目录; 新增语言只需添加一组键值, 缺失的键回退到 defaultLang
+// 标题与底部文案的第一个 %s 为 FEISHU_APP_NAME
+var catalog = map[string]map[msgKey]string{
+	"zh": {
+		msgTitleDone:     "%s 任务完成: %s",
+		msgTitleFailed:   "%s 任务失败: %s",
+		msgTitleEvent:    "%s 通知: %s",
+		msgTitleCancel:   "%s 任务已取消: %s",
+		msgTitleStart:    "%s 任务开始: %s",
+		msgTitleApproval: "%s 等待审批: %s",
+		msgTitleError:    "%s 出错: %s",
+		msgTitleAborted:  "%s 任务已中止: %s",
+		msgUnknownTask:   "Unknown Task",
+		msgLabelInput:    "📝 输入指令",
+		msgLabelResult:   "✅ 执行结果",
+		msgLabelApproval: "🔐 待审批操作",
+		msgLabelError:    "❗ 错误信息",
+		msgLabelCwd:      "📂 工作路径",
+		msgLabelThread:   "🆔 Thread ID",
+		msgLabelGit:      "🌿 分支",
+		msgLabelMessages: "💬 消息数",
+		msgLabelLength:   "📏 结果长度",
+		msgLabelRaw:      "🐞 原始通知",
+		msgLengthValue:   "%d 字",
+		msgEscalation:    "🚨 该项目已连续多次失败, 请尽快处理",
+		msgArchiveLink:   "📄 查看完整记录",
+		msgPasteLink:     "查看完整输出",
+		msgAttachment:    "📎 完整输出见附件 %s",
+		msgTrimmed:       "（内容已截断）",
+		msgOmittedLines:  "… (省略 %d 行) …",
+		msgAckButton:     "确认收到",
+		msgEmptyInput:    "（本次任务无输入指令）",
+		msgEmptyResult:   "（无执行结果描述）",
+		msgEmptyDetail:   "（未提供详情）",
+		msgFailureEmpty:  "任务失败，但未提供错误信息",
+		msgFailureReason: "任务失败：%s",
+		msgFooterAt:      "Generated by %s at %s",
+		msgFooterStarted: "Generated by %s · 开始于 %s",
+		msgJustNow:       "刚刚",
+		msgMinutesAgo:    "%d 分钟前",
+		msgHoursAgo:      "%d 小时前",
+		msgDaysAgo:       "%d 天前",
+		msgDigestTitle:   "📊 %s 摘要: %d 个任务, %d 个失败",
+		msgDigestMore:    "… 另有 %d 个任务",
+		msgDigestSince:   "自 %s 以来",
+	},
+	"en": {
+		msgTitleDone:     "%s task completed: %s",
+		msgTitleFailed:   "%s task failed: %s",
+		msgTitleEvent:    "%s notification: %s",
+		msgTitleCancel:   "%s task cancelled: %s",
+		msgTitleStart:    "%s task started: %s",
+		msgTitleApproval: "%s needs approval: %s",
+		msgTitleError:    "%s error: %s",
+		msgTitleAborted:  "%s task aborted: %s",
+		msgUnknownTask:   "Unknown Task",
+		msgLabelInput:    "📝 Input",
+		msgLabelResult:   "✅ Result",
+		msgLabelApproval: "🔐 Pending Approval",
+		msgLabelError:    "❗ Error",
+		msgLabelCwd:      "📂 Working Directory",
+		msgLabelThread:   "🆔 Thread ID",
+		msgLabelGit:      "🌿 Branch",
+		msgLabelMessages: "💬 Messages",
+		msgLabelLength:   "📏 Result Length",
+		msgLabelRaw:      "🐞 Raw Notification",
+		msgLengthValue:   "%d chars",
+		msgEscalation:    "🚨 This project has failed repeatedly, please take a look",
+		msgArchiveLink:   "📄 Full transcript",
+		msgPasteLink:     "View full output",
+		msgAttachment:    "📎 Full output attached as %s",
+		msgTrimmed:       "(content truncated)",
+		msgOmittedLines:  "… (%d lines omitted) …",
+		msgAckButton:     "Acknowledge",
+		msgEmptyInput:    "(no input for this turn)",
+		msgEmptyResult:   "(no result description)",
+		msgEmptyDetail:   "(no details provided)",
+		msgFailureEmpty:  "Task failed without an error message",
+		msgFailureReason: "Task failed: %s",
+		msgFooterAt:      "Generated by %s at %s",
+		msgFooterStarted: "Generated by %s · started %s",
+		msgJustNow:       "just now",
+		msgMinutesAgo:    "%d min ago",
+		msgHoursAgo:      "%d h ago",
+		msgDaysAgo:       "%d days ago",
+		msgDigestTitle:   "📊 %s digest: %d tasks, %d failed",
+		msgDigestMore:    "… and %d more",
+		msgDigestSince:   "Since %s",
+	},
+}
+
+// parseLang 校验 FEISHU_LANG, 为空时返回默认语言
+func parseLang(raw string) (string, error) {
+	lang := strings.ToLower(raw)
+	if lang == "" {
+		return defaultLang, nil
+	}
+	if _, ok := catalog[lang]; !ok {
+		langs := make([]string, 0, len(catalog))
+		for l := range catalog {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		return "", fmt.Errorf("FEISHU_LANG: unsupported language %q (want one of %s)", raw, strings.Join(langs, ", "))
+	}
+	return lang, nil
+}
+
+// t 返回当前语言下的文案
+func (cfg FeishuConfig) t(key msgKey) string {
+	if s, ok := catalog[cfg.Lang][key]; ok {
+		return s
+	}
+	return catalog[defaultLang][key]
+}
+
+// tf 返回格式化后的当前语言文案
+func (cfg FeishuConfig) tf(key msgKey, args ...interface{}) string {
+	return fmt.Sprintf(cfg.t(key), args...)
+}
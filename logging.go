@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logger 全局日志器, 输出到 stderr, 避免与 dry-run 等 stdout 输出混在一起
+var logger = newLogger(os.Stderr, "", "")
+
+// newLogger 根据级别 (debug/info/warn/error) 与格式 (text/json) 创建日志器, 默认 warn + text
+func newLogger(w io.Writer, level, format string) *slog.Logger {
+	var lv slog.Level
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		lv = slog.LevelDebug
+	case "info":
+		lv = slog.LevelInfo
+	case "error":
+		lv = slog.LevelError
+	default:
+		lv = slog.LevelWarn
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// setupLogger 按 FEISHU_LOG_LEVEL / FEISHU_LOG_FORMAT 初始化全局日志器
+func setupLogger() {
+	logger = newLogger(os.Stderr, os.Getenv("FEISHU_LOG_LEVEL"), os.Getenv("FEISHU_LOG_FORMAT"))
+}
+
+// redactWebhook 隐藏 Webhook URL 中的 token (最后一段路径) 与查询参数, 用于日志输出
+func redactWebhook(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid url>"
+	}
+	p := u.Path
+	if i := strings.LastIndex(p, "/"); i >= 0 && i < len(p)-1 {
+		token := p[i+1:]
+		keep := 4
+		if len(token) <= keep {
+			keep = 0
+		}
+		p = p[:i+1] + token[:keep] + "***"
+	}
+	return u.Scheme + "://" + u.Host + p
+}
+
+// redactQueryToken 隐藏以查询参数携带令牌的 Webhook (如钉钉的 access_token、企业微信的 key), 只保留前 4 位;
+// 没有该参数时按路径令牌处理
+func redactQueryToken(raw, param string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid url>"
+	}
+	token := u.Query().Get(param)
+	if token == "" {
+		return redactWebhook(raw)
+	}
+	keep := 4
+	if len(token) <= keep {
+		keep = 0
+	}
+	return u.Scheme + "://" + u.Host + u.Path + "?" + param + "=" + token[:keep] + "***"
+}
+
+// newRequestID 生成 16 字节随机十六进制请求 ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// resolveRequestID 优先使用 Codex 通过 CODEX_REQUEST_ID 传入的请求 ID, 否则生成新的
+func resolveRequestID() string {
+	if id := strings.TrimSpace(os.Getenv("CODEX_REQUEST_ID")); id != "" {
+		return id
+	}
+	return newRequestID()
+}
+
+// attachRequestID 让后续日志都带上请求 ID; FEISHU_QUIET 时只保留 error 级别日志
+func attachRequestID(cfg FeishuConfig) {
+	applyQuiet(cfg)
+	logger = logger.With("requestID", cfg.RequestID)
+}
+
+// applyQuiet FEISHU_QUIET 时将日志器切换为只输出 error 级别
+func applyQuiet(cfg FeishuConfig) {
+	if cfg.Quiet {
+		logger = newLogger(os.Stderr, "error", os.Getenv("FEISHU_LOG_FORMAT"))
+	}
+}
+
+// printf 输出非错误的提示信息 (如汇总行), FEISHU_QUIET 时不输出
+func (cfg FeishuConfig) printf(w io.Writer, format string, args ...interface{}) {
+	if cfg.Quiet {
+		return
+	}
+	fmt.Fprintf(w, format, args...)
+}
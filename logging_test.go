@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// captureLogger 将全局 logger 替换为写入缓冲区的 JSON 日志器, 测试结束后恢复
+func captureLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := logger
+	logger = newLogger(&buf, "info", "json")
+	t.Cleanup(func() { logger = saved })
+	return &buf
+}
+
+func TestRequestIDFromCodex(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": srv.URL + "/hook",
+		"CODEX_REQUEST_ID":   " trace-1234 ",
+	})
+	if cfg.RequestID != "trace-1234" {
+		t.Fatalf("RequestID = %q, want trace-1234", cfg.RequestID)
+	}
+	logs := captureLogger(t)
+	attachRequestID(cfg)
+	if _, err := processNotification(context.Background(), cfg, testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 || (*got)[0].Header.Get("X-Request-Id") != "trace-1234" {
+		t.Fatalf("X-Request-Id not propagated: %d requests", len(*got))
+	}
+	var entry map[string]interface{}
+	line, _, _ := bytes.Cut(logs.Bytes(), []byte("\n"))
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("log line %q: %v", line, err)
+	}
+	if entry["requestID"] != "trace-1234" {
+		t.Errorf("log requestID = %v, want trace-1234", entry["requestID"])
+	}
+}
+
+func TestRequestIDFallback(t *testing.T) {
+	hexID := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	a := testConfig(t, nil).RequestID
+	b := testConfig(t, map[string]string{"CODEX_REQUEST_ID": "   "}).RequestID
+	if !hexID.MatchString(a) || !hexID.MatchString(b) {
+		t.Errorf("generated ids %q, %q are not 32 hex characters", a, b)
+	}
+	if a == b {
+		t.Error("generated ids are not unique")
+	}
+}
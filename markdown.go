@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// resultSegment 是执行结果中的一段正文或围栏代码块
+type resultSegment struct {
+	Code bool
+	Lang string
+	Text string
+}
+
+// splitFencedCode 按 ``` 围栏将文本拆分为正文与代码块, 未闭合的围栏视为延续到结尾
+func splitFencedCode(s string) []resultSegment {
+	var (
+		segments []resultSegment
+		buf      []string
+		inCode   bool
+		lang     string
+	)
+	flush := func() {
+		text := strings.Join(buf, "\n")
+		if inCode || strings.TrimSpace(text) != "" {
+			if !inCode {
+				text = strings.Trim(text, "\n")
+			}
+			segments = append(segments, resultSegment{Code: inCode, Lang: lang, Text: text})
+		}
+		buf = nil
+	}
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			if inCode {
+				inCode, lang = false, ""
+			} else {
+				inCode, lang = true, strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			}
+			continue
+		}
+		buf = append(buf, line)
+	}
+	flush()
+	return segments
+}
+
+// resultElements 将带标题的执行结果渲染为卡片元素
+// 不含代码块时保持单个 lark_md div; 含代码块时正文与代码交替输出, 代码块使用 markdown 元素渲染
+func resultElements(label, content string) []interface{} {
+	segments := splitFencedCode(content)
+	hasCode := false
+	for _, seg := range segments {
+		hasCode = hasCode || seg.Code
+	}
+	if !hasCode {
+		return []interface{}{FeishuDiv{
+			Tag:  "div",
+			Text: &FeishuText{Tag: "lark_md", Content: fmt.Sprintf("%s\n%s", label, content)},
+		}}
+	}
+
+	var elements []interface{}
+	if segments[0].Code {
+		elements = append(elements, FeishuDiv{
+			Tag:  "div",
+			Text: &FeishuText{Tag: "lark_md", Content: label},
+		})
+	}
+	for i, seg := range segments {
+		if seg.Code {
+			elements = append(elements, FeishuMarkdown{
+				Tag:     "markdown",
+				Content: fmt.Sprintf("```%s\n%s\n```", seg.Lang, seg.Text),
+			})
+			continue
+		}
+		text := seg.Text
+		if i == 0 {
+			text = fmt.Sprintf("%s\n%s", label, text)
+		}
+		elements = append(elements, FeishuDiv{
+			Tag:  "div",
+			Text: &FeishuText{Tag: "lark_md", Content: text},
+		})
+	}
+	return elements
+}
+
+// markdownReport 将通知渲染为通用 Markdown 文本, 供只支持 Markdown 消息的平台 (钉钉、企业微信等) 共用:
+// 标题、输入指令、执行结果 (或对应类型的详情)、字段与底部信息, 各段以空行分隔
+func markdownReport(n CodexNotification, cfg FeishuConfig, failed bool) string {
+	sections := []string{"### " + cardTitle(n, cfg, failed)}
+	if cfg.Escalated {
+		sections = append(sections, fmt.Sprintf("**%s**", cfg.t(msgEscalation)))
+	}
+	sections = append(sections, fmt.Sprintf("**%s**\n%s", cfg.t(msgLabelInput), inputText(n, cfg, "\n")))
+	if label, text := eventBody(n, cfg, failed); label != "" {
+		lines := []string{fmt.Sprintf("**%s**\n%s", cfg.t(label), text)}
+		if cfg.PayloadTrimmed {
+			lines = append(lines, cfg.t(msgTrimmed))
+		}
+		lines = append(lines, resultLinks(cfg)...)
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	var fields []string
+	for _, f := range cardFields(n, cfg) {
+		fields = append(fields, fmt.Sprintf("**%s:** %s", f.Label, f.Value))
+	}
+	sections = append(sections, strings.Join(fields, "\n"))
+	var footer []string
+	for _, t := range buildFooter(n, cfg, time.Now()).Elements {
+		footer = append(footer, t.Content)
+	}
+	if len(footer) > 0 {
+		sections = append(sections, "> "+strings.Join(footer, " · "))
+	}
+	if cfg.DebugRaw {
+		sections = append(sections, fmt.Sprintf("**%s**\n%s", cfg.t(msgLabelRaw), debugCodeBlock(n)))
+	}
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFencedCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []resultSegment
+	}{
+		{"plain text", []resultSegment{{Text: "plain text"}}},
+		{
+			"Fixed the bug:\n\n```go\nfunc f() {}\n```\n\nAll tests pass.",
+			[]resultSegment{
+				{Text: "Fixed the bug:"},
+				{Code: true, Lang: "go", Text: "func f() {}"},
+				{Text: "All tests pass."},
+			},
+		},
+		{"```\nonly code\n\n  indented\n```", []resultSegment{{Code: true, Text: "only code\n\n  indented"}}},
+		{"before\n```sh\nmake test", []resultSegment{{Text: "before"}, {Code: true, Lang: "sh", Text: "make test"}}},
+		{"```\n```", []resultSegment{{Code: true, Text: ""}}},
+	}
+	for _, tt := range tests {
+		if got := splitFencedCode(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitFencedCode(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 正文与代码交替时, 正文为 lark_md div, 代码块为 markdown 元素
+func TestResultElementsMixed(t *testing.T) {
+	content := "Fixed the bug:\n```go\nfunc f() {}\n```\nAll tests pass."
+	got := resultElements("**结果:**", content)
+	want := []interface{}{
+		FeishuDiv{Tag: "div", Text: &FeishuText{Tag: "lark_md", Content: "**结果:**\nFixed the bug:"}},
+		FeishuMarkdown{Tag: "markdown", Content: "```go\nfunc f() {}\n```"},
+		FeishuDiv{Tag: "div", Text: &FeishuText{Tag: "lark_md", Content: "All tests pass."}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resultElements = %+v, want %+v", got, want)
+	}
+}
+
+// 整条结果都是代码块时, 标题单独一行, 代码以代码块展示而不是转义后的行内文本
+func TestResultElementsOnlyCode(t *testing.T) {
+	got := resultElements("**结果:**", "```json\n{\"ok\": true}\n```")
+	want := []interface{}{
+		FeishuDiv{Tag: "div", Text: &FeishuText{Tag: "lark_md", Content: "**结果:**"}},
+		FeishuMarkdown{Tag: "markdown", Content: "```json\n{\"ok\": true}\n```"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resultElements = %+v, want %+v", got, want)
+	}
+}
+
+func TestResultElementsWithoutCode(t *testing.T) {
+	got := resultElements("**结果:**", "line one\nline two")
+	want := []interface{}{
+		FeishuDiv{Tag: "div", Text: &FeishuText{Tag: "lark_md", Content: "**结果:**\nline one\nline two"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resultElements = %+v, want %+v", got, want)
+	}
+}
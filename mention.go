@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ================= 关键词触发的 @ 提醒 =================
+// FEISHU_MENTION_ON_KEYWORDS="失败=ou_a|ou_b,error=ou_c": 执行结果包含关键词 (不区分大小写) 时 @ 对应用户
+
+// keywordMention 关键词与需要 @ 的 open_id
+type keywordMention struct {
+	Keyword string
+	IDs     []string
+}
+
+// parseKeywordMentions 解析 keyword=id1|id2 形式的映射列表
+func parseKeywordMentions(items []string) ([]keywordMention, error) {
+	var out []keywordMention
+	for _, item := range items {
+		keyword, ids, ok := strings.Cut(item, "=")
+		keyword = strings.TrimSpace(keyword)
+		if !ok || keyword == "" {
+			return nil, fmt.Errorf("FEISHU_MENTION_ON_KEYWORDS: invalid entry %q (want keyword=open_id|open_id)", item)
+		}
+		var m keywordMention
+		m.Keyword = strings.ToLower(keyword)
+		for _, id := range strings.Split(ids, "|") {
+			if id = strings.TrimSpace(id); id != "" {
+				m.IDs = append(m.IDs, id)
+			}
+		}
+		if len(m.IDs) == 0 {
+			return nil, fmt.Errorf("FEISHU_MENTION_ON_KEYWORDS: keyword %q has no open_id", keyword)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// keywordMentions 返回需要在卡片顶部 @ 的 open_id: 先是按邮箱解析出的用户,
+// 再是执行结果命中的关键词对应的用户, 按配置顺序去重; 升级告警已 @ 的用户不再重复提醒
+func keywordMentions(n CodexNotification, cfg FeishuConfig) []string {
+	if len(cfg.MentionOnKeywords) == 0 && len(cfg.EmailMentions) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	if cfg.Escalated {
+		for _, id := range cfg.EscalateMention {
+			seen[id] = true
+		}
+	}
+	var ids []string
+	add := func(candidates []string) {
+		for _, id := range candidates {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	add(cfg.EmailMentions)
+	result := strings.ToLower(n.LastAssistantMessage)
+	for _, m := range cfg.MentionOnKeywords {
+		if strings.Contains(result, m.Keyword) {
+			add(m.IDs)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ================= 按邮箱 @ 用户 =================
+// FEISHU_MENTION_EMAILS="a@example.com,b@example.com": 每张卡片都 @ 这些用户。
+// 邮箱需通过通讯录接口 (POST /open-apis/contact/v3/users/batch_get_id) 换成 open_id,
+// 因此只有自建应用方式可用; 解析结果缓存在状态目录, 避免每次发送都查询。
+
+// mentionCacheTTL 邮箱到 open_id 映射的缓存有效期
+const mentionCacheTTL = 7 * 24 * time.Hour
+
+// resolvedMention 缓存的一条邮箱映射
+type resolvedMention struct {
+	OpenID     string `json:"open_id"`
+	ResolvedAt int64  `json:"resolved_at"`
+}
+
+func (a *appNotifier) mentionCachePath() string {
+	return filepath.Join(stateDir(a.cfg), "mention_ids.json")
+}
+
+// batchGetOpenIDs 调用通讯录接口批量查询邮箱对应的 open_id, 未找到的邮箱不在结果中
+func (a *appNotifier) batchGetOpenIDs(ctx context.Context, emails []string) (map[string]string, error) {
+	body, err := json.Marshal(map[string][]string{"emails": emails})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := a.cfg.OpenAPIBase + "/open-apis/contact/v3/users/batch_get_id?user_id_type=open_id"
+	data, err := a.callWithToken(ctx, "POST", endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("resolve mention emails: %w", err)
+	}
+	var resp struct {
+		UserList []struct {
+			Email  string `json:"email"`
+			UserID string `json:"user_id"`
+		} `json:"user_list"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("resolve mention emails: %w (payload: %s)", err, string(data))
+	}
+	ids := map[string]string{}
+	for _, u := range resp.UserList {
+		if u.UserID != "" {
+			ids[strings.ToLower(u.Email)] = u.UserID
+		}
+	}
+	return ids, nil
+}
+
+// resolveMentionEmails 返回 FEISHU_MENTION_EMAILS 对应的 open_id, 按配置顺序;
+// 优先使用缓存, 只查询缺失或过期的邮箱。查询失败时只返回缓存中已有的结果
+func (a *appNotifier) resolveMentionEmails(ctx context.Context, now time.Time) []string {
+	cache := map[string]resolvedMention{}
+	if err := readStateFile(a.mentionCachePath(), &cache); err != nil {
+		logger.Debug("ignoring unreadable mention cache", "err", err)
+		cache = map[string]resolvedMention{}
+	}
+	cutoff := now.Add(-mentionCacheTTL).Unix()
+	var missing []string
+	for _, email := range a.cfg.MentionEmails {
+		key := strings.ToLower(email)
+		if m, ok := cache[key]; !ok || m.ResolvedAt < cutoff {
+			missing = append(missing, email)
+		}
+	}
+
+	if len(missing) > 0 {
+		resolved, err := a.batchGetOpenIDs(ctx, missing)
+		if err != nil {
+			logger.Warn("resolve mention emails failed, using cached ids only", "err", err)
+		} else {
+			for _, email := range missing {
+				key := strings.ToLower(email)
+				id, ok := resolved[key]
+				if !ok {
+					// 未解析的邮箱不缓存, 下次仍会重新查询
+					logger.Warn("no Feishu user found for mention email", "email", email)
+					delete(cache, key)
+					continue
+				}
+				cache[key] = resolvedMention{OpenID: id, ResolvedAt: now.Unix()}
+			}
+			if err := writeStateFile(a.mentionCachePath(), cache); err != nil {
+				logger.Debug("write mention cache failed", "err", err)
+			}
+		}
+	}
+
+	var ids []string
+	for _, email := range a.cfg.MentionEmails {
+		if m, ok := cache[strings.ToLower(email)]; ok {
+			ids = append(ids, m.OpenID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pathBatchGetID = "/open-apis/contact/v3/users/batch_get_id"
+
+// mentionStub 模拟通讯录接口: a@example.com 对应 ou_a, 其余邮箱查不到
+func mentionStub(t *testing.T, env map[string]string) (*appNotifier, *[]openAPICall) {
+	t.Helper()
+	srv, calls := openAPIStub(t, map[string]string{
+		"POST " + pathBatchGetID: `{"code":0,"data":{"user_list":[{"email":"a@example.com","user_id":"ou_a"},{"email":"b@example.com"}]}}`,
+		"POST " + pathMessages:   `{"code":0,"data":{"message_id":"om_1"}}`,
+	})
+	if env == nil {
+		env = map[string]string{}
+	}
+	env["FEISHU_MENTION_EMAILS"] = "A@example.com,b@example.com"
+	return newTestAppNotifier(t, srv, env), calls
+}
+
+// queriedEmails 返回一次通讯录查询请求中的邮箱
+func queriedEmails(t *testing.T, call openAPICall) []string {
+	t.Helper()
+	var body struct {
+		Emails []string `json:"emails"`
+	}
+	if err := json.Unmarshal(call.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Emails
+}
+
+func TestResolveMentionEmailsCached(t *testing.T) {
+	a, calls := mentionStub(t, nil)
+	now := time.Now()
+
+	if ids := a.resolveMentionEmails(context.Background(), now); !reflect.DeepEqual(ids, []string{"ou_a"}) {
+		t.Fatalf("ids = %q, want [ou_a]", ids)
+	}
+	// 已解析的邮箱走缓存, 只重新查询未找到的邮箱
+	if ids := a.resolveMentionEmails(context.Background(), now.Add(time.Hour)); !reflect.DeepEqual(ids, []string{"ou_a"}) {
+		t.Fatalf("cached ids = %q", ids)
+	}
+	lookups := callsTo(*calls, "POST", pathBatchGetID)
+	if len(lookups) != 2 {
+		t.Fatalf("got %d lookups, want 2", len(lookups))
+	}
+	if got := queriedEmails(t, lookups[0]); !reflect.DeepEqual(got, []string{"A@example.com", "b@example.com"}) {
+		t.Errorf("first lookup = %q", got)
+	}
+	if got := queriedEmails(t, lookups[1]); !reflect.DeepEqual(got, []string{"b@example.com"}) {
+		t.Errorf("second lookup = %q, want only the unresolved email", got)
+	}
+
+	// 超过有效期后重新查询
+	a.resolveMentionEmails(context.Background(), now.Add(mentionCacheTTL+time.Hour))
+	lookups = callsTo(*calls, "POST", pathBatchGetID)
+	if got := queriedEmails(t, lookups[len(lookups)-1]); len(got) != 2 {
+		t.Errorf("lookup after expiry = %q, want both emails", got)
+	}
+}
+
+// 查询失败时退回缓存中已有的结果
+func TestResolveMentionEmailsAPIFailure(t *testing.T) {
+	srv, _ := openAPIStub(t, map[string]string{
+		"POST " + pathBatchGetID: `{"code":99991672,"msg":"no permission"}`,
+	})
+	a := newTestAppNotifier(t, srv, map[string]string{"FEISHU_MENTION_EMAILS": "a@example.com,c@example.com"})
+	cache := map[string]resolvedMention{"a@example.com": {OpenID: "ou_a", ResolvedAt: time.Now().Unix()}}
+	if err := writeStateFile(a.mentionCachePath(), cache); err != nil {
+		t.Fatal(err)
+	}
+	if ids := a.resolveMentionEmails(context.Background(), time.Now()); !reflect.DeepEqual(ids, []string{"ou_a"}) {
+		t.Errorf("ids = %q, want the cached ou_a", ids)
+	}
+}
+
+func TestMentionEmailsInCard(t *testing.T) {
+	a, calls := mentionStub(t, nil)
+	if err := a.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	msgs := callsTo(*calls, "POST", pathMessages)
+	if len(msgs) != 1 || !strings.Contains(string(msgs[0].Body), "ou_a") {
+		t.Errorf("card does not mention ou_a: %+v", msgs)
+	}
+}
+
+func TestMentionEmailsRequireApp(t *testing.T) {
+	logs := captureLogger(t)
+	testConfig(t, map[string]string{"FEISHU_MENTION_EMAILS": "a@example.com"})
+	if !strings.Contains(logs.String(), "FEISHU_MENTION_EMAILS requires FEISHU_BACKEND=app") {
+		t.Errorf("no warning for email mentions on the webhook backend:\n%s", logs)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ================= 运行指标 =================
+// 每次调用都是短生命周期进程, 指标按单次运行统计: 请求次数、重试次数、发送字节数与最终状态。
+// 退出前输出一条 info 级别的结构化日志; 配置了 FEISHU_METRICS_FILE 时再追加一行 JSON,
+// 由外部聚合得到趋势。写指标失败只记录警告, 不影响退出码。
+
+// runMetrics 本次运行的计数器, 发送路径上直接累加
+var runMetrics = metrics{start: time.Now()}
+
+type metrics struct {
+	start     time.Time
+	attempts  atomic.Int64 // 发出的 HTTP 请求数 (含重试)
+	retries   atomic.Int64 // 重试次数
+	bytesSent atomic.Int64 // 请求体字节数 (压缩后)
+}
+
+// metricsRecord 写入 FEISHU_METRICS_FILE 的一行记录
+type metricsRecord struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Status     string    `json:"status"`
+	Sent       int       `json:"sent"`
+	Skipped    int       `json:"skipped"`
+	Queued     int       `json:"queued"`
+	Failed     int       `json:"failed"`
+	Attempts   int64     `json:"attempts"`
+	Retries    int64     `json:"retries"`
+	BytesSent  int64     `json:"bytes_sent"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// record 汇总本次运行的指标; status 为 ok / failed / interrupted
+func (m *metrics) record(cfg FeishuConfig, report runReport, status string) metricsRecord {
+	return metricsRecord{
+		Time:       time.Now().UTC(),
+		RequestID:  cfg.RequestID,
+		Status:     status,
+		Sent:       report.Sent,
+		Skipped:    report.Skipped,
+		Queued:     report.Queued,
+		Failed:     report.Failed,
+		Attempts:   m.attempts.Load(),
+		Retries:    m.retries.Load(),
+		BytesSent:  m.bytesSent.Load(),
+		DurationMS: time.Since(m.start).Milliseconds(),
+	}
+}
+
+// emitMetrics 输出本次运行的指标日志, 并按需追加到指标文件
+func emitMetrics(cfg FeishuConfig, report runReport, status string) {
+	rec := runMetrics.record(cfg, report, status)
+	logger.Info("run metrics", "status", rec.Status, "sent", rec.Sent, "skipped", rec.Skipped, "queued", rec.Queued, "failed", rec.Failed,
+		"attempts", rec.Attempts, "retries", rec.Retries, "bytesSent", rec.BytesSent, "durationMS", rec.DurationMS)
+	if cfg.MetricsFile == "" {
+		return
+	}
+	if err := appendMetrics(cfg.MetricsFile, rec); err != nil {
+		logger.Warn("write metrics file", "file", cfg.MetricsFile, "err", err)
+	}
+}
+
+// appendMetrics 以 O_APPEND 方式整行写入一条 JSON, 多个进程同时追加也不会互相覆盖
+func appendMetrics(path string, rec metricsRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ================= sink 注册表 =================
+// 每个发送端 (飞书 Webhook、自建应用等) 实现 Notifier, 在 init 中通过 registerNotifier 注册构造函数;
+// 发送流程只依赖注册表, 新增平台只需新增一个文件, 不需要修改发送流程。
+// FEISHU_BACKEND 可选的取值同样由各实现通过 registerBackend 注册。
+
+// Notifier 是通知发送端 (sink) 的统一接口
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, n CodexNotification) error
+}
+
+// notifierFactory 根据配置构造 sink; 同一后端可配置多个目标 (如多个 Webhook), 每个目标一个 Notifier
+// 对应的环境变量未设置时返回 nil, nil
+type notifierFactory func(cfg FeishuConfig) ([]Notifier, error)
+
+var (
+	notifierFactories = map[string]notifierFactory{}
+	notifierOrder     []string
+	backends          = map[string]backendTarget{}
+)
+
+// backendTarget 将路由规则中的 Webhook 与 Secret 写入该后端的配置, 为 nil 表示该后端不能作为路由目标
+type backendTarget func(cfg *FeishuConfig, webhook, secret string)
+
+// registerBackend 注册 FEISHU_BACKEND 的一个可选取值, 由对应 sink 的工厂函数根据 cfg.Backend 决定是否启用
+func registerBackend(name string, target backendTarget) {
+	if _, dup := backends[name]; dup {
+		panic("backend registered twice: " + name)
+	}
+	backends[name] = target
+}
+
+// parseBackend 校验 FEISHU_BACKEND, 为空时使用默认的 Webhook 方式
+func parseBackend(raw string) (string, error) {
+	backend := strings.ToLower(raw)
+	if backend == "" {
+		return backendWebhook, nil
+	}
+	if _, ok := backends[backend]; ok {
+		return backend, nil
+	}
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("FEISHU_BACKEND: unknown backend %q (want one of %s)", raw, strings.Join(names, ", "))
+}
+
+// registerNotifier 注册一个 sink, 按注册顺序发送
+func registerNotifier(name string, factory notifierFactory) {
+	if _, dup := notifierFactories[name]; dup {
+		panic("notifier registered twice: " + name)
+	}
+	notifierFactories[name] = factory
+	notifierOrder = append(notifierOrder, name)
+}
+
+// configuredNotifiers 返回当前配置下启用的所有 sink
+func configuredNotifiers(cfg FeishuConfig) ([]Notifier, error) {
+	var notifiers []Notifier
+	for _, name := range notifierOrder {
+		ns, err := notifierFactories[name](cfg)
+		if err != nil {
+			// 严格模式下任一 sink 配置有误即失败, 否则跳过该 sink 继续使用其它 sink
+			if cfg.MultiBackendStrict {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			logger.Warn("skipping misconfigured sink", "sink", name, "err", err)
+			continue
+		}
+		notifiers = append(notifiers, ns...)
+	}
+	if len(notifiers) == 0 {
+		return nil, errors.New("no usable sink configured (set FEISHU_WEBHOOK_URL, or pick another FEISHU_BACKEND and set its options)")
+	}
+	return notifiers, nil
+}
+
+// dispatch 将通知发送到所有 sink, 单个 sink 失败不影响其它 sink, 返回各 sink 的结果与汇总错误
+// 最多 concurrency 个 sink 同时发送; 结果与错误仍按 sink 顺序排列, 与串行发送一致
+func dispatch(ctx context.Context, notifiers []Notifier, n CodexNotification, concurrency int) ([]sinkResult, error) {
+	errs := make([]error, len(notifiers))
+	if concurrency <= 1 || len(notifiers) <= 1 {
+		for i, notifier := range notifiers {
+			errs[i] = notifier.Send(ctx, n)
+		}
+	} else {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for i, notifier := range notifiers {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, notifier Notifier) {
+				defer func() { <-sem; wg.Done() }()
+				errs[i] = notifier.Send(ctx, n)
+			}(i, notifier)
+		}
+		wg.Wait()
+	}
+
+	results := make([]sinkResult, len(notifiers))
+	var joined []error
+	for i, notifier := range notifiers {
+		results[i] = newSinkResult(notifier, errs[i])
+		if errs[i] != nil {
+			joined = append(joined, fmt.Errorf("%s: %w", notifier.Name(), errs[i]))
+		}
+	}
+	return results, errors.Join(joined...)
+}
+
+// notify 按配置构造 sink 并发送通知
+func notify(ctx context.Context, cfg FeishuConfig, n CodexNotification) error {
+	cfg = withPasteLink(ctx, n, cfg)
+	notifiers, err := configuredNotifiers(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = dispatch(ctx, notifiers, n, cfg.Concurrency)
+	return err
+}
+
+// ================= Feishu Webhook sink =================
+
+func init() {
+	registerBackend(backendWebhook, func(cfg *FeishuConfig, webhook, secret string) {
+		cfg.WebhookURL, cfg.Secret = webhook, secret
+		cfg.WebhookURLs, cfg.WebhookSecrets = nil, nil
+	})
+	registerNotifier("feishu", newFeishuNotifier)
+}
+
+type feishuNotifier struct {
+	name   string // sink 名称, 多个 Webhook 时为 feishu、feishu-2、feishu-3 ...
+	cfg    FeishuConfig
+	client doer
+	now    func() time.Time // 签名时间戳的时钟
+}
+
+// dryRunWebhookURL 未配置 Webhook 时 dry-run 使用的占位地址, 不会被请求
+const dryRunWebhookURL = "https://open.feishu.cn/open-apis/bot/v2/hook/dry-run"
+
+// newFeishuNotifier 为 FEISHU_WEBHOOK_URL 中的每个 Webhook 构造一个 sink, 各自使用对应的签名 Secret
+func newFeishuNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendWebhook {
+		return nil, nil
+	}
+	targets := cfg.webhookTargets()
+	if len(targets) == 0 && cfg.DryRun {
+		// dry-run 不发送请求, 没有配置 Webhook 时也输出卡片, 便于离线调试模板
+		targets = []webhookTarget{{URL: dryRunWebhookURL}}
+	}
+	var notifiers []Notifier
+	for i, t := range targets {
+		if err := validateWebhookURL(t.URL); err != nil {
+			return nil, err
+		}
+		name := "feishu"
+		if i > 0 {
+			name = fmt.Sprintf("feishu-%d", i+1)
+		}
+		c := cfg
+		c.WebhookURL, c.Secret, c.WebhookURLs, c.WebhookSecrets = t.URL, t.Secret, nil, nil
+		notifiers = append(notifiers, &feishuNotifier{name: name, cfg: c, client: newHTTPClient(c), now: time.Now})
+	}
+	return notifiers, nil
+}
+
+// webhookTarget 一个 Webhook 及其签名 Secret
+type webhookTarget struct {
+	URL    string
+	Secret string
+}
+
+// webhookTargets 返回需要发送的全部 Webhook; 只配置一个 Secret 时所有 Webhook 共用
+func (cfg FeishuConfig) webhookTargets() []webhookTarget {
+	if len(cfg.WebhookURLs) == 0 {
+		if cfg.WebhookURL == "" {
+			return nil
+		}
+		return []webhookTarget{{URL: cfg.WebhookURL, Secret: cfg.Secret}}
+	}
+	targets := make([]webhookTarget, len(cfg.WebhookURLs))
+	for i, u := range cfg.WebhookURLs {
+		secret := cfg.Secret
+		if i < len(cfg.WebhookSecrets) {
+			secret = cfg.WebhookSecrets[i]
+		}
+		targets[i] = webhookTarget{URL: u, Secret: secret}
+	}
+	return targets
+}
+
+// validateWebhookURL 校验 Webhook 为带主机名的 http(s) 地址
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: want an absolute http(s) URL", redactWebhook(raw))
+	}
+	return nil
+}
+
+func (f *feishuNotifier) Name() string { return f.name }
+
+func (f *feishuNotifier) Target() string { return redactWebhook(f.cfg.WebhookURL) }
+
+func (f *feishuNotifier) Send(ctx context.Context, n CodexNotification) error {
+	return sendFeishuCard(ctx, f.client, f.now, n, f.cfg)
+}
+
+// ================= 第三方平台 Webhook 的公共发送逻辑 =================
+
+// thirdPartyResponseLimit 读取第三方平台响应体的上限
+const thirdPartyResponseLimit = 64 << 10
+
+// postJSON 向第三方平台的 Webhook 发送 JSON 请求, 遇到可重试错误时按配置重试;
+// 非 2xx 状态码返回 HTTPStatusError, check 非 nil 时再校验 2xx 的响应体 (平台在响应体中报告的错误)
+// 请求只带内容类型、User-Agent 与 headers (该平台自身的请求头, 如 ntfy 的令牌), 不压缩请求体。
+// display 是隐藏了令牌的地址, 用于日志与网络错误信息, 避免令牌出现在日志与死信中
+func postJSON(ctx context.Context, client doer, cfg FeishuConfig, endpoint, display string, headers http.Header, payload []byte, check func(body []byte) error) error {
+	return withRetry(ctx, cfg, func() error {
+		req, err := newThirdPartyRequest(ctx, endpoint, headers, payload)
+		if err != nil {
+			return err
+		}
+		logger.Debug("sending notification", "endpoint", display, "bytes", len(payload))
+		runMetrics.attempts.Add(1)
+		runMetrics.bytesSent.Add(int64(len(payload)))
+		resp, err := client.Do(req)
+		if err != nil {
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				urlErr.URL = display
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, thirdPartyResponseLimit))
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Body:       string(body),
+				RetryAfter: serverRetryAfter(resp.Header, time.Now()),
+			}
+		}
+		if check != nil {
+			return check(body)
+		}
+		return nil
+	})
+}
+
+// newThirdPartyRequest 构造发往第三方平台的 POST 请求。FEISHU_EXTRA_HEADERS 通常是飞书网关的凭据,
+// 与请求 ID、幂等键、负载签名一样只发给飞书, 不随 ntfy、Pushover 等并行发送的 sink 泄露给其他服务
+func newThirdPartyRequest(ctx context.Context, endpoint string, headers http.Header, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", binaryName+"/"+version)
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// PlatformError 表示第三方平台在 2xx 响应体中报告的错误 (如钉钉、企业微信的 errcode)
+type PlatformError struct {
+	Platform    string
+	Code        int
+	Msg         string
+	RateLimited bool // 平台的频率限制错误, 可重试
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.Platform, e.Code, e.Msg)
+}
+
+// errcodeChecker 返回校验 {"errcode":0,"errmsg":"ok"} 形式响应体的函数, rateLimitCodes 中的错误码可重试
+func errcodeChecker(platform string, rateLimitCodes map[int]bool) func(body []byte) error {
+	return func(body []byte) error {
+		var resp struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("decode %s response: %w (payload: %s)", platform, err, string(body))
+		}
+		if resp.ErrCode != 0 {
+			return &PlatformError{Platform: platform, Code: resp.ErrCode, Msg: resp.ErrMsg, RateLimited: rateLimitCodes[resp.ErrCode]}
+		}
+		return nil
+	}
+}
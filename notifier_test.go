@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConfig 在隔离的环境中按 env 加载配置, 见 isolateEnv
+func testConfig(t *testing.T, env map[string]string) FeishuConfig {
+	t.Helper()
+	isolateEnv(t, env)
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	return cfg
+}
+
+// isolateEnv 清除 FEISHU_* 与 CODEX_* 变量和命令行覆盖, 不读取用户的配置文件,
+// 状态文件写入临时目录, 再按 env 设置变量; 测试结束后恢复
+func isolateEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "FEISHU_") || strings.HasPrefix(name, "CODEX_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("FEISHU_ENV_FILE", "")
+	t.Setenv("FEISHU_STATE_DIR", t.TempDir())
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	savedFlags, savedDotEnv := flagOverrides, dotEnvKeys
+	flagOverrides, dotEnvKeys = map[string]string{}, map[string]bool{}
+	t.Cleanup(func() { flagOverrides, dotEnvKeys = savedFlags, savedDotEnv })
+}
+
+// testNotification 返回一条普通的 agent-turn-complete 通知
+func testNotification() CodexNotification {
+	return CodexNotification{
+		Type:                 "agent-turn-complete",
+		ThreadID:             "thread-1",
+		TurnID:               "turn-1",
+		Cwd:                  "/work/demo",
+		InputMessages:        []string{"修复登录页面的样式问题"},
+		LastAssistantMessage: "已修复, 所有测试通过。",
+	}
+}
+
+// capturedRequest 测试服务器收到的请求
+type capturedRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// feishuStub 启动模拟飞书 Webhook 的服务器, 记录收到的请求并按 status 与 body 响应
+func feishuStub(t *testing.T, status int, body string) (*httptest.Server, *[]capturedRequest) {
+	t.Helper()
+	var got []capturedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, capturedRequest{Header: r.Header.Clone(), Body: b})
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+// newTestFeishuNotifier 构造发往 srv 的飞书 sink, 签名时钟固定为 now
+func newTestFeishuNotifier(t *testing.T, srv *httptest.Server, env map[string]string, now time.Time) *feishuNotifier {
+	t.Helper()
+	if env == nil {
+		env = map[string]string{}
+	}
+	env["FEISHU_WEBHOOK_URL"] = srv.URL + "/open-apis/bot/v2/hook/test"
+	env["FEISHU_MAX_RETRIES"] = "0"
+	notifiers, err := newFeishuNotifier(testConfig(t, env))
+	if err != nil {
+		t.Fatalf("newFeishuNotifier: %v", err)
+	}
+	if len(notifiers) != 1 {
+		t.Fatalf("got %d notifiers, want 1", len(notifiers))
+	}
+	f := notifiers[0].(*feishuNotifier)
+	f.client = srv.Client()
+	f.now = func() time.Time { return now }
+	return f
+}
+
+func TestSendFeishuCardBody(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	f := newTestFeishuNotifier(t, srv, nil, time.Unix(1700000000, 0))
+
+	if err := f.Send(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	req := (*got)[0]
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var body struct {
+		Timestamp *string `json:"timestamp"`
+		Sign      *string `json:"sign"`
+		MsgType   string  `json:"msg_type"`
+		Card      struct {
+			Header struct {
+				Title    FeishuText `json:"title"`
+				Template string     `json:"template"`
+			} `json:"header"`
+			Elements []map[string]interface{} `json:"elements"`
+		} `json:"card"`
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatalf("body is not valid JSON: %v\n%s", err, req.Body)
+	}
+	if body.MsgType != "interactive" {
+		t.Errorf("msg_type = %q, want interactive", body.MsgType)
+	}
+	if body.Timestamp != nil || body.Sign != nil {
+		t.Errorf("timestamp/sign present without a secret: %s", req.Body)
+	}
+	if !strings.Contains(body.Card.Header.Title.Content, "修复登录页面") {
+		t.Errorf("header title = %q, want it to contain the task", body.Card.Header.Title.Content)
+	}
+	if body.Card.Header.Template == "" {
+		t.Error("header template is empty")
+	}
+	if len(body.Card.Elements) == 0 {
+		t.Fatal("card has no elements")
+	}
+	if !strings.Contains(string(req.Body), "所有测试通过") {
+		t.Errorf("card does not contain the result: %s", req.Body)
+	}
+}
+
+func TestSendFeishuCardSigned(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	now := time.Unix(1700000000, 0)
+	f := newTestFeishuNotifier(t, srv, map[string]string{"FEISHU_SECRET": "demo-secret"}, now)
+
+	if err := f.Send(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	var body struct {
+		Timestamp string `json:"timestamp"`
+		Sign      string `json:"sign"`
+	}
+	if err := json.Unmarshal((*got)[0].Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	want, err := GenSign("demo-secret", now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Timestamp != "1700000000" || body.Sign != want {
+		t.Errorf("timestamp, sign = %q, %q; want %q, %q", body.Timestamp, body.Sign, "1700000000", want)
+	}
+}
+
+func TestSendFeishuCardErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{"http 500", http.StatusInternalServerError, "oops", func(err error) bool {
+			var e *HTTPStatusError
+			return errors.As(err, &e) && e.StatusCode == http.StatusInternalServerError
+		}},
+		{"http 400", http.StatusBadRequest, `{"code":9499,"msg":"Bad Request"}`, func(err error) bool {
+			var e *HTTPStatusError
+			return errors.As(err, &e) && e.StatusCode == http.StatusBadRequest
+		}},
+		{"non-zero code", http.StatusOK, `{"code":9499,"msg":"Bad Request"}`, func(err error) bool {
+			var e *FeishuAPIError
+			return errors.As(err, &e) && e.Code == 9499
+		}},
+		{"non-zero StatusCode", http.StatusOK, `{"StatusCode":11232,"StatusMessage":"frequency limited"}`, func(err error) bool {
+			var e *FeishuAPIError
+			return errors.As(err, &e) && e.StatusCode == 11232
+		}},
+		{"undecodable body", http.StatusOK, `<html>`, func(err error) bool {
+			return strings.Contains(err.Error(), "decode feishu response")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := feishuStub(t, tt.status, tt.body)
+			f := newTestFeishuNotifier(t, srv, nil, time.Now())
+			err := f.Send(context.Background(), testNotification())
+			if err == nil {
+				t.Fatal("Send succeeded, want an error")
+			}
+			if !tt.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// doerFunc 将函数适配为 doer
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestSendFeishuCardInjectedDoer(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": "https://open.feishu.cn/open-apis/bot/v2/hook/test",
+		"FEISHU_MAX_RETRIES": "0",
+	})
+	var calls int
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if req.URL.String() != cfg.WebhookURL {
+			t.Errorf("request URL = %s, want %s", req.URL, cfg.WebhookURL)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"code":0}`)),
+		}, nil
+	})
+	if err := sendFeishuCard(context.Background(), client, time.Now, testNotification(), cfg); err != nil {
+		t.Fatalf("sendFeishuCard: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("doer called %d times, want 1", calls)
+	}
+}
+
+// fakeNotifier 记录收到的通知, err 非空时发送失败
+type fakeNotifier struct {
+	name string
+	err  error
+	mu   sync.Mutex
+	sent []CodexNotification
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Send(ctx context.Context, n CodexNotification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, n)
+	return f.err
+}
+
+// registerTestNotifier 临时注册一个 sink, 测试结束后从注册表移除
+func registerTestNotifier(t *testing.T, name string, factory notifierFactory) {
+	t.Helper()
+	order := notifierOrder
+	registerNotifier(name, factory)
+	t.Cleanup(func() {
+		delete(notifierFactories, name)
+		notifierOrder = order
+	})
+}
+
+func TestRegistryDispatchesToFakeSink(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	fake := &fakeNotifier{name: "fake"}
+	registerTestNotifier(t, "fake", func(cfg FeishuConfig) ([]Notifier, error) {
+		return []Notifier{fake}, nil
+	})
+	registerTestNotifier(t, "unconfigured", func(cfg FeishuConfig) ([]Notifier, error) {
+		return nil, nil
+	})
+
+	cfg := testConfig(t, map[string]string{"FEISHU_WEBHOOK_URL": srv.URL + "/hook"})
+	rep, err := processNotification(context.Background(), cfg, testNotification())
+	if err != nil || rep.Outcome != outcomeSent {
+		t.Fatalf("outcome = %v, err = %v", rep.Outcome, err)
+	}
+	if len(*got) != 1 || len(fake.sent) != 1 || fake.sent[0].TurnID != testNotification().TurnID {
+		t.Fatalf("feishu got %d, fake got %d; want one each", len(*got), len(fake.sent))
+	}
+	var names []string
+	for _, s := range rep.Sinks {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "feishu,fake" {
+		t.Errorf("sinks = %v, want registration order feishu,fake", names)
+	}
+}
+
+// 单个 sink 失败不影响其它 sink, 结果按 sink 顺序排列
+func TestDispatchIsolatesFailures(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		a := &fakeNotifier{name: "a"}
+		b := &fakeNotifier{name: "b", err: errors.New("boom")}
+		c := &fakeNotifier{name: "c"}
+		results, err := dispatch(context.Background(), []Notifier{a, b, c}, testNotification(), concurrency)
+		if err == nil || err.Error() != "b: boom" {
+			t.Errorf("concurrency %d: err = %v, want b: boom", concurrency, err)
+		}
+		if len(a.sent) != 1 || len(b.sent) != 1 || len(c.sent) != 1 {
+			t.Errorf("concurrency %d: sends = %d/%d/%d", concurrency, len(a.sent), len(b.sent), len(c.sent))
+		}
+		if len(results) != 3 || results[0].Name != "a" || !results[0].OK || results[1].OK || results[1].Error == "" || !results[2].OK {
+			t.Errorf("concurrency %d: results = %+v", concurrency, results)
+		}
+	}
+}
+
+func TestRegisterNotifierTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a sink twice did not panic")
+		}
+	}()
+	registerNotifier("feishu", newFeishuNotifier)
+}
+
+// 一个可用的飞书 Webhook 加一个地址错误的 ntfy: 默认跳过出错的 sink, 严格模式下整体失败
+func TestMultiBackendStrict(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+		env := map[string]string{
+			"FEISHU_WEBHOOK_URL": srv.URL + "/hook",
+			"FEISHU_NTFY_URL":    "ntfy.sh/no-scheme",
+		}
+		if strict {
+			env["FEISHU_MULTI_BACKEND_STRICT"] = "1"
+		}
+		cfg := testConfig(t, env)
+
+		notifiers, err := configuredNotifiers(cfg)
+		if strict {
+			if err == nil || !strings.Contains(err.Error(), "ntfy: FEISHU_NTFY_URL") {
+				t.Errorf("strict: err = %v, want the ntfy config error", err)
+			}
+		} else if err != nil || len(notifiers) != 1 || notifiers[0].Name() != "feishu" {
+			t.Errorf("lenient: notifiers = %v, err = %v; want only feishu", notifiers, err)
+		}
+
+		rep, err := processNotification(context.Background(), cfg, testNotification())
+		wantSent := 1
+		if strict {
+			wantSent = 0
+			if err == nil || rep.Outcome != outcomeFailed {
+				t.Errorf("strict: outcome = %v, err = %v", rep.Outcome, err)
+			}
+		} else if err != nil || rep.Outcome != outcomeSent {
+			t.Errorf("lenient: outcome = %v, err = %v", rep.Outcome, err)
+		}
+		if len(*got) != wantSent {
+			t.Errorf("strict=%v: feishu got %d cards, want %d", strict, len(*got), wantSent)
+		}
+	}
+}
+
+// 所有 sink 都不可用时, 非严格模式同样报错
+func TestNoUsableSink(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_NTFY_URL": "::bad"})
+	if _, err := configuredNotifiers(cfg); err == nil || !strings.Contains(err.Error(), "no usable sink") {
+		t.Errorf("err = %v, want no usable sink", err)
+	}
+}
+
+// slowWebhooks 启动 count 个响应前等待 delay 的模拟 Webhook, 记录同时处理中的请求数峰值
+func slowWebhooks(t *testing.T, count int, delay time.Duration, status func(i int) string) (urls []string, received []*atomic.Int32, peak *atomic.Int32) {
+	t.Helper()
+	var inFlight atomic.Int32
+	peak = &atomic.Int32{}
+	for i := 0; i < count; i++ {
+		i := i
+		got := &atomic.Int32{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			cur := inFlight.Add(1)
+			for {
+				old := peak.Load()
+				if cur <= old || peak.CompareAndSwap(old, cur) {
+					break
+				}
+			}
+			time.Sleep(delay)
+			inFlight.Add(-1)
+			got.Add(1)
+			io.WriteString(w, status(i))
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL+"/hook")
+		received = append(received, got)
+	}
+	return urls, received, peak
+}
+
+func TestParallelFanOut(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	urls, received, peak := slowWebhooks(t, 4, delay, func(int) string { return `{"code":0}` })
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": strings.Join(urls, ","),
+		"FEISHU_CONCURRENCY": "4",
+	})
+	start := time.Now()
+	rep, err := processNotification(context.Background(), cfg, testNotification())
+	elapsed := time.Since(start)
+	if err != nil || rep.Outcome != outcomeSent || len(rep.Sinks) != 4 {
+		t.Fatalf("outcome=%v err=%v sinks=%d", rep.Outcome, err, len(rep.Sinks))
+	}
+	for i, got := range received {
+		if got.Load() != 1 {
+			t.Errorf("webhook %d received %d requests, want 1", i, got.Load())
+		}
+	}
+	// 串行需要 4*delay
+	if elapsed >= 3*delay {
+		t.Errorf("fan-out took %v, want well under the serial %v", elapsed, 4*delay)
+	}
+	if peak.Load() < 2 {
+		t.Errorf("peak concurrency = %d, want parallel requests", peak.Load())
+	}
+}
+
+// 并发数受 FEISHU_CONCURRENCY 限制; 单个失败不影响其他 Webhook, 整体记为失败
+func TestParallelFanOutBounded(t *testing.T) {
+	urls, received, peak := slowWebhooks(t, 5, 50*time.Millisecond, func(i int) string {
+		if i == 2 {
+			return `{"code":9499,"msg":"Bad Request"}`
+		}
+		return `{"code":0}`
+	})
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL": strings.Join(urls, ","),
+		"FEISHU_CONCURRENCY": "2",
+		"FEISHU_MAX_RETRIES": "0",
+	})
+	rep, err := processNotification(context.Background(), cfg, testNotification())
+	if err != nil || rep.Outcome != outcomeFailed {
+		t.Fatalf("outcome=%v err=%v, want failed", rep.Outcome, err)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak.Load())
+	}
+	for i, got := range received {
+		if got.Load() != 1 {
+			t.Errorf("webhook %d received %d requests, want 1", i, got.Load())
+		}
+	}
+	failed := 0
+	for _, s := range rep.Sinks {
+		if !s.OK {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d sinks failed, want 1", failed)
+	}
+}
+
+// 飞书专用的请求头 (FEISHU_EXTRA_HEADERS、请求 ID、幂等键、负载签名) 不发给其他平台
+func TestThirdPartyRequestHeaders(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_EXTRA_HEADERS":       "X-Gateway-Token: gw-secret",
+		"FEISHU_PAYLOAD_SIGN_SECRET": "sign-secret",
+		"FEISHU_NTFY_URL":            "https://ntfy.example.com/codex",
+		"FEISHU_NTFY_TOKEN":          "tk_ntfy",
+		"FEISHU_PUSHOVER_TOKEN":      "po-token",
+		"FEISHU_PUSHOVER_USER":       "po-user",
+		"FEISHU_GENERIC_HEADERS":     "X-Generic: 1",
+		"FEISHU_MAX_RETRIES":         "0",
+	})
+	cfg.RequestID = "req-1"
+	cfg.SlackWebhookURL = "https://hooks.slack.com/services/T/B/x"
+	cfg.DingTalkWebhookURL = "https://oapi.dingtalk.com/robot/send?access_token=x"
+	cfg.WeComWebhookURL = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=x"
+	cfg.TelegramBotToken, cfg.TelegramChatID = "123:abc", "42"
+	cfg.GenericURL = "https://hooks.example.com/codex"
+
+	var requests []*http.Request
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"errcode":0,"ok":true,"status":1}`)),
+		}, nil
+	})
+	ntfy, err := newNtfyNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushover, err := newPushoverNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ntfy[0].(*ntfyNotifier).client = client
+	pushover[0].(*pushoverNotifier).client = client
+	notifiers := []Notifier{
+		&slackNotifier{cfg: cfg, client: client},
+		&dingTalkNotifier{cfg: cfg, client: client, now: time.Now},
+		&wecomNotifier{cfg: cfg, client: client},
+		&telegramNotifier{cfg: cfg, client: client},
+		&genericNotifier{cfg: cfg, client: client},
+		ntfy[0],
+		pushover[0],
+	}
+
+	for _, n := range notifiers {
+		requests = nil
+		if err := n.Send(context.Background(), testNotification()); err != nil {
+			t.Fatalf("%s: Send: %v", n.Name(), err)
+		}
+		if len(requests) != 1 {
+			t.Fatalf("%s: got %d requests, want 1", n.Name(), len(requests))
+		}
+		h := requests[0].Header
+		for _, name := range []string{"X-Gateway-Token", "X-Request-Id", headerIdempotencyKey, headerCodexSignature, headerCodexTimestamp} {
+			if v := h.Get(name); v != "" {
+				t.Errorf("%s: request carries %s: %q", n.Name(), name, v)
+			}
+		}
+		if h.Get("Content-Type") != "application/json" || !strings.HasPrefix(h.Get("User-Agent"), binaryName+"/") {
+			t.Errorf("%s: headers = %v, want Content-Type and User-Agent", n.Name(), h)
+		}
+	}
+
+	// 各平台自身的请求头 (ntfy 令牌、通用 Webhook 请求头) 照常发送
+	requests = nil
+	ntfy[0].Send(context.Background(), testNotification())
+	if got := requests[0].Header.Get("Authorization"); got != "Bearer tk_ntfy" {
+		t.Errorf("ntfy Authorization = %q", got)
+	}
+	requests = nil
+	notifiers[4].Send(context.Background(), testNotification())
+	if got := requests[0].Header.Get("X-Generic"); got != "1" {
+		t.Errorf("generic X-Generic = %q", got)
+	}
+}
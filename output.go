@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ================= 机器可读的发送结果 =================
+// FEISHU_OUTPUT=json 时, 在发送结束后向 stdout 输出一个 JSON 对象, 便于包装脚本判断结果。
+// 与卡片负载及 dry-run 输出无关; 退出码仍按原规则反映成功或失败。
+
+const (
+	outputText = "text" // 默认, 不输出结果对象
+	outputJSON = "json"
+)
+
+// targeter 由可以给出发送目标 (如脱敏后的 Webhook 地址) 的 sink 实现
+type targeter interface {
+	Target() string
+}
+
+// sinkResult 单个 sink 的发送结果
+type sinkResult struct {
+	Name       string `json:"name"`
+	Target     string `json:"target,omitempty"`
+	OK         bool   `json:"ok"`
+	FeishuCode *int   `json:"feishu_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// newSinkResult 根据发送错误构造结果, 飞书接口错误时附带业务错误码
+func newSinkResult(n Notifier, err error) sinkResult {
+	r := sinkResult{Name: n.Name(), OK: err == nil}
+	if t, ok := n.(targeter); ok {
+		r.Target = t.Target()
+	}
+	var apiErr *FeishuAPIError
+	switch {
+	case errors.As(err, &apiErr):
+		code := apiErr.Code
+		if code == 0 {
+			code = apiErr.StatusCode
+		}
+		r.FeishuCode = &code
+	case err == nil && r.Target != "":
+		code := 0
+		r.FeishuCode = &code
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// MarshalText 让 outcome 以字符串形式输出
+func (o outcome) MarshalText() ([]byte, error) {
+	switch o {
+	case outcomeSkipped:
+		return []byte("skipped"), nil
+	case outcomeSent:
+		return []byte("sent"), nil
+	case outcomeFailed:
+		return []byte("failed"), nil
+	case outcomeQueued:
+		return []byte("queued"), nil
+	default:
+		return []byte("ignored"), nil
+	}
+}
+
+// notificationReport 单条通知的处理结果
+type notificationReport struct {
+	TurnID  string       `json:"turn_id,omitempty"`
+	Outcome outcome      `json:"outcome"`
+	Sinks   []sinkResult `json:"sinks,omitempty"`
+	Error   string       `json:"error,omitempty"` // 配置错误, 此时没有 sink 结果
+}
+
+// runReport 一次运行的汇总结果
+type runReport struct {
+	OK            bool                 `json:"ok"`
+	Webhooks      []string             `json:"webhooks"`
+	FeishuCode    int                  `json:"feishu_code"` // 第一个非零的飞书错误码, 全部成功时为 0
+	Sent          int                  `json:"sent"`
+	Skipped       int                  `json:"skipped"`
+	Queued        int                  `json:"queued"`
+	Failed        int                  `json:"failed"`
+	Notifications []notificationReport `json:"notifications"`
+	Error         string               `json:"error,omitempty"` // 第一个配置错误
+}
+
+// add 记录一条通知的处理结果并更新汇总
+func (r *runReport) add(rep notificationReport) {
+	r.Notifications = append(r.Notifications, rep)
+	switch rep.Outcome {
+	case outcomeSent:
+		r.Sent++
+	case outcomeSkipped:
+		r.Skipped++
+	case outcomeFailed:
+		r.Failed++
+	case outcomeQueued:
+		r.Queued++
+	}
+	for _, s := range rep.Sinks {
+		if s.Target != "" && !containsString(r.Webhooks, s.Target) {
+			r.Webhooks = append(r.Webhooks, s.Target)
+		}
+		if r.FeishuCode == 0 && s.FeishuCode != nil {
+			r.FeishuCode = *s.FeishuCode
+		}
+	}
+}
+
+// total 返回实际处理 (未被忽略) 的通知数
+func (r *runReport) total() int {
+	return r.Sent + r.Skipped + r.Failed + r.Queued
+}
+
+// write 以单行 JSON 输出结果
+func (r runReport) write(w io.Writer) error {
+	if r.Webhooks == nil {
+		r.Webhooks = []string{}
+	}
+	if r.Notifications == nil {
+		r.Notifications = []notificationReport{}
+	}
+	return json.NewEncoder(w).Encode(r)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// ================= 完整输出上传 (paste) =================
+// 执行结果超出卡片截断长度时, 将完整内容上传到粘贴服务, 并在卡片中附上链接。
+
+// pasteUploader 上传完整内容并返回可访问的链接
+type pasteUploader interface {
+	Upload(ctx context.Context, content string) (string, error)
+}
+
+// newPasteUploader 根据配置返回上传实现, 未配置时返回 nil
+func newPasteUploader(cfg FeishuConfig) pasteUploader {
+	if cfg.PasteUpload == "" {
+		return nil
+	}
+	return &httpPasteUploader{endpoint: cfg.PasteUpload, client: newHTTPClient(cfg)}
+}
+
+// httpPasteUploader 以 text/plain 形式 POST 到通用粘贴服务
+// 响应可以是纯文本链接 (如 0x0.st), 也可以是带 url / link 字段的 JSON
+type httpPasteUploader struct {
+	endpoint string
+	client   doer
+}
+
+func (u *httpPasteUploader) Upload(ctx context.Context, content string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", u.endpoint, strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return parsePasteResponse(body)
+}
+
+// parsePasteResponse 从粘贴服务响应中提取链接
+func parsePasteResponse(body []byte) (string, error) {
+	link := string(bytes.TrimSpace(body))
+	var obj struct {
+		URL  string `json:"url"`
+		Link string `json:"link"`
+	}
+	if json.Unmarshal(body, &obj) == nil {
+		link = obj.URL
+		if link == "" {
+			link = obj.Link
+		}
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("paste service returned no usable url: %q", truncateText(string(body), 100, truncateByRunes))
+	}
+	return link, nil
+}
+
+// withPasteLink 在执行结果会被截断时上传完整内容, 成功后返回带链接的配置
+// 上传失败时仅记录日志, 卡片退回只展示截断后的内容
+func withPasteLink(ctx context.Context, n CodexNotification, cfg FeishuConfig) FeishuConfig {
+	uploader := newPasteUploader(cfg)
+	if uploader == nil || cfg.DryRun {
+		return cfg
+	}
+	// 上传经过内容处理 (如脱敏) 后的结果, 与卡片展示保持一致
+	full := strings.TrimSpace(transformNotification(n, cfg.ContentPipeline).LastAssistantMessage)
+	if utf8.RuneCountInString(full) <= resultLimit {
+		return cfg
+	}
+	link, err := uploader.Upload(ctx, full)
+	if err != nil {
+		logger.Warn("paste upload failed, sending truncated result only", "err", err)
+		return cfg
+	}
+	cfg.PasteURL = link
+	return cfg
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pasteStub 返回模拟粘贴服务, 记录收到的上传内容
+func pasteStub(t *testing.T, status int, response string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		uploads = append(uploads, string(b))
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &uploads
+}
+
+// longResultNotification 返回执行结果超出卡片截断长度的通知
+func longResultNotification() CodexNotification {
+	n := testNotification()
+	n.LastAssistantMessage = strings.Repeat("all tests passed. ", 60)
+	return n
+}
+
+// 扇出到多个 Webhook 时只上传一次, 每张卡片附带同一个链接
+func TestPasteUploadedOnceForAllSinks(t *testing.T) {
+	a, gotA := feishuStub(t, http.StatusOK, `{"code":0}`)
+	b, gotB := feishuStub(t, http.StatusOK, `{"code":0}`)
+	paste, uploads := pasteStub(t, http.StatusOK, "https://paste.example/abc\n")
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":  a.URL + "/hook/a," + b.URL + "/hook/b",
+		"FEISHU_PASTE_UPLOAD": paste.URL,
+	})
+
+	rep, err := processNotification(context.Background(), cfg, longResultNotification())
+	if err != nil || rep.Outcome != outcomeSent {
+		t.Fatalf("outcome = %v, err = %v", rep.Outcome, err)
+	}
+	if len(*uploads) != 1 {
+		t.Fatalf("got %d uploads, want 1", len(*uploads))
+	}
+	if (*uploads)[0] != strings.TrimSpace(longResultNotification().LastAssistantMessage) {
+		t.Error("upload is not the full result")
+	}
+	for name, got := range map[string]*[]capturedRequest{"a": gotA, "b": gotB} {
+		if len(*got) != 1 || !strings.Contains(string((*got)[0].Body), "https://paste.example/abc") {
+			t.Errorf("webhook %s: %d requests, want one card with the paste link", name, len(*got))
+		}
+	}
+}
+
+func TestPasteSkippedForShortResult(t *testing.T) {
+	hook, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	paste, uploads := pasteStub(t, http.StatusOK, "https://paste.example/abc")
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":  hook.URL + "/hook",
+		"FEISHU_PASTE_UPLOAD": paste.URL,
+	})
+	if _, err := processNotification(context.Background(), cfg, testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*uploads) != 0 || len(*got) != 1 {
+		t.Errorf("uploads=%d cards=%d, want 0 and 1", len(*uploads), len(*got))
+	}
+}
+
+// 上传失败时照常发送, 卡片中没有链接
+func TestPasteFailureSendsTruncatedCard(t *testing.T) {
+	hook, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	paste, _ := pasteStub(t, http.StatusInternalServerError, "down")
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":  hook.URL + "/hook",
+		"FEISHU_PASTE_UPLOAD": paste.URL,
+	})
+	rep, err := processNotification(context.Background(), cfg, longResultNotification())
+	if err != nil || rep.Outcome != outcomeSent || len(*got) != 1 {
+		t.Fatalf("outcome=%v err=%v cards=%d", rep.Outcome, err, len(*got))
+	}
+	if strings.Contains(string((*got)[0].Body), paste.URL) {
+		t.Error("card links to the failed paste service")
+	}
+}
+
+func TestParsePasteResponse(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"https://0x0.st/abc.txt\n", "https://0x0.st/abc.txt"},
+		{`{"url":"https://paste.example/1"}`, "https://paste.example/1"},
+		{`{"link":"http://paste.example/2"}`, "http://paste.example/2"},
+	}
+	for _, tt := range tests {
+		got, err := parsePasteResponse([]byte(tt.body))
+		if err != nil || got != tt.want {
+			t.Errorf("parsePasteResponse(%q) = %q, %v; want %q", tt.body, got, err, tt.want)
+		}
+	}
+	for _, body := range []string{"", "ok", `{"id":1}`, "ftp://paste.example/x", "https://"} {
+		if _, err := parsePasteResponse([]byte(body)); err == nil {
+			t.Errorf("parsePasteResponse(%q) accepted", body)
+		}
+	}
+}
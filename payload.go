@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// ================= 消息体大小上限 =================
+// 飞书会直接拒绝过大的消息。发送前测量序列化后的字节数, 超出 FEISHU_MAX_PAYLOAD_BYTES 时
+// 逐步收紧输入指令与执行结果的字符预算 (每次将较大的一方减半), 直到消息体不超过上限。
+
+const (
+	// defaultMaxPayloadBytes 默认上限, 略低于飞书 30KB 的限制, 为签名等字段留出余量
+	defaultMaxPayloadBytes = 28 * 1024
+
+	// minTrimBudget 收紧预算的下限, 两者都降到该值后不再继续
+	minTrimBudget = 16
+)
+
+// fitPayload 返回序列化后不超过上限的消息; build 根据 (收紧了预算的) 配置组装消息
+// 每轮至少将一个预算减半, 因此循环次数有上界; 降到下限仍超出时原样返回最后一次的结果
+func fitPayload(n CodexNotification, cfg FeishuConfig, build func(FeishuConfig) interface{}) (interface{}, error) {
+	msg := build(cfg)
+	if cfg.MaxPayloadBytes <= 0 {
+		return msg, nil
+	}
+	size, err := payloadSize(msg)
+	if err != nil || size <= cfg.MaxPayloadBytes {
+		return msg, err
+	}
+
+	inputBudget := utf8.RuneCountInString(strings.Join(n.InputMessages, "\n"))
+	resultBudget := utf8.RuneCountInString(n.LastAssistantMessage)
+	if resultBudget > resultLimit {
+		resultBudget = resultLimit // 卡片本身最多展示这么多
+	}
+	for inputBudget > minTrimBudget || resultBudget > minTrimBudget {
+		if inputBudget >= resultBudget {
+			inputBudget = max(inputBudget/2, minTrimBudget)
+		} else {
+			resultBudget = max(resultBudget/2, minTrimBudget)
+		}
+		cfg.InputBudget, cfg.ResultBudget = inputBudget, resultBudget
+		msg = build(cfg)
+		if size, err = payloadSize(msg); err != nil || size <= cfg.MaxPayloadBytes {
+			logger.Info("trimmed payload to fit size limit", "bytes", size, "limit", cfg.MaxPayloadBytes,
+				"inputBudget", inputBudget, "resultBudget", resultBudget)
+			return msg, err
+		}
+	}
+	logger.Warn("payload still exceeds size limit after trimming", "bytes", size, "limit", cfg.MaxPayloadBytes)
+	return msg, nil
+}
+
+// payloadSize 返回消息序列化后的字节数
+func payloadSize(msg interface{}) (int, error) {
+	b, err := json.Marshal(msg)
+	return len(b), err
+}
+
+// applyBudgets 按配置中的预算截断输入指令与执行结果, 返回是否发生了截断
+func applyBudgets(n CodexNotification, cfg FeishuConfig) (CodexNotification, bool) {
+	trimmed := false
+	if cfg.InputBudget > 0 {
+		var msgs []string
+		left := cfg.InputBudget
+		for _, msg := range n.InputMessages {
+			if left <= 0 {
+				trimmed = true
+				break
+			}
+			if cut := truncateRunes(msg, left); cut != msg {
+				msg, trimmed = cut, true
+			}
+			msgs = append(msgs, msg)
+			left -= utf8.RuneCountInString(msg) + 1
+		}
+		n.InputMessages = msgs
+	}
+	if cfg.ResultBudget > 0 {
+		if cut := truncateRunes(n.LastAssistantMessage, cfg.ResultBudget); cut != n.LastAssistantMessage {
+			n.LastAssistantMessage, trimmed = cut, true
+		}
+	}
+	return n, trimmed
+}
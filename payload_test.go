@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// hugeNotification 返回输入与结果都远超消息体上限的通知
+func hugeNotification() CodexNotification {
+	n := testNotification()
+	n.InputMessages = nil
+	for i := 0; i < 40; i++ {
+		n.InputMessages = append(n.InputMessages, strings.Repeat("重构解析器并补充测试。", 100))
+	}
+	n.LastAssistantMessage = strings.Repeat("所有测试均已通过, 覆盖率提升到百分之九十。", 2000)
+	return n
+}
+
+func TestPayloadTrimmedUnderCap(t *testing.T) {
+	for _, format := range []string{"card", "text"} {
+		srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+		f := newTestFeishuNotifier(t, srv, map[string]string{"FEISHU_MSG_FORMAT": format}, footerNow)
+		if err := f.Send(context.Background(), hugeNotification()); err != nil {
+			t.Fatalf("%s: Send: %v", format, err)
+		}
+		body := (*got)[0].Body
+		if len(body) > defaultMaxPayloadBytes {
+			t.Errorf("%s: payload is %d bytes, want at most %d", format, len(body), defaultMaxPayloadBytes)
+		}
+		if !strings.Contains(string(body), "（内容已截断）") {
+			t.Errorf("%s: trimmed payload has no truncation note", format)
+		}
+	}
+}
+
+func TestPayloadUnderCapUntouched(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	f := newTestFeishuNotifier(t, srv, nil, footerNow)
+	if err := f.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string((*got)[0].Body), "（内容已截断）") {
+		t.Error("small payload marked as truncated")
+	}
+}
+
+// 上限小到无法满足时, 收紧到下限后停止并照常返回, 不会无限循环
+func TestFitPayloadTerminates(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_MAX_PAYLOAD_BYTES": "100"})
+	builds := 0
+	msg, err := fitPayload(hugeNotification(), cfg, func(cfg FeishuConfig) interface{} {
+		builds++
+		n, _ := applyBudgets(hugeNotification(), cfg)
+		return n
+	})
+	if err != nil || msg == nil {
+		t.Fatalf("fitPayload = %v, %v", msg, err)
+	}
+	if builds > 64 {
+		t.Errorf("fitPayload built the message %d times", builds)
+	}
+	n := msg.(CodexNotification)
+	if got := len([]rune(n.LastAssistantMessage)); got > minTrimBudget {
+		t.Errorf("result trimmed to %d runes, want at most %d", got, minTrimBudget)
+	}
+}
+
+func TestApplyBudgets(t *testing.T) {
+	n := testNotification()
+	n.InputMessages = []string{"first instruction", "second instruction", "third"}
+	n.LastAssistantMessage = "a fairly long result text"
+	cfg := FeishuConfig{InputBudget: 20, ResultBudget: 10}
+	got, trimmed := applyBudgets(n, cfg)
+	if !trimmed {
+		t.Fatal("budgets below content length not reported as trimmed")
+	}
+	if len(got.InputMessages) != 2 || got.InputMessages[0] != "first instruction" || got.InputMessages[1] != "se" {
+		t.Errorf("inputs = %q", got.InputMessages)
+	}
+	if got.LastAssistantMessage != "a fairl..." {
+		t.Errorf("result = %q", got.LastAssistantMessage)
+	}
+	if _, trimmed := applyBudgets(n, FeishuConfig{}); trimmed {
+		t.Error("zero budgets trimmed the content")
+	}
+}
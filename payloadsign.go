@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ================= 请求体签名与幂等键 =================
+// 每次 Webhook 请求在 thread-id 与 turn-id 都存在时附带 X-Codex-Idempotency-Key, 便于接收端去重。
+// 经由自建接收端转发时, 接收端需要确认请求确实来自本工具。配置 FEISHU_PAYLOAD_SIGN_SECRET 后,
+// 每次 Webhook 请求附带:
+//   X-Codex-Timestamp: 秒级 Unix 时间戳
+//   X-Codex-Signature: hex(HMAC-SHA256(secret, timestamp + "." + body))
+// body 为实际发送的请求体字节 (开启 FEISHU_GZIP 时为压缩后的字节)。与飞书自身的签名相互独立。
+
+const (
+	headerCodexSignature = "X-Codex-Signature"
+	headerCodexTimestamp = "X-Codex-Timestamp"
+	headerIdempotencyKey = "X-Codex-Idempotency-Key"
+)
+
+// payloadSignature 计算请求体签名, 签名串为 timestamp + "." + body
+func payloadSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signPayload 为请求设置签名头; 未配置 secret 时不做任何事
+func signPayload(h http.Header, secret string, body []byte, now time.Time) {
+	if secret == "" {
+		return
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	h.Set(headerCodexTimestamp, ts)
+	h.Set(headerCodexSignature, payloadSignature(secret, ts, body))
+}
+
+// idempotencyKey 由 thread-id 与 turn-id 派生稳定的幂等键: hex(SHA-256(thread_id + "\n" + dedupKey))
+// agent-turn-complete 即 hex(SHA-256(thread_id + "\n" + turn_id)), 其它类型的 turn-id 带类型前缀,
+// 同一 turn 的开始、审批与完成通知得到不同的键 (也用作邮件的 Message-ID)。
+// 同一通知在任何机器上得到相同的键, 接收端可据此去重; 缺少任一 ID 时返回空字符串, 不发送该头
+func idempotencyKey(n CodexNotification) string {
+	if n.ThreadID == "" || n.TurnID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(n.ThreadID + "\n" + dedupKey(n)))
+	return hex.EncodeToString(sum[:])
+}
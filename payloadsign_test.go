@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyIncludesEventType(t *testing.T) {
+	n := testNotification()
+	complete := idempotencyKey(n)
+	sum := sha256.Sum256([]byte(n.ThreadID + "\n" + n.TurnID))
+	if want := hex.EncodeToString(sum[:]); complete != want {
+		t.Errorf("agent-turn-complete key = %s, want %s", complete, want)
+	}
+
+	seen := map[string]string{complete: typeTurnComplete}
+	for _, typ := range []string{typeTurnStart, typeApproval, typeError, typeTurnAborted} {
+		n.Type = typ
+		key := idempotencyKey(n)
+		if other, dup := seen[key]; dup {
+			t.Errorf("%s and %s share idempotency key %s", typ, other, key)
+		}
+		seen[key] = typ
+	}
+
+	n.Type = "APPROVAL_REQUESTED"
+	if key := idempotencyKey(n); seen[key] != typeApproval {
+		t.Errorf("type spelling changed the key: %s", key)
+	}
+}
+
+// 接收端按文档中的签名串独立计算, 结果应与请求头一致
+func TestPayloadSignatureHeader(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, gzipBody := range []string{"", "1"} {
+		srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+		f := newTestFeishuNotifier(t, srv, map[string]string{
+			"FEISHU_PAYLOAD_SIGN_SECRET": "receiver-secret",
+			"FEISHU_GZIP":                gzipBody,
+		}, now)
+		if err := f.Send(context.Background(), testNotification()); err != nil {
+			t.Fatal(err)
+		}
+		req := (*got)[0]
+		ts := req.Header.Get("X-Codex-Timestamp")
+		if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+			t.Fatalf("X-Codex-Timestamp = %q", ts)
+		}
+		mac := hmac.New(sha256.New, []byte("receiver-secret"))
+		mac.Write([]byte(ts + "."))
+		mac.Write(req.Body)
+		if want := hex.EncodeToString(mac.Sum(nil)); req.Header.Get("X-Codex-Signature") != want {
+			t.Errorf("gzip=%q: X-Codex-Signature = %q, want %q", gzipBody, req.Header.Get("X-Codex-Signature"), want)
+		}
+	}
+}
+
+func TestPayloadSignatureAbsentWithoutSecret(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	f := newTestFeishuNotifier(t, srv, nil, time.Now())
+	if err := f.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	h := (*got)[0].Header
+	if h.Get("X-Codex-Signature") != "" || h.Get("X-Codex-Timestamp") != "" {
+		t.Errorf("signature headers sent without FEISHU_PAYLOAD_SIGN_SECRET: %v", h)
+	}
+}
+
+func TestPayloadSignatureVector(t *testing.T) {
+	// 参考值: printf '1700000000.{}' | openssl dgst -sha256 -hmac s
+	const want = "e9232c9945da8456c2dea6b39da7786c07b00bca7a64d5dd1283674713a7b72a"
+	if got := payloadSignature("s", "1700000000", []byte("{}")); got != want {
+		t.Errorf("payloadSignature = %s, want %s", got, want)
+	}
+}
+
+func TestIdempotencyKeyStable(t *testing.T) {
+	a, b := testNotification(), testNotification()
+	b.LastAssistantMessage = "a different result for the same turn"
+	if idempotencyKey(a) != idempotencyKey(b) || idempotencyKey(a) == "" {
+		t.Errorf("same turn gave keys %q and %q", idempotencyKey(a), idempotencyKey(b))
+	}
+	b.TurnID = "turn-2"
+	if idempotencyKey(a) == idempotencyKey(b) {
+		t.Error("different turns share an idempotency key")
+	}
+	b.TurnID, b.ThreadID = a.TurnID, "thread-2"
+	if idempotencyKey(a) == idempotencyKey(b) {
+		t.Error("different threads share an idempotency key")
+	}
+	for _, n := range []CodexNotification{{ThreadID: "thread-1"}, {TurnID: "turn-1"}} {
+		if key := idempotencyKey(n); key != "" {
+			t.Errorf("idempotencyKey(%+v) = %q, want empty", n, key)
+		}
+	}
+}
+
+// 经由完整发送流程: 相同的通知带相同的键, 没有 turn-id 时不带该头
+func TestIdempotencyHeaderPerNotification(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{"FEISHU_WEBHOOK_URL": srv.URL + "/hook"})
+	noTurn := testNotification()
+	noTurn.TurnID = ""
+	for _, n := range []CodexNotification{testNotification(), testNotification(), turnNotification(2, false), noTurn} {
+		if _, err := processNotification(context.Background(), cfg, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*got) != 4 {
+		t.Fatalf("got %d requests, want 4", len(*got))
+	}
+	keys := make([]string, len(*got))
+	for i, req := range *got {
+		keys[i] = req.Header.Get("X-Codex-Idempotency-Key")
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("same notification sent with keys %q and %q", keys[0], keys[1])
+	}
+	if keys[2] == keys[0] {
+		t.Error("different turn reused the key")
+	}
+	if _, present := (*got)[3].Header["X-Codex-Idempotency-Key"]; present {
+		t.Errorf("key sent without a turn-id: %q", keys[3])
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// contentTransform 是作用于输入指令与执行结果的单个文本变换
+type contentTransform struct {
+	Name  string
+	Apply func(string) string
+}
+
+// contentTransforms 所有可用的变换, 通过 FEISHU_CONTENT_PIPELINE 按名称启用并排序
+var contentTransforms = map[string]func(string) string{
+	"normalize_space":    normalizeWhitespace,
+	"strip_ansi":         stripANSI,
+	"redact":             redactSecrets,
+	"collapse_blank":     collapseBlankLines,
+	"normalize_markdown": normalizeMarkdown,
+}
+
+// defaultContentPipeline 默认的变换顺序, 只包含不改变卡片显示内容的无损变换;
+// redact、collapse_blank 与 normalize_markdown 会改写内容, 需在 FEISHU_CONTENT_PIPELINE 中显式启用
+var defaultContentPipeline = []string{"normalize_space", "strip_ansi"}
+
+// parseContentPipeline 按名称构造变换流水线, none 表示不做任何变换
+func parseContentPipeline(names []string) ([]contentTransform, error) {
+	if len(names) == 0 {
+		names = defaultContentPipeline
+	}
+	if len(names) == 1 && strings.EqualFold(names[0], "none") {
+		return nil, nil
+	}
+	pipeline := make([]contentTransform, 0, len(names))
+	for _, name := range names {
+		apply, ok := contentTransforms[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("FEISHU_CONTENT_PIPELINE: unknown transform %q", name)
+		}
+		pipeline = append(pipeline, contentTransform{Name: strings.ToLower(name), Apply: apply})
+	}
+	return pipeline, nil
+}
+
+// applyPipeline 依次执行流水线中的变换
+func applyPipeline(s string, pipeline []contentTransform) string {
+	for _, t := range pipeline {
+		s = t.Apply(s)
+	}
+	return s
+}
+
+// transformNotification 返回对输入指令与执行结果应用流水线后的通知副本
+func transformNotification(n CodexNotification, pipeline []contentTransform) CodexNotification {
+	if len(pipeline) == 0 {
+		return n
+	}
+	inputs := make([]string, len(n.InputMessages))
+	for i, msg := range n.InputMessages {
+		inputs[i] = applyPipeline(msg, pipeline)
+	}
+	n.InputMessages = inputs
+	n.LastAssistantMessage = applyPipeline(n.LastAssistantMessage, pipeline)
+	return n
+}
+
+var blankRun3Pattern = regexp.MustCompile(`\n{4,}`)
+
+// normalizeWhitespace 统一换行为 \n, 去除每行行尾空白, 并将连续 3 个及以上空行合并为一个
+// 终端复制的内容常带有 \r\n、进度条留下的 \r 与行尾空格, 放在流水线最前面, 避免浪费截断预算
+func normalizeWhitespace(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	s = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	return blankRun3Pattern.ReplaceAllString(s, "\n\n")
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)`)
+
+// stripANSI 去除终端颜色等 ANSI 控制序列
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),                 // OpenAI 风格 API key
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`),            // GitHub token
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                    // AWS access key id
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),          // Slack token
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`), // Authorization 头
+}
+
+// redactSecrets 将常见的密钥格式替换为 [REDACTED]
+func redactSecrets(s string) string {
+	for _, p := range secretPatterns {
+		s = p.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+var blankRunPattern = regexp.MustCompile(`\n([ \t]*\n){2,}`)
+
+// collapseBlankLines 将连续多个空行合并为一个
+func collapseBlankLines(s string) string {
+	return blankRunPattern.ReplaceAllString(s, "\n\n")
+}
+
+var headingPattern = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+
+// normalizeMarkdown 将 lark_md 不支持的标题语法转为加粗, 并统一 * / + 列表符号为 -
+// 围栏代码块内的内容保持不变
+func normalizeMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+			lines[i] = "**" + m[1] + "**"
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		rest := line[len(indent):]
+		if strings.HasPrefix(rest, "* ") || strings.HasPrefix(rest, "+ ") {
+			lines[i] = indent + "- " + rest[2:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dedupConsecutive 合并连续重复的条目, 非连续的重复保留
+func dedupConsecutive(items []string) []string {
+	if len(items) < 2 {
+		return items
+	}
+	out := make([]string, 0, len(items))
+	for i, item := range items {
+		if i > 0 && item == items[i-1] {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
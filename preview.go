@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ================= 本地 HTML 预览 =================
+// codex-notify -html-preview <path> <NOTIFICATION_JSON> 将卡片近似渲染为 HTML 文件,
+// 便于在浏览器中调整布局, 不发送任何请求。
+
+// headerColors 卡片头颜色模板对应的近似色值
+var headerColors = map[string]string{
+	"blue": "#3370ff", "wathet": "#6dabf5", "turquoise": "#2ea6a6", "green": "#34a853",
+	"yellow": "#e6b800", "orange": "#ff8800", "red": "#f54a45", "carmine": "#d83931",
+	"violet": "#b33ae0", "purple": "#7f3bf5", "indigo": "#4e5bd6", "grey": "#8f959e",
+	"default": "#8f959e",
+}
+
+const previewStyle = `body{font-family:-apple-system,"PingFang SC",sans-serif;background:#f2f3f5;padding:24px}
+.card{max-width:640px;margin:0 auto 24px;background:#fff;border-radius:8px;overflow:hidden;box-shadow:0 1px 4px rgba(0,0,0,.1)}
+.header{color:#fff;padding:12px 16px;font-weight:600}
+.body{padding:8px 16px 12px}
+.div{margin:8px 0;white-space:pre-wrap;line-height:1.5}
+.fields{display:flex;flex-wrap:wrap}.field{margin:4px 0;white-space:pre-wrap}.field.short{width:50%}.field.long{width:100%}
+hr{border:none;border-top:1px solid #e5e6eb}
+pre{background:#f5f6f7;padding:8px;border-radius:4px;overflow-x:auto}
+.note{color:#8f959e;font-size:12px}
+.text{white-space:pre-wrap;padding:16px}`
+
+var (
+	mdBold = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	mdCode = regexp.MustCompile("`([^`\n]*)`")
+	mdLink = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdAt   = regexp.MustCompile(`&lt;at (?:id|user_id)=(?:&#34;)?([^&\s]+?)(?:&#34;)?&gt;&lt;/at&gt;`)
+)
+
+// larkMarkdownHTML 将 lark_md 近似转换为 HTML: 加粗、行内代码、链接、@ 与围栏代码块
+func larkMarkdownHTML(s string) string {
+	var b strings.Builder
+	for _, seg := range splitFencedCode(s) {
+		if seg.Code {
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>", html.EscapeString(seg.Text))
+			continue
+		}
+		text := html.EscapeString(seg.Text)
+		text = mdCode.ReplaceAllString(text, "<code>$1</code>")
+		text = mdBold.ReplaceAllString(text, "<b>$1</b>")
+		text = mdLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
+		text = mdAt.ReplaceAllString(text, "<b>@$1</b>")
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// textHTML 渲染单个文本元素
+func textHTML(t FeishuText) string {
+	if t.Tag == "lark_md" {
+		return larkMarkdownHTML(t.Content)
+	}
+	return html.EscapeString(t.Content)
+}
+
+// renderCardHTML 将卡片渲染为 HTML 片段, 覆盖本程序使用到的元素类型
+func renderCardHTML(card FeishuCard) string {
+	var b strings.Builder
+	color, ok := headerColors[card.Header.Template]
+	if !ok {
+		color = headerColors["default"]
+	}
+	fmt.Fprintf(&b, "<div class=\"card\">\n<div class=\"header\" style=\"background:%s\">%s</div>\n<div class=\"body\">\n",
+		color, html.EscapeString(card.Header.Title.Content))
+	for _, el := range card.Elements {
+		switch e := el.(type) {
+		case FeishuDiv:
+			if e.Text != nil {
+				fmt.Fprintf(&b, "<div class=\"div\">%s</div>\n", textHTML(*e.Text))
+			}
+			if len(e.Fields) > 0 {
+				b.WriteString("<div class=\"fields\">")
+				for _, f := range e.Fields {
+					width := "long"
+					if f.IsShort {
+						width = "short"
+					}
+					fmt.Fprintf(&b, "<div class=\"field %s\">%s</div>", width, textHTML(f.Text))
+				}
+				b.WriteString("</div>\n")
+			}
+		case FeishuMarkdown:
+			fmt.Fprintf(&b, "<div class=\"div\">%s</div>\n", larkMarkdownHTML(e.Content))
+		case FeishuHr:
+			b.WriteString("<hr>\n")
+		case FeishuAction:
+			for _, btn := range e.Actions {
+				fmt.Fprintf(&b, "<div class=\"div\"><button>%s</button></div>\n", html.EscapeString(btn.Text.Content))
+			}
+		case FeishuNote:
+			parts := make([]string, len(e.Elements))
+			for i, t := range e.Elements {
+				parts[i] = textHTML(t)
+			}
+			fmt.Fprintf(&b, "<div class=\"note\">%s</div>\n", strings.Join(parts, " · "))
+		case json.RawMessage:
+			// 自定义模板渲染的元素, 原样展示 JSON
+			fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(string(e)))
+		default:
+			fmt.Fprintf(&b, "<div class=\"note\">[unsupported element %T]</div>\n", el)
+		}
+	}
+	b.WriteString("</div>\n</div>\n")
+	return b.String()
+}
+
+// renderPreviewHTML 渲染完整的 HTML 预览页面
+func renderPreviewHTML(notifications []CodexNotification, cfg FeishuConfig) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>codex-notify preview</title>\n<style>%s</style></head>\n<body>\n", previewStyle)
+	for _, n := range notifications {
+		switch _, content := renderContent(n, cfg); c := content.(type) {
+		case FeishuCard:
+			b.WriteString(renderCardHTML(c))
+		case FeishuCardV2:
+			b.WriteString(renderCardV2HTML(c))
+		case FeishuTextContent:
+			fmt.Fprintf(&b, "<div class=\"card text\">%s</div>\n", html.EscapeString(c.Text))
+		}
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// runHTMLPreview 渲染通知并写入 HTML 文件, 返回进程退出码
+func runHTMLPreview(path, jsonStr string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config error", "err", err)
+		return 1
+	}
+	attachRequestID(cfg)
+
+	notifications, _, err := parseNotifications(jsonStr)
+	if err != nil {
+		logger.Error("error parsing notification JSON", "err", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(renderPreviewHTML(notifications, cfg)), 0o644); err != nil {
+		logger.Error("write html preview", "err", err)
+		return 1
+	}
+	cfg.printf(os.Stdout, "Preview written to %s\n", path)
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// previewNotification 返回输入与结果中带 HTML 特殊字符、加粗与代码块的通知
+func previewNotification() CodexNotification {
+	n := testNotification()
+	n.InputMessages = []string{"fix the <login> form"}
+	n.LastAssistantMessage = "Updated **two files**:\n```go\nif a < b {\n}\n```\nall `go test` passed"
+	return n
+}
+
+func TestRenderPreviewHTMLSections(t *testing.T) {
+	cfg := testConfig(t, nil)
+	n := previewNotification()
+	page := renderPreviewHTML([]CodexNotification{n}, cfg)
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<div class="header"`,
+		"fix the &lt;login&gt; form",               // 输入, 已转义
+		"<b>two files</b>",                         // 结果中的加粗
+		"<pre><code>if a &lt; b {\n}</code></pre>", // 围栏代码块
+		"<code>go test</code>",                     // 行内代码
+		n.Cwd,                                      // 字段
+		"<hr>",
+		`<div class="note">`, // 底部备注
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("preview does not contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<login>") {
+		t.Error("input is not HTML-escaped")
+	}
+}
+
+func TestRenderPreviewHTMLText(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_MSG_FORMAT": "text"})
+	page := renderPreviewHTML([]CodexNotification{previewNotification()}, cfg)
+	if !strings.Contains(page, `<div class="card text">`) || !strings.Contains(page, "fix the &lt;login&gt; form") {
+		t.Errorf("text preview missing content:\n%s", page)
+	}
+}
+
+func TestRunHTMLPreview(t *testing.T) {
+	testConfig(t, nil)
+	path := filepath.Join(t.TempDir(), "card.html")
+	input, err := json.Marshal(previewNotification())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := runHTMLPreview(path, string(input)); code != 0 {
+		t.Fatalf("runHTMLPreview exit code = %d", code)
+	}
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "fix the &lt;login&gt; form") {
+		t.Error("preview file does not contain the input")
+	}
+	if code := runHTMLPreview(path, "{not json"); code != 1 {
+		t.Errorf("invalid input exit code = %d, want 1", code)
+	}
+}
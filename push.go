@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// ================= 手机推送 sink (ntfy / Pushover) =================
+// 与 FEISHU_BACKEND 无关: 设置了 FEISHU_NTFY_URL 或 FEISHU_PUSHOVER_TOKEN 与 FEISHU_PUSHOVER_USER 时,
+// 在所选后端之外额外推送一条简短的通知 (标题与结果摘要), 二者可同时启用。
+// 失败类通知与审批请求使用高优先级, 以便在静音时也能提醒。
+
+const (
+	// pushMessageLimit 推送正文的最大字符数; Pushover 上限为 1024, ntfy 为 4096 字节
+	pushMessageLimit = 1024
+	// pushoverTitleLimit Pushover 标题的最大字符数
+	pushoverTitleLimit = 250
+
+	pushoverEndpoint = "https://api.pushover.net/1/messages.json"
+)
+
+func init() {
+	registerNotifier("ntfy", newNtfyNotifier)
+	registerNotifier("pushover", newPushoverNotifier)
+}
+
+// pushSummary 返回推送的标题、正文与是否需要高优先级提醒
+func pushSummary(n CodexNotification, cfg FeishuConfig) (title, message string, urgent bool) {
+	n, cfg, failed := prepareNotification(n, cfg)
+	title = cardTitle(n, cfg, failed)
+	_, message = eventBody(n, cfg, failed)
+	if message == "" {
+		message = inputText(n, cfg, "\n")
+	}
+	return title, truncateText(message, pushMessageLimit, truncateByRunes), failed || n.Type == typeApproval
+}
+
+// ================= ntfy =================
+
+type ntfyNotifier struct {
+	cfg      FeishuConfig
+	client   doer
+	endpoint string      // 服务地址, JSON 发布接口
+	headers  http.Header // 访问令牌, 只发给 ntfy 服务
+	topic    string
+}
+
+func newNtfyNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.NtfyURL == "" {
+		return nil, nil
+	}
+	endpoint, topic, err := parseNtfyURL(cfg.NtfyURL)
+	if err != nil {
+		return nil, err
+	}
+	var headers http.Header
+	if cfg.NtfyToken != "" {
+		headers = http.Header{"Authorization": {"Bearer " + cfg.NtfyToken}}
+	}
+	return []Notifier{&ntfyNotifier{cfg: cfg, client: newHTTPClient(cfg), endpoint: endpoint, topic: topic, headers: headers}}, nil
+}
+
+// parseNtfyURL 将主题地址 (如 https://ntfy.sh/my-topic) 拆分为服务地址与主题名
+func parseNtfyURL(raw string) (endpoint, topic string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", "", fmt.Errorf("FEISHU_NTFY_URL: invalid URL %q", redactWebhook(raw))
+	}
+	dir, topic := path.Split(strings.TrimRight(u.Path, "/"))
+	if topic == "" {
+		return "", "", fmt.Errorf("FEISHU_NTFY_URL: missing topic in %q", raw)
+	}
+	u.Path, u.RawQuery, u.Fragment = dir, "", ""
+	return u.String(), topic, nil
+}
+
+func (t *ntfyNotifier) Name() string { return "ntfy" }
+
+// Target 主题名即发布凭据, 不在日志中显示
+func (t *ntfyNotifier) Target() string { return t.endpoint + "***" }
+
+// ntfyMessage JSON 发布接口的请求体; priority 1-5, 3 为默认
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	Markdown bool     `json:"markdown"`
+}
+
+func (t *ntfyNotifier) Send(ctx context.Context, n CodexNotification) error {
+	title, message, urgent := pushSummary(n, t.cfg)
+	msg := ntfyMessage{Topic: t.topic, Title: title, Message: message, Priority: 3, Markdown: true}
+	if urgent {
+		msg.Priority, msg.Tags = 4, []string{"warning"}
+	}
+	if t.cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, t.cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, t.client, t.cfg, t.endpoint, t.Target(), t.headers, payload, nil)
+}
+
+// ================= Pushover =================
+
+type pushoverNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newPushoverNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.PushoverToken == "" && cfg.PushoverUser == "" {
+		return nil, nil
+	}
+	if cfg.PushoverToken == "" || cfg.PushoverUser == "" {
+		return nil, errors.New("FEISHU_PUSHOVER_TOKEN and FEISHU_PUSHOVER_USER must be set together")
+	}
+	return []Notifier{&pushoverNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (p *pushoverNotifier) Name() string { return "pushover" }
+
+func (p *pushoverNotifier) Target() string { return pushoverEndpoint }
+
+// pushoverMessage messages.json 的请求体; priority 1 为高优先级, 绕过免打扰时段
+type pushoverMessage struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+func (p *pushoverNotifier) Send(ctx context.Context, n CodexNotification) error {
+	title, message, urgent := pushSummary(n, p.cfg)
+	msg := pushoverMessage{
+		Token:   p.cfg.PushoverToken,
+		User:    p.cfg.PushoverUser,
+		Title:   truncateText(title, pushoverTitleLimit, truncateByRunes),
+		Message: message,
+	}
+	if urgent {
+		msg.Priority = 1
+	}
+	if p.cfg.DryRun {
+		preview := msg
+		preview.Token, preview.User = "***", "***"
+		return writeDryRun(os.Stdout, preview, p.cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, p.client, p.cfg, pushoverEndpoint, pushoverEndpoint, nil, payload, checkPushoverResponse)
+}
+
+// checkPushoverResponse 校验 {"status":1} 形式的返回; 参数错误时 Pushover 以 4xx 状态码返回, 由 postJSON 处理
+func checkPushoverResponse(body []byte) error {
+	var resp struct {
+		Status int      `json:"status"`
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode pushover response: %w (payload: %s)", err, string(body))
+	}
+	if resp.Status != 1 {
+		return &PlatformError{Platform: "pushover", Code: resp.Status, Msg: strings.Join(resp.Errors, "; ")}
+	}
+	return nil
+}
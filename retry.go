@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitCodes 飞书触发频率限制时返回的错误码
+var rateLimitCodes = map[int]bool{
+	11232:    true, // 自定义机器人: frequency limited
+	99991400: true, // 开放平台: request trigger frequency limit
+}
+
+// RetryError 表示重试次数耗尽后的最终错误
+type RetryError struct {
+	Attempts   int
+	Elapsed    time.Duration
+	LastStatus int // 最后一次收到的 HTTP 状态码, 0 表示未收到响应
+	Err        error
+}
+
+func (e *RetryError) Error() string {
+	status := "no response"
+	if e.LastStatus != 0 {
+		status = fmt.Sprintf("HTTP %d", e.LastStatus)
+	}
+	return fmt.Sprintf("giving up after %d attempts in %s (last status: %s): %v",
+		e.Attempts, e.Elapsed.Round(time.Millisecond), status, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// postWithRetry 发送 Webhook 请求, 遇到可重试错误时最多重试 cfg.MaxRetries 次
+func postWithRetry(ctx context.Context, client doer, cfg FeishuConfig, payload []byte) error {
+	if cfg.Gzip {
+		compressed, err := gzipBytes(payload)
+		if err != nil {
+			return fmt.Errorf("gzip payload: %w", err)
+		}
+		logger.Debug("compressed payload", "from", len(payload), "to", len(compressed))
+		payload = compressed
+	}
+	return withRetry(ctx, cfg, func() error {
+		return postCard(ctx, client, cfg, payload)
+	})
+}
+
+// withRetry 执行 attempt, 遇到可重试错误时最多重试 cfg.MaxRetries 次
+func withRetry(ctx context.Context, cfg FeishuConfig, attempt func() error) error {
+	start := time.Now()
+	lastStatus := 0
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		lastStatus = responseStatus(err, lastStatus)
+		if !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if i > cfg.MaxRetries {
+			if cfg.MaxRetries == 0 {
+				return err
+			}
+			return &RetryError{Attempts: i, Elapsed: time.Since(start), LastStatus: lastStatus, Err: err}
+		}
+
+		delay := retryDelay(cfg, i, err)
+		runMetrics.retries.Add(1)
+		logger.Warn("send failed, retrying", "attempt", i, "maxRetries", cfg.MaxRetries, "delay", delay, "err", err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// retryDelay 返回第 attempt 次尝试失败后的等待时间: 按 backoffDelay 退避,
+// 若服务端要求了等待时间 (Retry-After 等) 则取二者较大值, 并以 cfg.MaxRetryAfter 封顶
+func retryDelay(cfg FeishuConfig, attempt int, err error) time.Duration {
+	delay := backoffDelay(cfg, attempt)
+	if requested := requestedDelay(err); requested > delay {
+		delay = requested
+		if cfg.MaxRetryAfter > 0 && delay > cfg.MaxRetryAfter {
+			logger.Warn("capping Retry-After", "requested", requested, "cap", cfg.MaxRetryAfter)
+			delay = cfg.MaxRetryAfter
+		}
+	}
+	return delay
+}
+
+// backoffDelay 指数退避: cfg.RetryDelay 每次乘以 cfg.RetryBackoff, 以 cfg.RetryMaxDelay 封顶,
+// 再随机缩短至多 cfg.RetryJitter 的比例, 避免同时失败的多个进程在同一时刻重试
+func backoffDelay(cfg FeishuConfig, attempt int) time.Duration {
+	factor := math.Max(cfg.RetryBackoff, 1)
+	d := float64(cfg.RetryDelay) * math.Pow(factor, float64(attempt-1))
+	if cfg.RetryMaxDelay > 0 && d > float64(cfg.RetryMaxDelay) {
+		d = float64(cfg.RetryMaxDelay)
+	}
+	return time.Duration(d * (1 - cfg.RetryJitter*jitterRand()))
+}
+
+// requestedDelay 返回错误中服务端要求的等待时间, 未指定时返回 0
+func requestedDelay(err error) time.Duration {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	var apiErr *FeishuAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// headerRateLimitReset 开放平台触发频率限制时返回的响应头, 值为距离限额重置的秒数
+const headerRateLimitReset = "x-ogw-ratelimit-reset"
+
+// serverRetryAfter 返回响应头要求的等待时间: 优先 Retry-After, 其次为 x-ogw-ratelimit-reset
+func serverRetryAfter(h http.Header, now time.Time) time.Duration {
+	if d := parseRetryAfter(h.Get("Retry-After"), now); d > 0 {
+		return d
+	}
+	return parseRetryAfter(h.Get(headerRateLimitReset), now)
+}
+
+// parseRetryAfter 解析 Retry-After 头, 支持秒数与 HTTP-date 两种形式, 无法解析时返回 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryable 判断错误是否值得重试: 网络错误、5xx、429 及飞书与其它平台的频率限制
+func retryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var apiErr *FeishuAPIError
+	if errors.As(err, &apiErr) {
+		return rateLimitCodes[apiErr.Code] || rateLimitCodes[apiErr.StatusCode]
+	}
+	var platformErr *PlatformError
+	if errors.As(err, &platformErr) {
+		return platformErr.RateLimited
+	}
+	// SMTP 的 4xx 回复表示暂时性失败 (灰名单、限流等)
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+	// 网络层错误 (连接失败、超时等) 可重试; 响应解析失败等其它错误不重试, 以免重复发卡片
+	var urlErr *url.Error
+	var opErr *net.OpError
+	return (errors.As(err, &urlErr) || errors.As(err, &opErr)) && !errors.Is(err, context.Canceled)
+}
+
+// responseStatus 从错误中提取 HTTP 状态码, 无法提取时沿用 prev
+func responseStatus(err error, prev int) int {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	var apiErr *FeishuAPIError
+	var platformErr *PlatformError
+	if errors.As(err, &apiErr) || errors.As(err, &platformErr) {
+		return http.StatusOK
+	}
+	return prev
+}
+
+// jitterRand 返回 [0, 1) 内的随机数, 测试中可替换为固定值以获得确定的等待时间
+var jitterRand = rand.Float64
+
+// sendJitter 在首次发送前随机等待 [0, cfg.SendJitter), 错开同时结束的任务对同一 Webhook 的请求
+// 等待可被 ctx 取消, 返回 ctx 的错误
+func sendJitter(ctx context.Context, cfg FeishuConfig) error {
+	if cfg.SendJitter <= 0 || cfg.DryRun {
+		return nil
+	}
+	delay := time.Duration(float64(cfg.SendJitter) * jitterRand())
+	logger.Debug("delaying send", "jitter", delay)
+	return sleepContext(ctx, delay)
+}
+
+// sleepContext 等待 d, 上下文取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixJitter 将 jitterRand 固定为 v, 测试结束后恢复
+func fixJitter(t *testing.T, v float64) {
+	t.Helper()
+	saved := jitterRand
+	jitterRand = func() float64 { return v }
+	t.Cleanup(func() { jitterRand = saved })
+}
+
+func retryTestConfig() FeishuConfig {
+	return FeishuConfig{
+		RetryDelay:    time.Second,
+		RetryBackoff:  2,
+		RetryMaxDelay: 10 * time.Second,
+		RetryJitter:   0.2,
+		MaxRetryAfter: 30 * time.Second,
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	fixJitter(t, 0)
+	cfg := retryTestConfig()
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := backoffDelay(cfg, i+1); got != w {
+			t.Errorf("attempt %d: delay = %v, want %v", i+1, got, w)
+		}
+	}
+	cfg.RetryBackoff = 0.5 // 小于 1 时按 1 处理, 不会越等越短
+	if got := backoffDelay(cfg, 3); got != time.Second {
+		t.Errorf("backoff < 1: delay = %v, want 1s", got)
+	}
+}
+
+// 随机缩短的比例不超过 RetryJitter: 结果落在 [d*(1-jitter), d] 内
+func TestBackoffJitterBounds(t *testing.T) {
+	cfg := retryTestConfig()
+	fixJitter(t, 0.5)
+	if got := backoffDelay(cfg, 2); got != 1800*time.Millisecond {
+		t.Errorf("jitter 0.5 of 20%%: delay = %v, want 1.8s", got)
+	}
+
+	jitterRand = rand.Float64
+	for i := 0; i < 1000; i++ {
+		got := backoffDelay(cfg, 2)
+		if got <= 1600*time.Millisecond || got > 2*time.Second {
+			t.Fatalf("delay %v outside (1.6s, 2s]", got)
+		}
+	}
+	cfg.RetryJitter = 0
+	if got := backoffDelay(cfg, 2); got != 2*time.Second {
+		t.Errorf("no jitter: delay = %v, want 2s", got)
+	}
+}
+
+func TestRetryDelayPrefersRetryAfter(t *testing.T) {
+	fixJitter(t, 0)
+	cfg := retryTestConfig()
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"no Retry-After", errors.New("boom"), 2 * time.Second},
+		{"longer Retry-After wins", &HTTPStatusError{StatusCode: 429, RetryAfter: 5 * time.Second}, 5 * time.Second},
+		{"shorter Retry-After ignored", &HTTPStatusError{StatusCode: 429, RetryAfter: time.Second}, 2 * time.Second},
+		{"Feishu rate limit", &FeishuAPIError{Code: 11232, RetryAfter: 7 * time.Second}, 7 * time.Second},
+		{"capped by MaxRetryAfter", &HTTPStatusError{StatusCode: 503, RetryAfter: time.Hour}, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(cfg, 2, tt.err); got != tt.want {
+			t.Errorf("%s: delay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// Retry-After 不受随机缩短影响
+	fixJitter(t, 0.99)
+	if got := retryDelay(cfg, 2, &HTTPStatusError{StatusCode: 429, RetryAfter: 5 * time.Second}); got != 5*time.Second {
+		t.Errorf("Retry-After with jitter: delay = %v, want 5s", got)
+	}
+}
+
+func TestServerRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{http.Header{"Retry-After": {"Fri, 01 Mar 2024 12:00:10 GMT"}}, 10 * time.Second},
+		{http.Header{"X-Ogw-Ratelimit-Reset": {"4"}}, 4 * time.Second},
+		{http.Header{"Retry-After": {"2"}, "X-Ogw-Ratelimit-Reset": {"9"}}, 2 * time.Second},
+		{http.Header{"Retry-After": {"-1"}, "X-Ogw-Ratelimit-Reset": {"9"}}, 9 * time.Second},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{"Retry-After": {"Fri, 01 Mar 2024 11:00:00 GMT"}}, 0},
+	}
+	for _, tt := range tests {
+		if got := serverRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("serverRetryAfter(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSendJitter(t *testing.T) {
+	fixJitter(t, 0.5)
+	cfg := FeishuConfig{SendJitter: 40 * time.Millisecond}
+	start := time.Now()
+	if err := sendJitter(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("slept %v, want at least 20ms", d)
+	}
+
+	// 取消时立即返回
+	fixJitter(t, 0.99)
+	cfg.SendJitter = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if err := sendJitter(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("cancelled jitter took %v", d)
+	}
+
+	cfg.DryRun = true
+	if err := sendJitter(ctx, cfg); err != nil {
+		t.Errorf("dry run jitter: %v", err)
+	}
+}
+
+// fastRetryConfig 返回重试间隔极短的配置
+func fastRetryConfig(maxRetries int) FeishuConfig {
+	return FeishuConfig{MaxRetries: maxRetries, RetryDelay: time.Millisecond, RetryBackoff: 1}
+}
+
+func TestWithRetryExhausted(t *testing.T) {
+	calls := 0
+	err := withRetry(context.Background(), fastRetryConfig(2), func() error {
+		calls++
+		return &HTTPStatusError{StatusCode: http.StatusServiceUnavailable, Body: "busy"}
+	})
+	if calls != 3 {
+		t.Fatalf("attempts = %d, want 3", calls)
+	}
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("err = %v, want *RetryError", err)
+	}
+	if retryErr.Attempts != 3 || retryErr.LastStatus != http.StatusServiceUnavailable {
+		t.Errorf("RetryError = %+v", retryErr)
+	}
+	pattern := regexp.MustCompile(`^giving up after 3 attempts in \d+(\.\d+)?m?s \(last status: HTTP 503\): `)
+	if !pattern.MatchString(err.Error()) {
+		t.Errorf("error message = %q", err)
+	}
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Error("RetryError does not unwrap to the last error")
+	}
+}
+
+func TestWithRetryNoResponse(t *testing.T) {
+	netErr := &url.Error{Op: "Post", URL: "https://example.invalid", Err: errors.New("connection refused")}
+	err := withRetry(context.Background(), fastRetryConfig(1), func() error { return netErr })
+	if err == nil || !strings.Contains(err.Error(), "giving up after 2 attempts") || !strings.Contains(err.Error(), "(last status: no response)") {
+		t.Errorf("error message = %q", err)
+	}
+}
+
+func TestWithRetryStopsEarly(t *testing.T) {
+	// 不可重试的错误直接返回
+	calls := 0
+	plain := errors.New("bad request")
+	if err := withRetry(context.Background(), fastRetryConfig(3), func() error { calls++; return plain }); err != plain || calls != 1 {
+		t.Errorf("non-retryable: err=%v calls=%d", err, calls)
+	}
+
+	// 未开启重试时返回原始错误
+	calls = 0
+	status := &HTTPStatusError{StatusCode: 500}
+	if err := withRetry(context.Background(), fastRetryConfig(0), func() error { calls++; return status }); err != status || calls != 1 {
+		t.Errorf("MaxRetries=0: err=%v calls=%d", err, calls)
+	}
+
+	// 重试中途成功
+	calls = 0
+	err := withRetry(context.Background(), fastRetryConfig(3), func() error {
+		calls++
+		if calls < 3 {
+			return &HTTPStatusError{StatusCode: 429}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("eventual success: err=%v calls=%d", err, calls)
+	}
+}
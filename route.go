@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ================= 按工作路径路由 =================
+// FEISHU_ROUTES="~/work/*=https://.../hook/a|secretA,re:^/srv/(api|web)=https://.../hook/b":
+// 工作路径命中规则时, 卡片改发到该规则的 Webhook (| 后为其签名 Secret, 选填);
+// 按配置顺序取第一条命中的规则, 都不命中时使用默认的 FEISHU_WEBHOOK_URL。
+// 规则与 FEISHU_CWD_ALLOW 相同 (前缀或通配符, 支持 ~), 以 re: 开头时为正则表达式。
+// Webhook 前可加后端名, 如 ~/cn/*=wecom:https://qyapi.weixin.qq.com/..., 命中时改用该后端发送;
+// 不加后端名时只替换飞书 Webhook, 只作用于 Webhook 方式。
+
+// route 一条路由规则
+type route struct {
+	Pattern string         // 前缀或通配符规则, 为空时使用 Regexp
+	Regexp  *regexp.Regexp // re: 规则
+	Backend string         // 命中时切换到的后端, 为空表示飞书 Webhook 且不切换 FEISHU_BACKEND
+	Webhook string
+	Secret  string
+}
+
+// parseRoutes 解析 pattern=[backend:]webhook|secret 形式的路由列表
+func parseRoutes(items []string) ([]route, error) {
+	var routes []route
+	for _, item := range items {
+		pattern, target, ok := strings.Cut(item, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("FEISHU_ROUTES: invalid entry %q (want pattern=webhook or pattern=webhook|secret)", item)
+		}
+		webhook, secret, _ := strings.Cut(target, "|")
+		r := route{Webhook: strings.TrimSpace(webhook), Secret: strings.TrimSpace(secret)}
+		if name, rest, ok := strings.Cut(r.Webhook, ":"); ok && name != "http" && name != "https" {
+			setTarget, known := backends[strings.ToLower(name)]
+			if !known || setTarget == nil {
+				return nil, fmt.Errorf("FEISHU_ROUTES: %s: backend %q cannot be a route target", pattern, name)
+			}
+			r.Backend, r.Webhook = strings.ToLower(name), rest
+		}
+		if err := validateWebhookURL(r.Webhook); err != nil {
+			return nil, fmt.Errorf("FEISHU_ROUTES: %s: %w", pattern, err)
+		}
+		if expr, isRegexp := strings.CutPrefix(pattern, "re:"); isRegexp {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("FEISHU_ROUTES: invalid regexp %q: %w", expr, err)
+			}
+			r.Regexp = re
+		} else {
+			patterns, err := parseCwdPatterns("FEISHU_ROUTES", []string{pattern})
+			if err != nil {
+				return nil, err
+			}
+			r.Pattern = patterns[0]
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// matches 判断工作路径是否命中该规则
+func (r route) matches(cwd string) bool {
+	if r.Regexp != nil {
+		return cwd != "" && r.Regexp.MatchString(cwd)
+	}
+	return cwdMatches(cwd, []string{r.Pattern})
+}
+
+// routeFor 返回按工作路径路由后的配置副本: 命中规则时以该规则的 Webhook 与 Secret 替换默认 Webhook,
+// 规则指定了后端时同时切换 FEISHU_BACKEND; 未命中时原样返回
+func (cfg FeishuConfig) routeFor(cwd string) FeishuConfig {
+	for _, r := range cfg.Routes {
+		if !r.matches(cwd) {
+			continue
+		}
+		backend := r.Backend
+		if backend == "" {
+			backend = backendWebhook
+		} else {
+			cfg.Backend = backend
+		}
+		logger.Debug("routing notification", "cwd", cwd, "backend", backend, "webhook", redactWebhook(r.Webhook))
+		backends[backend](&cfg, r.Webhook, r.Secret)
+		return cfg
+	}
+	return cfg
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ================= 守护进程模式 =================
+// serve 子命令常驻运行, 通过本机 HTTP 或 Unix socket (FEISHU_SERVE_ADDR) 接收通知 JSON,
+// Codex 的 notify 只需一条 curl, 排队、重试与连接复用由守护进程负责。
+// POST /notify 的请求体与命令行参数相同 (单条通知或数组), 入队后立即返回 202;
+// 队列由单个 worker 依次处理, 流程与命令行一次运行相同 (过滤、去重、死信等)。GET /healthz 用于存活检查。
+// 接口没有鉴权, 因此只允许监听回环地址或 Unix socket。
+// 收到 SIGTERM / SIGINT 后停止接收, 在 FEISHU_SIGNAL_GRACE (未设置时为 defaultServeDrain) 内处理完队列,
+// 超时未发出的通知写入死信目录。每条通知使用独立的请求 ID, 处理期间的日志都带上该 ID。
+
+const (
+	defaultServeAddr = "127.0.0.1:8787"
+
+	// serveQueueSize 排队等待发送的通知上限, 队列满时返回 503
+	serveQueueSize = 256
+	// serveShutdownTimeout 停止时等待进行中请求结束的时间
+	serveShutdownTimeout = 5 * time.Second
+	// defaultServeDrain FEISHU_SIGNAL_GRACE 为 0 时, 停止前处理剩余队列的时间;
+	// 命令行一次运行默认立即放弃, 但守护进程的队列中通常还有已接收的通知
+	defaultServeDrain = 10 * time.Second
+)
+
+// notifyQueue 待发送的通知队列; 入队加锁, 保证一次请求中的通知要么全部入队要么全部拒绝
+type notifyQueue struct {
+	mu sync.Mutex
+	ch chan CodexNotification
+}
+
+// push 将通知全部入队, 剩余容量不足时返回 false
+func (q *notifyQueue) push(ns []CodexNotification) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cap(q.ch)-len(q.ch) < len(ns) {
+		return false
+	}
+	for _, n := range ns {
+		q.ch <- n
+	}
+	return true
+}
+
+// runServeCommand 运行守护进程直到收到终止信号, 返回进程退出码
+func runServeCommand() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config error", "err", err)
+		return 1
+	}
+	// 全局 logger 由 worker 按通知设置请求 ID, 其它 goroutine 使用不带请求 ID 的 base
+	applyQuiet(cfg)
+	base := logger
+	ln, err := listenServe(cfg.ServeAddr)
+	if err != nil {
+		base.Error("listen", "addr", cfg.ServeAddr, "err", err)
+		return 1
+	}
+
+	queue := &notifyQueue{ch: make(chan CodexNotification, serveQueueSize)}
+	srv := &http.Server{Handler: serveMux(queue, base), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			base.Error("serve", "err", err)
+		}
+	}()
+
+	stop, cancelStop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancelStop()
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+	done := make(chan struct{})
+	go func() {
+		serveWorker(sendCtx, cfg, queue.ch, base)
+		close(done)
+	}()
+	base.Info("listening for notifications", "addr", cfg.ServeAddr)
+	cfg.printf(os.Stderr, "Listening on %s\n", cfg.ServeAddr)
+
+	<-stop.Done()
+	base.Info("shutting down", "queued", len(queue.ch))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		base.Warn("shutdown", "err", err)
+	}
+	// Shutdown 返回后不再有请求入队, 关闭队列让 worker 处理完剩余通知后退出
+	close(queue.ch)
+	timer := time.NewTimer(serveDrain(cfg))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return 0
+	case <-timer.C:
+	}
+	// 超过 grace: 放弃进行中的发送, 剩余通知由 worker 写入死信目录
+	cancelSend()
+	<-done
+	return 0
+}
+
+// listenServe 按 FEISHU_SERVE_ADDR 监听: unix:<path> 为 Unix socket (仅当前用户可访问), 其它为回环地址的 host:port
+func listenServe(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// 清理上次异常退出留下的 socket 文件, 但不删除其它类型的文件
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		return ln, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("FEISHU_SERVE_ADDR: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("FEISHU_SERVE_ADDR: %q is not a loopback address (the listener has no authentication)", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveDrain 返回停止时处理剩余队列的时间
+func serveDrain(cfg FeishuConfig) time.Duration {
+	if cfg.SignalGrace > 0 {
+		return cfg.SignalGrace
+	}
+	return defaultServeDrain
+}
+
+// serveMux 返回守护进程的 HTTP 路由, 请求处理中的日志写入 log
+func serveMux(queue *notifyQueue, log *slog.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/notify", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStdinBytes))
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		notifications, _, err := parseNotifications(string(body))
+		if err != nil {
+			http.Error(w, "invalid notification JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !queue.push(notifications) {
+			log.Warn("queue full, rejecting notifications", "count", len(notifications))
+			http.Error(w, "queue full", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]int{"queued": len(notifications)})
+	})
+	return mux
+}
+
+// serveWorker 依次处理队列中的通知, 直到队列关闭且为空
+// 处理每条通知时全局 logger 为 base 附加该通知的请求 ID; 只有 worker 修改 logger
+// ctx 取消后 (停止时超过 grace) 剩余通知不再发送, 直接写入死信目录
+func serveWorker(ctx context.Context, cfg FeishuConfig, queue <-chan CodexNotification, base *slog.Logger) {
+	// 启动时以及每次发送失败后, 下一次成功发送会顺带补发积压的死信
+	flushPending := true
+	for n := range queue {
+		logger = base
+		if ctx.Err() != nil {
+			if cfg.typeEnabled(n.Type) {
+				logger.Warn("grace period over, abandoning queued notification", "turnID", n.TurnID)
+				saveDeadLetter(cfg, n, ctx.Err())
+			}
+			continue
+		}
+		c := cfg
+		c.RequestID = newRequestID()
+		logger = base.With("requestID", c.RequestID)
+		rep, err := processNotification(ctx, c, n)
+		if err != nil {
+			logger.Error("config error", "turnID", n.TurnID, "err", err)
+			continue
+		}
+		// 补发的死信各有来源, 不沿用触发补发的通知的请求 ID
+		logger = base
+		switch rep.Outcome {
+		case outcomeSent:
+			if flushPending {
+				flushDeadLetters(ctx, cfg)
+				flushPending = false
+			}
+		case outcomeFailed:
+			flushPending = true
+		}
+	}
+	logger = base
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeWorkerRequestIDPerNotification(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"code":0}`)
+	cfg := testConfig(t, map[string]string{"FEISHU_WEBHOOK_URL": srv.URL + "/hook"})
+
+	var logs bytes.Buffer
+	saved := logger
+	t.Cleanup(func() { logger = saved })
+	base := newLogger(&logs, "info", "json")
+
+	queue := make(chan CodexNotification, 2)
+	queue <- turnNotification(1, false)
+	queue <- turnNotification(2, false)
+	close(queue)
+	serveWorker(context.Background(), cfg, queue, base)
+
+	if len(*got) != 2 {
+		t.Fatalf("got %d requests, want 2", len(*got))
+	}
+	headerIDs := map[string]bool{}
+	for _, req := range *got {
+		headerIDs[req.Header.Get("X-Request-Id")] = true
+	}
+	if len(headerIDs) != 2 || headerIDs[""] {
+		t.Fatalf("X-Request-Id values = %v, want two distinct ids", headerIDs)
+	}
+
+	// 每条 "feishu card sent" 日志都带有对应请求的 ID
+	sent := 0
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("log line is not JSON: %s", line)
+		}
+		if entry["msg"] != "feishu card sent" {
+			continue
+		}
+		sent++
+		if id, _ := entry["requestID"].(string); !headerIDs[id] {
+			t.Errorf("log line has requestID %q, want one of %v", id, headerIDs)
+		}
+	}
+	if sent != 2 {
+		t.Errorf("found %d send log lines, want 2:\n%s", sent, logs.String())
+	}
+	if logger != base {
+		t.Error("worker did not restore the base logger")
+	}
+}
+
+func TestServeDrain(t *testing.T) {
+	if d := serveDrain(FeishuConfig{}); d != defaultServeDrain {
+		t.Errorf("default drain = %v, want %v", d, defaultServeDrain)
+	}
+	if d := serveDrain(FeishuConfig{SignalGrace: 3 * time.Second}); d != 3*time.Second {
+		t.Errorf("drain with FEISHU_SIGNAL_GRACE=3s = %v", d)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ================= 信号处理 =================
+// 收到 SIGTERM / SIGINT 时, 在 FEISHU_SIGNAL_GRACE 内允许进行中的发送完成, 超时 (或再次收到信号)
+// 后取消 context 放弃发送。发送被放弃时进程以 128+信号值 退出; 在 grace 内完成则正常退出。
+// 状态文件均通过临时文件 + rename 原子写入, 中断不会留下半截文件;
+// 被放弃的通知 (包括批量输入中尚未处理的通知) 不会计入去重缓存, 配置了死信目录时会保存以便 replay。
+// 信号处理只在 main 中安装, sink 的 Send 只感知 context 取消。
+
+// signalWatcher 将终止信号转换为 context 取消
+type signalWatcher struct {
+	mu        sync.Mutex
+	sig       os.Signal
+	abandoned bool
+	ch        chan os.Signal
+	done      chan struct{} // stop 时关闭, 让监听 goroutine 退出
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+}
+
+// watchSignals 返回在收到终止信号 (并经过 grace) 后取消的 context
+func watchSignals(parent context.Context, grace time.Duration) (context.Context, *signalWatcher) {
+	ctx, cancel := context.WithCancel(parent)
+	w := &signalWatcher{ch: make(chan os.Signal, 2), done: make(chan struct{}), cancel: cancel}
+	signal.Notify(w.ch, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		// signal.Stop 不会关闭 ch, 未收到信号时需靠 done 退出
+		var sig os.Signal
+		select {
+		case sig = <-w.ch:
+		case <-w.done:
+			return
+		}
+		w.mu.Lock()
+		w.sig = sig
+		w.mu.Unlock()
+		if grace > 0 {
+			logger.Warn("received signal, letting in-flight send finish", "signal", sig.String(), "grace", grace)
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-w.ch:
+			case <-ctx.Done():
+				return
+			case <-w.done:
+				return
+			}
+		}
+		logger.Warn("received signal, abandoning in-flight send", "signal", sig.String())
+		w.mu.Lock()
+		w.abandoned = true
+		w.mu.Unlock()
+		cancel()
+	}()
+	return ctx, w
+}
+
+// stop 停止监听信号、结束监听 goroutine 并释放 context, 可重复调用
+func (w *signalWatcher) stop() {
+	w.stopOnce.Do(func() {
+		signal.Stop(w.ch)
+		close(w.done)
+		w.cancel()
+	})
+}
+
+// interrupted 返回导致发送被放弃的信号, 未被放弃 (未收到信号或已在 grace 内完成) 时返回 nil
+func (w *signalWatcher) interrupted() os.Signal {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.abandoned {
+		return nil
+	}
+	return w.sig
+}
+
+// signalExitCode 返回被信号中断时的退出码 (128+信号值, 与 shell 约定一致)
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// blockingStub 在收到请求后阻塞, 直到 release 关闭或客户端放弃请求
+func blockingStub(t *testing.T) (srv *httptest.Server, arrived chan struct{}, release chan struct{}) {
+	t.Helper()
+	arrived = make(chan struct{}, 1)
+	release = make(chan struct{})
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		arrived <- struct{}{}
+		select {
+		case <-release:
+			io.WriteString(w, `{"code":0}`)
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, arrived, release
+}
+
+// sendDuringSignal 在请求到达服务端后向 watcher 投递 SIGTERM, 返回处理结果
+func sendDuringSignal(t *testing.T, cfg FeishuConfig, arrived, release chan struct{}, releaseAfterSignal bool) (notificationReport, *signalWatcher) {
+	t.Helper()
+	ctx, w := watchSignals(context.Background(), cfg.SignalGrace)
+	t.Cleanup(w.stop)
+	type result struct {
+		rep notificationReport
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rep, err := processNotification(ctx, cfg, testNotification())
+		done <- result{rep, err}
+	}()
+	select {
+	case <-arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the stub")
+	}
+	w.ch <- syscall.SIGTERM
+	if releaseAfterSignal {
+		close(release)
+	}
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		return r.rep, w
+	case <-time.After(5 * time.Second):
+		t.Fatal("send did not return after the signal")
+	}
+	return notificationReport{}, nil
+}
+
+// 没有 grace 时立即放弃发送: 记为失败、写入死信、不计入去重缓存, 状态目录中不留临时文件
+func TestSignalAbandonsSend(t *testing.T) {
+	srv, arrived, release := blockingStub(t)
+	defer close(release)
+	deadDir := t.TempDir()
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":    srv.URL + "/hook",
+		"FEISHU_DEDUP_WINDOW":   "1h",
+		"FEISHU_DEADLETTER_DIR": deadDir,
+		"FEISHU_MAX_RETRIES":    "3",
+		"FEISHU_RETRY_DELAY":    "10ms",
+		"FEISHU_TIMEOUT":        "30s",
+	})
+
+	rep, w := sendDuringSignal(t, cfg, arrived, release, false)
+	if rep.Outcome != outcomeFailed {
+		t.Errorf("outcome = %v, want failed", rep.Outcome)
+	}
+	sig := w.interrupted()
+	if sig != syscall.SIGTERM || signalExitCode(sig) != 143 {
+		t.Errorf("interrupted = %v (exit %d), want SIGTERM and 143", sig, signalExitCode(sig))
+	}
+	if dup, _ := seenRecently(cfg, dedupKey(testNotification()), time.Now()); dup {
+		t.Error("abandoned send recorded in the dedup cache")
+	}
+	if letters, _ := filepath.Glob(filepath.Join(deadDir, "*.json")); len(letters) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(letters))
+	}
+	entries, _ := os.ReadDir(stateDir(cfg))
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") || strings.HasSuffix(e.Name(), ".lock") {
+			t.Errorf("state dir has leftover file %s", e.Name())
+		}
+	}
+}
+
+// grace 内完成的发送照常记为成功, 进程正常退出
+func TestSignalGraceLetsSendFinish(t *testing.T) {
+	srv, arrived, release := blockingStub(t)
+	cfg := testConfig(t, map[string]string{
+		"FEISHU_WEBHOOK_URL":  srv.URL + "/hook",
+		"FEISHU_DEDUP_WINDOW": "1h",
+		"FEISHU_SIGNAL_GRACE": "5s",
+	})
+
+	rep, w := sendDuringSignal(t, cfg, arrived, release, true)
+	if rep.Outcome != outcomeSent {
+		t.Errorf("outcome = %v, want sent", rep.Outcome)
+	}
+	w.stop()
+	if sig := w.interrupted(); sig != nil {
+		t.Errorf("interrupted = %v after the send finished within the grace period", sig)
+	}
+	if dup, _ := seenRecently(cfg, dedupKey(testNotification()), time.Now()); !dup {
+		t.Error("finished send not recorded in the dedup cache")
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	for sig, want := range map[os.Signal]int{syscall.SIGTERM: 143, syscall.SIGINT: 130, os.Interrupt: 130} {
+		if got := signalExitCode(sig); got != want {
+			t.Errorf("signalExitCode(%v) = %d, want %d", sig, got, want)
+		}
+	}
+}
+
+// stop 后监听 goroutine 退出, 不会一直阻塞在信号 channel 上
+func TestSignalWatcherStopExits(t *testing.T) {
+	// 首次 signal.Notify 会启动 os/signal 常驻的 goroutine, 先让它启动再计数
+	_, w := watchSignals(context.Background(), 0)
+	w.stop()
+	time.Sleep(10 * time.Millisecond)
+	base := runtime.NumGoroutine()
+	var watchers []*signalWatcher
+	for i := 0; i < 20; i++ {
+		_, w := watchSignals(context.Background(), time.Second)
+		watchers = append(watchers, w)
+	}
+	for _, w := range watchers {
+		w.stop()
+		w.stop()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running after stop, want at most %d", runtime.NumGoroutine(), base)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
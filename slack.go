@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ================= Slack Incoming Webhook sink =================
+// FEISHU_BACKEND=slack 时改为发送到 Slack Incoming Webhook (FEISHU_SLACK_WEBHOOK_URL),
+// 以 Block Kit 渲染与飞书卡片相同的内容: 标题、输入指令、执行结果、工作路径与 Thread ID。
+// 飞书专有的功能 (@ 用户、确认按钮、更新已发送的卡片、附件) 不适用。
+
+const backendSlack = "slack"
+
+const (
+	slackTextLimit   = 3000 // section 文本的长度上限
+	slackHeaderLimit = 150  // header 文本的长度上限
+	slackFieldLimit  = 10   // section 最多的字段数
+)
+
+func init() {
+	registerBackend(backendSlack, func(cfg *FeishuConfig, webhook, _ string) {
+		cfg.SlackWebhookURL = webhook
+	})
+	registerNotifier("slack", newSlackNotifier)
+}
+
+type slackNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newSlackNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendSlack {
+		return nil, nil
+	}
+	if cfg.SlackWebhookURL == "" {
+		return nil, errors.New("FEISHU_BACKEND=slack requires FEISHU_SLACK_WEBHOOK_URL")
+	}
+	if err := validateWebhookURL(cfg.SlackWebhookURL); err != nil {
+		return nil, fmt.Errorf("FEISHU_SLACK_WEBHOOK_URL: %w", err)
+	}
+	return []Notifier{&slackNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (s *slackNotifier) Name() string { return "slack" }
+
+func (s *slackNotifier) Target() string { return redactWebhook(s.cfg.SlackWebhookURL) }
+
+func (s *slackNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := s.cfg
+	msg, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		n, cfg, failed := prepareNotification(n, cfg)
+		return buildSlackMessage(n, cfg, failed)
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	// Slack 成功时返回 200 与纯文本 ok, 失败时以 4xx 状态码与错误名 (如 invalid_payload) 返回
+	return postJSON(ctx, s.client, cfg, cfg.SlackWebhookURL, s.Target(), nil, payload, nil)
+}
+
+// slackMessage Incoming Webhook 的请求体; text 用于系统通知预览
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"` // plain_text / mrkdwn
+	Text string `json:"text"`
+}
+
+// buildSlackMessage 按飞书卡片的内容顺序组装 Block Kit 消息
+func buildSlackMessage(n CodexNotification, cfg FeishuConfig, failed bool) slackMessage {
+	title := cardTitle(n, cfg, failed)
+	blocks := []slackBlock{{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncateText(title, slackHeaderLimit, truncateByRunes)},
+	}}
+	if cfg.Escalated {
+		blocks = append(blocks, slackSection(fmt.Sprintf("*%s*", slackEscape(cfg.t(msgEscalation)))))
+	}
+	blocks = append(blocks, slackSection(fmt.Sprintf("*%s*\n%s", slackEscape(cfg.t(msgLabelInput)), slackMarkdown(inputText(n, cfg, "\n")))))
+
+	if label, text := eventBody(n, cfg, failed); label != "" {
+		lines := []string{fmt.Sprintf("*%s*\n%s", slackEscape(cfg.t(label)), slackMarkdown(text))}
+		if cfg.PayloadTrimmed {
+			lines = append(lines, "_"+slackEscape(cfg.t(msgTrimmed))+"_")
+		}
+		for _, link := range resultLinks(cfg) {
+			lines = append(lines, slackMarkdown(link))
+		}
+		blocks = append(blocks, slackBlock{Type: "divider"}, slackSection(strings.Join(lines, "\n")))
+	}
+
+	var fields []slackText
+	for _, f := range cardFields(n, cfg) {
+		if len(fields) == slackFieldLimit {
+			break
+		}
+		fields = append(fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", slackEscape(f.Label), slackMarkdown(f.Value))})
+	}
+	blocks = append(blocks, slackBlock{Type: "divider"}, slackBlock{Type: "section", Fields: fields})
+
+	var footer []slackText
+	for _, t := range buildFooter(n, cfg, time.Now()).Elements {
+		footer = append(footer, slackText{Type: "plain_text", Text: t.Content})
+	}
+	if len(footer) > 0 {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: footer})
+	}
+	if cfg.DebugRaw {
+		blocks = append(blocks, slackSection(fmt.Sprintf("*%s*\n%s", slackEscape(cfg.t(msgLabelRaw)), slackMarkdown(debugCodeBlock(n)))))
+	}
+	return slackMessage{Text: title, Blocks: blocks}
+}
+
+// slackSection 构建一段 mrkdwn 文本, 超出 Slack 上限时截断
+func slackSection(text string) slackBlock {
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncateText(text, slackTextLimit, truncateByRunes)}}
+}
+
+// slackEscape 转义 Slack mrkdwn 中的控制字符 &、< 与 >
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+var (
+	markdownBold = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	markdownLink = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+)
+
+// slackMarkdown 将卡片中使用的 Markdown 转为 Slack mrkdwn: **粗体** 改为 *粗体*, [文字](链接) 改为 <链接|文字>;
+// 代码块内容只做转义, 去掉 Slack 不支持的语言标记
+func slackMarkdown(s string) string {
+	var parts []string
+	for _, seg := range splitFencedCode(s) {
+		if seg.Code {
+			parts = append(parts, "```\n"+slackEscape(seg.Text)+"\n```")
+			continue
+		}
+		text := slackEscape(seg.Text)
+		text = markdownBold.ReplaceAllString(text, "*$1*")
+		text = markdownLink.ReplaceAllString(text, "<$2|$1>")
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// checkDirPrivate 在没有 Unix 属主与权限位的平台上不做检查, 默认的用户缓存目录本身只对当前用户可见
+func checkDirPrivate(dir string, info fs.FileInfo) error {
+	return nil
+}
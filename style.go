@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ================= 按通知类型区分的卡片头样式 =================
+// 每种通知类型对应一个 emoji、标题文案与飞书卡片头颜色模板,
+// 可通过 FEISHU_TYPE_STYLE_<type>=color:emoji 覆盖单个条目。
+
+// typeStyle 通知类型的卡片头样式
+type typeStyle struct {
+	Template string // 卡片头颜色模板
+	Emoji    string // 标题前缀 emoji
+	Title    msgKey // 标题文案, 参数为任务摘要
+}
+
+// agent-turn-complete 按结果细分的样式键
+const (
+	typeFailed    = "agent-turn-failed"    // 检测为失败 (status=error 或关键词判断)
+	typeSucceeded = "agent-turn-succeeded" // 通知明确给出 status=success
+	typeCancelled = "agent-turn-cancelled" // 通知明确给出 status=cancelled
+)
+
+// defaultTypeStyles 内置样式表
+var defaultTypeStyles = map[string]typeStyle{
+	"agent-turn-complete": {Template: "indigo", Emoji: "🤖", Title: msgTitleDone},
+	typeFailed:            {Template: "red", Emoji: "🤖", Title: msgTitleFailed},
+	typeSucceeded:         {Template: "green", Emoji: "✅", Title: msgTitleDone},
+	typeCancelled:         {Template: "grey", Emoji: "⏹️", Title: msgTitleCancel},
+	typeTurnStart:         {Template: "blue", Emoji: "▶️", Title: msgTitleStart},
+	typeApproval:          {Template: "orange", Emoji: "✋", Title: msgTitleApproval},
+	typeError:             {Template: "red", Emoji: "❌", Title: msgTitleError},
+	typeTurnAborted:       {Template: "carmine", Emoji: "⛔", Title: msgTitleAborted},
+}
+
+// fixedColorTypes 颜色表达状态的样式, 不受 FEISHU_HEADER_COLOR 影响
+var fixedColorTypes = map[string]bool{
+	typeFailed: true, typeCancelled: true, typeApproval: true, typeError: true, typeTurnAborted: true,
+}
+
+// 通知 status 字段的取值
+const (
+	statusSuccess   = "success"
+	statusError     = "error"
+	statusCancelled = "cancelled"
+)
+
+// normalizeStatus 统一 status 取值, 接受 canceled 拼写; 未知取值返回空字符串, 按未提供处理
+func normalizeStatus(raw string) string {
+	switch status := strings.ToLower(strings.TrimSpace(raw)); status {
+	case statusSuccess, statusError, statusCancelled:
+		return status
+	case "canceled":
+		return statusCancelled
+	default:
+		return ""
+	}
+}
+
+// fallbackTypeStyle 未知通知类型使用的样式
+var fallbackTypeStyle = typeStyle{Template: "blue", Emoji: "🔔", Title: msgTitleEvent}
+
+// headerTemplates 飞书卡片头支持的颜色模板
+var headerTemplates = map[string]bool{
+	"blue": true, "wathet": true, "turquoise": true, "green": true, "yellow": true,
+	"orange": true, "red": true, "carmine": true, "violet": true, "purple": true,
+	"indigo": true, "grey": true, "default": true,
+}
+
+// typeStylePrefix 覆盖样式的环境变量前缀
+const typeStylePrefix = "FEISHU_TYPE_STYLE_"
+
+// normalizeType 统一类型名: 小写并将 _ 替换为 -, 便于在 shell 中以 FEISHU_TYPE_STYLE_AGENT_TURN_FAILED 形式设置
+func normalizeType(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// parseHeaderColor 校验 FEISHU_HEADER_COLOR, 为空时返回空字符串 (使用各类型默认颜色)
+func parseHeaderColor(raw string) (string, error) {
+	color := strings.ToLower(raw)
+	if color == "" || headerTemplates[color] {
+		return color, nil
+	}
+	names := make([]string, 0, len(headerTemplates))
+	for name := range headerTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("FEISHU_HEADER_COLOR: unknown color %q (want one of %s)", raw, strings.Join(names, ", "))
+}
+
+// parseTypeStyles 在内置样式表上应用覆盖项, overrides 的键为类型名, 值为 color:emoji
+// color 与 emoji 均可留空以保留默认值, 如 "red" 或 ":⚠️"
+// headerColor 非空时替换 fixedColorTypes 以外的默认颜色, 按类型的覆盖项优先于它
+func parseTypeStyles(overrides map[string]string, headerColor string) (map[string]typeStyle, error) {
+	styles := make(map[string]typeStyle, len(defaultTypeStyles)+len(overrides))
+	for name, style := range defaultTypeStyles {
+		if headerColor != "" && !fixedColorTypes[name] {
+			style.Template = headerColor
+		}
+		styles[name] = style
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw := overrides[name]
+		typ := normalizeType(name)
+		style, ok := styles[typ]
+		if !ok {
+			style = fallbackStyle(headerColor)
+		}
+		color, emoji, _ := strings.Cut(raw, ":")
+		if color = strings.ToLower(strings.TrimSpace(color)); color != "" {
+			if !headerTemplates[color] {
+				return nil, fmt.Errorf("%s%s: unknown color %q", typeStylePrefix, name, color)
+			}
+			style.Template = color
+		}
+		if emoji = strings.TrimSpace(emoji); emoji != "" {
+			style.Emoji = emoji
+		}
+		styles[typ] = style
+	}
+	return styles, nil
+}
+
+// typeStyleOverrides 收集环境变量与配置文件 (type_style_<type> 键) 中的样式覆盖项, 环境变量优先
+func (s configSource) typeStyleOverrides() map[string]string {
+	overrides := map[string]string{}
+	filePrefix := fileKey(typeStylePrefix)
+	for key, value := range s.file {
+		if name, ok := strings.CutPrefix(key, filePrefix); ok && name != "" {
+			overrides[normalizeType(name)] = value
+		}
+	}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(key, typeStylePrefix); ok && name != "" && strings.TrimSpace(value) != "" {
+			overrides[normalizeType(name)] = value
+		}
+	}
+	return overrides
+}
+
+// styleFor 返回通知对应的卡片头样式
+func (cfg FeishuConfig) styleFor(n CodexNotification, failed bool) typeStyle {
+	typ := normalizeType(n.Type)
+	if typ == typeTurnComplete {
+		switch status := normalizeStatus(n.Status); {
+		case failed:
+			typ = typeFailed
+		case status == statusSuccess:
+			typ = typeSucceeded
+		case status == statusCancelled:
+			typ = typeCancelled
+		}
+	}
+	if style, ok := cfg.TypeStyles[typ]; ok {
+		return style
+	}
+	if style, ok := defaultTypeStyles[typ]; ok {
+		return style
+	}
+	return fallbackStyle(cfg.HeaderColor)
+}
+
+// fallbackStyle 返回未知类型使用的样式, headerColor 非空时替换其颜色
+func fallbackStyle(headerColor string) typeStyle {
+	style := fallbackTypeStyle
+	if headerColor != "" {
+		style.Template = headerColor
+	}
+	return style
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTypeStyles(t *testing.T) {
+	styles, err := parseTypeStyles(map[string]string{
+		"agent-turn-failed":   "carmine:⚠️",
+		"AGENT_TURN_COMPLETE": ":🎉",     // 只覆盖 emoji
+		"agent-turn-start":    "wathet", // 只覆盖颜色
+		"custom-event":        "violet:🧪",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		typ  string
+		want typeStyle
+	}{
+		{"agent-turn-failed", typeStyle{Template: "carmine", Emoji: "⚠️", Title: msgTitleFailed}},
+		{"agent-turn-complete", typeStyle{Template: "indigo", Emoji: "🎉", Title: msgTitleDone}},
+		{"agent-turn-start", typeStyle{Template: "wathet", Emoji: "▶️", Title: msgTitleStart}},
+		{"custom-event", typeStyle{Template: "violet", Emoji: "🧪", Title: msgTitleEvent}},
+		{"approval-requested", defaultTypeStyles[typeApproval]},
+	}
+	for _, tt := range tests {
+		if got := styles[tt.typ]; got != tt.want {
+			t.Errorf("styles[%q] = %+v, want %+v", tt.typ, got, tt.want)
+		}
+	}
+	if len(styles) != len(defaultTypeStyles)+1 {
+		t.Errorf("got %d styles, want the defaults plus custom-event", len(styles))
+	}
+}
+
+func TestParseTypeStylesInvalidColor(t *testing.T) {
+	if _, err := parseTypeStyles(map[string]string{"agent-turn-failed": "crimson:⚠️"}, ""); err == nil {
+		t.Error("unknown color accepted")
+	}
+}
+
+// FEISHU_HEADER_COLOR 只替换非状态类型的默认颜色, 按类型的覆盖项优先
+func TestTypeStylesHeaderColor(t *testing.T) {
+	styles, err := parseTypeStyles(map[string]string{"agent-turn-start": "green"}, "purple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for typ, want := range map[string]string{
+		"agent-turn-complete": "purple",
+		typeFailed:            "red",
+		typeTurnStart:         "green",
+	} {
+		if got := styles[typ].Template; got != want {
+			t.Errorf("styles[%q].Template = %q, want %q", typ, got, want)
+		}
+	}
+	if got := fallbackStyle("purple").Template; got != "purple" {
+		t.Errorf("fallback template = %q, want purple", got)
+	}
+}
+
+func TestTypeStyleFromEnv(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_TYPE_STYLE_AGENT_TURN_FAILED": "orange:🔥"})
+	n := testNotification()
+	n.Status = statusError
+	style := cfg.styleFor(n, true)
+	if style.Template != "orange" || style.Emoji != "🔥" {
+		t.Errorf("failed style = %+v, want orange 🔥", style)
+	}
+	n.Status = statusSuccess
+	if got := cfg.styleFor(n, false); got != defaultTypeStyles[typeSucceeded] {
+		t.Errorf("succeeded style = %+v", got)
+	}
+	n.Type = "something-new"
+	if got := cfg.styleFor(n, false); got != fallbackTypeStyle {
+		t.Errorf("unknown type style = %+v, want the fallback", got)
+	}
+}
+
+// FEISHU_HEADER_COLOR 只影响成功的卡片, 失败仍为红色
+func TestHeaderColorOverride(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_HEADER_COLOR": "Turquoise"})
+	if got := buildCard(testNotification(), cfg, false).Header.Template; got != "turquoise" {
+		t.Errorf("success template = %q, want turquoise", got)
+	}
+	failed := testNotification()
+	failed.Status = statusError
+	if got := buildCard(failed, cfg, true).Header.Template; got != "red" {
+		t.Errorf("failure template = %q, want red", got)
+	}
+	if got := buildCard(testNotification(), testConfig(t, nil), false).Header.Template; got != "indigo" {
+		t.Errorf("default template = %q, want indigo", got)
+	}
+}
+
+func TestHeaderColorRejected(t *testing.T) {
+	isolateEnv(t, map[string]string{"FEISHU_HEADER_COLOR": "pink"})
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), `unknown color "pink"`) || !strings.Contains(err.Error(), "wathet") {
+		t.Errorf("loadConfig error = %v, want the unknown color and the allowed list", err)
+	}
+}
+
+// status 字段优先于关键词判断; 缺省或未知取值时退回 FEISHU_FAILURE_KEYWORDS
+func TestStatusHeaderStyle(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"FEISHU_FAILURE_KEYWORDS": "failed"})
+	tests := []struct {
+		status, result string
+		template       string
+		emoji          string
+	}{
+		{"success", "2 tests failed before the fix, all pass now", "green", "✅"},
+		{"error", "looks fine", "red", "🤖"},
+		{"cancelled", "stopped", "grey", "⏹️"},
+		{"Canceled", "stopped", "grey", "⏹️"},
+		{"", "go test failed: 3 tests", "red", "🤖"},
+		{"", "all good", "indigo", "🤖"},
+		{"unknown", "all good", "indigo", "🤖"},
+	}
+	for _, tt := range tests {
+		raw := `{"type":"agent-turn-complete","turn-id":"t","input-messages":["run"],"last-assistant-message":"` + tt.result + `"`
+		if tt.status != "" {
+			raw += `,"status":"` + tt.status + `"`
+		}
+		notifications, _, err := parseNotifications(raw + "}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, content := renderContent(notifications[0], cfg)
+		header := content.(FeishuCard).Header
+		if header.Template != tt.template || !strings.HasPrefix(header.Title.Content, tt.emoji+" ") {
+			t.Errorf("status=%q result=%q: header %q %q, want %s %s", tt.status, tt.result, header.Template, header.Title.Content, tt.template, tt.emoji)
+		}
+	}
+}
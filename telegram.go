@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ================= Telegram Bot sink =================
+// FEISHU_BACKEND=telegram 时通过 Bot API 的 sendMessage 发送 (FEISHU_TELEGRAM_BOT_TOKEN 与 FEISHU_TELEGRAM_CHAT_ID),
+// 内容与飞书卡片相同, 以 MarkdownV2 渲染。MarkdownV2 要求转义大部分标点, 因此卡片中的 Markdown
+// 只保留粗体、行内代码、链接与代码块, 其余文本一律转义。
+// token 位于请求路径中, 日志与错误信息中的地址会被隐藏。
+
+const backendTelegram = "telegram"
+
+const (
+	defaultTelegramAPIBase = "https://api.telegram.org"
+
+	// telegramTextLimit 消息文本的最大字符数
+	telegramTextLimit = 4096
+)
+
+func init() {
+	registerBackend(backendTelegram, nil)
+	registerNotifier("telegram", newTelegramNotifier)
+}
+
+type telegramNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newTelegramNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendTelegram {
+		return nil, nil
+	}
+	var missing []string
+	for _, kv := range [][2]string{
+		{"FEISHU_TELEGRAM_BOT_TOKEN", cfg.TelegramBotToken},
+		{"FEISHU_TELEGRAM_CHAT_ID", cfg.TelegramChatID},
+	} {
+		if kv[1] == "" {
+			missing = append(missing, kv[0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("FEISHU_BACKEND=telegram requires %s", strings.Join(missing, ", "))
+	}
+	// 字节数不小于字符数, 按字节收紧消息体即可让文本不超过 Telegram 的字符上限
+	if cfg.MaxPayloadBytes <= 0 || cfg.MaxPayloadBytes > telegramTextLimit {
+		cfg.MaxPayloadBytes = telegramTextLimit
+	}
+	return []Notifier{&telegramNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (t *telegramNotifier) Name() string { return "telegram" }
+
+func (t *telegramNotifier) Target() string { return "chat:" + t.cfg.TelegramChatID }
+
+// telegramMessage sendMessage 的请求体
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+func (t *telegramNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := t.cfg
+	msg, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		n, cfg, failed := prepareNotification(n, cfg)
+		return telegramMessage{
+			ChatID:                cfg.TelegramChatID,
+			Text:                  buildTelegramText(n, cfg, failed),
+			ParseMode:             "MarkdownV2",
+			DisableWebPagePreview: true,
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	endpoint := cfg.TelegramAPIBase + "/bot" + cfg.TelegramBotToken + "/sendMessage"
+	return postJSON(ctx, t.client, cfg, endpoint, cfg.TelegramAPIBase+"/bot***/sendMessage", nil, payload, checkTelegramResponse)
+}
+
+// validateTelegramAPIBase 校验 FEISHU_TELEGRAM_API_BASE (自建 Bot API 服务或测试时使用), 为空时使用官方地址
+func validateTelegramAPIBase(raw string) (string, error) {
+	if raw == "" {
+		return defaultTelegramAPIBase, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("FEISHU_TELEGRAM_API_BASE: invalid URL %q", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
+
+// checkTelegramResponse 校验 Bot API 的 {"ok":true} 返回
+func checkTelegramResponse(body []byte) error {
+	var resp struct {
+		OK          bool   `json:"ok"`
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode telegram response: %w (payload: %s)", err, string(body))
+	}
+	if !resp.OK {
+		return &PlatformError{Platform: "telegram", Code: resp.ErrorCode, Msg: resp.Description}
+	}
+	return nil
+}
+
+// buildTelegramText 按飞书卡片的内容顺序组装 MarkdownV2 文本, 超出 Telegram 上限时截断
+func buildTelegramText(n CodexNotification, cfg FeishuConfig, failed bool) string {
+	sections := []string{"*" + telegramEscape(cardTitle(n, cfg, failed)) + "*"}
+	if cfg.Escalated {
+		sections = append(sections, "*"+telegramEscape(cfg.t(msgEscalation))+"*")
+	}
+	sections = append(sections, fmt.Sprintf("*%s*\n%s", telegramEscape(cfg.t(msgLabelInput)), telegramMarkdown(inputText(n, cfg, "\n"))))
+	if label, text := eventBody(n, cfg, failed); label != "" {
+		lines := []string{fmt.Sprintf("*%s*\n%s", telegramEscape(cfg.t(label)), telegramMarkdown(text))}
+		if cfg.PayloadTrimmed {
+			lines = append(lines, "_"+telegramEscape(cfg.t(msgTrimmed))+"_")
+		}
+		for _, link := range resultLinks(cfg) {
+			lines = append(lines, telegramMarkdown(link))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	var fields []string
+	for _, f := range cardFields(n, cfg) {
+		fields = append(fields, fmt.Sprintf("*%s:* %s", telegramEscape(f.Label), telegramMarkdown(f.Value)))
+	}
+	sections = append(sections, strings.Join(fields, "\n"))
+	var footer []string
+	for _, t := range buildFooter(n, cfg, time.Now()).Elements {
+		footer = append(footer, telegramEscape(t.Content))
+	}
+	if len(footer) > 0 {
+		sections = append(sections, "_"+strings.Join(footer, " · ")+"_")
+	}
+	if cfg.DebugRaw {
+		sections = append(sections, fmt.Sprintf("*%s*\n%s", telegramEscape(cfg.t(msgLabelRaw)), telegramMarkdown(debugCodeBlock(n))))
+	}
+	text := strings.Join(sections, "\n\n")
+	if len([]rune(text)) > telegramTextLimit {
+		// 收紧预算后仍超出 (如字段过长): 截断可能破坏实体, 退回为转义后的纯文本, 转义最多使长度翻倍
+		text = telegramEscape(truncateText(stripTelegramMarkup(text), telegramTextLimit/2, truncateByRunes))
+	}
+	return text
+}
+
+// telegramSpecial MarkdownV2 中需要转义的字符
+const telegramSpecial = "_*[]()~`>#+-=|{}.!\\"
+
+// telegramEscape 转义 MarkdownV2 普通文本中的全部特殊字符
+func telegramEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(telegramSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// telegramEscapeCode 转义代码与链接地址中的 ` 与 \
+func telegramEscapeCode(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+// telegramInline 卡片 Markdown 中保留的行内格式: 行内代码、粗体与链接
+var telegramInline = regexp.MustCompile("`([^`\n]+)`|\\*\\*([^*\n]+)\\*\\*|\\[([^\\]\n]+)\\]\\(([^)\\s]+)\\)")
+
+// telegramMarkdown 将卡片中使用的 Markdown 转为 MarkdownV2: 代码块与行内代码、粗体、链接保留, 其余文本转义
+func telegramMarkdown(s string) string {
+	var parts []string
+	for _, seg := range splitFencedCode(s) {
+		if seg.Code {
+			parts = append(parts, "```\n"+telegramEscapeCode(seg.Text)+"\n```")
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range telegramInline.FindAllStringSubmatchIndex(seg.Text, -1) {
+			b.WriteString(telegramEscape(seg.Text[last:m[0]]))
+			switch {
+			case m[2] >= 0:
+				b.WriteString("`" + telegramEscapeCode(seg.Text[m[2]:m[3]]) + "`")
+			case m[4] >= 0:
+				b.WriteString("*" + telegramEscape(seg.Text[m[4]:m[5]]) + "*")
+			default:
+				link := strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(seg.Text[m[8]:m[9]])
+				b.WriteString("[" + telegramEscape(seg.Text[m[6]:m[7]]) + "](" + link + ")")
+			}
+			last = m[1]
+		}
+		b.WriteString(telegramEscape(seg.Text[last:]))
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, "\n")
+}
+
+// stripTelegramMarkup 去掉 MarkdownV2 的转义与格式字符, 得到近似的纯文本
+func stripTelegramMarkup(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' || r == '_' || r == '`':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// ================= 自定义卡片模板 =================
+// FEISHU_CARD_TEMPLATE_FILE 指向一个 text/template 文件, 渲染结果为卡片的 elements JSON 数组,
+// 替换内置的元素构建; 卡片头、签名与发送流程保持不变。
+// 模板执行失败或输出不是合法的 JSON 数组时, 记录警告并回退到内置布局。
+
+// cardTemplateData 是模板的渲染上下文: 通知的全部字段, 以及渲染时计算出的派生值
+type cardTemplateData struct {
+	CodexNotification
+	Failed bool   // 是否判定为失败
+	Result string // 已按 resultLimit 截断并处理空结果/失败占位的执行结果; 其它通知类型为对应详情
+	Title  string // 卡片标题
+}
+
+// cardTemplateFuncs 模板中可用的辅助函数
+var cardTemplateFuncs = template.FuncMap{
+	// truncate 按字符数截断, 参数顺序便于管道写法: {{.Cwd | truncate 40}}
+	"truncate": func(limit int, s string) string {
+		return truncateText(s, limit, truncateByRunes)
+	},
+	// escape 转义为 JSON 字符串内容 (不含两侧引号), 用于 "content": "{{escape .Result}}"
+	"escape": func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b[1 : len(b)-1])
+	},
+	// json 输出任意值的 JSON 表示, 如 {{json .InputMessages}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+// loadCardTemplate 读取并解析卡片模板; 文件无法读取是配置错误, 语法错误则回退到内置布局
+func loadCardTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("FEISHU_CARD_TEMPLATE_FILE: %w", err)
+	}
+	tmpl, err := template.New(path).Funcs(cardTemplateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		logger.Warn("ignoring invalid card template, using the built-in layout", "file", path, "err", err)
+		return nil, nil
+	}
+	return tmpl, nil
+}
+
+// templateElements 用自定义模板渲染卡片元素; 失败时返回 nil, 由调用方回退到内置布局
+func templateElements(n CodexNotification, cfg FeishuConfig, failed bool) []interface{} {
+	_, result := eventBody(n, cfg, failed)
+	data := cardTemplateData{
+		CodexNotification: n,
+		Failed:            failed,
+		Result:            result,
+		Title:             cardTitle(n, cfg, failed),
+	}
+	var buf bytes.Buffer
+	if err := cfg.CardTemplate.Execute(&buf, data); err != nil {
+		logger.Warn("card template failed, using the built-in layout", "err", err)
+		return nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		logger.Warn("card template did not render a JSON array, using the built-in layout", "err", err)
+		return nil
+	}
+	elements := make([]interface{}, len(raw))
+	for i, el := range raw {
+		elements[i] = el
+	}
+	return elements
+}
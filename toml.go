@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ================= TOML 配置文件 =================
+// config.toml 与 config.json 的键相同 (去掉 FEISHU_ 前缀的小写变量名), 与 Codex 自身的配置格式一致。
+// 不引入依赖, 只解析配置所需的子集:
+//   - key = "basic string" / 'literal string' / 整数 / 浮点数 / true / false
+//   - 数组 (可跨行, 元素为上述标量), 按逗号拼接, 与 JSON 配置中的数组一致
+//   - 内联表 {k = "v"} 与 [table] 段, 转为 JSON 对象字符串 (如 extra_headers)
+// 不支持多行字符串、嵌套表与表数组。
+
+// parseTOMLConfig 将 TOML 配置展开为字符串键值, 结果与 parseConfigFile 相同
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	p := &tomlParser{s: string(data), line: 1}
+	out := map[string]string{}
+	tables := map[string]map[string]string{}
+	var table string
+	for {
+		p.skipBlank()
+		if p.eof() {
+			break
+		}
+		if p.peek() == '[' {
+			p.pos++
+			p.skipSpace()
+			name, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.eof() || p.peek() != ']' {
+				return nil, p.errorf("expected ] after table name %q", name)
+			}
+			p.pos++
+			if err := p.endOfLine(); err != nil {
+				return nil, err
+			}
+			if _, dup := tables[name]; dup {
+				return nil, p.errorf("table [%s] defined twice", name)
+			}
+			if _, dup := out[name]; dup {
+				return nil, p.errorf("table [%s] conflicts with key %q", name, name)
+			}
+			tables[name] = map[string]string{}
+			table = name
+			continue
+		}
+
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.eof() || p.peek() != '=' {
+			return nil, p.errorf("expected = after key %q", key)
+		}
+		p.pos++
+		p.skipSpace()
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+
+		if table != "" {
+			s, ok := v.(string)
+			if !ok {
+				return nil, p.errorf("key %q in table [%s] must be a plain value", key, table)
+			}
+			tables[table][key] = s
+			continue
+		}
+		if _, dup := out[key]; dup {
+			return nil, p.errorf("key %q defined twice", key)
+		}
+		switch val := v.(type) {
+		case string:
+			out[key] = strings.TrimSpace(val)
+		case []string:
+			out[key] = strings.Join(val, ",")
+		case map[string]string:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = string(b)
+		}
+	}
+	for name, t := range tables {
+		if _, dup := out[name]; dup {
+			return nil, fmt.Errorf("table [%s] conflicts with key %q", name, name)
+		}
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = string(b)
+	}
+	return out, nil
+}
+
+// tomlParser 逐字符扫描 TOML 文本, line 用于错误信息
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *tomlParser) peek() byte { return p.s[p.pos] }
+
+// skipSpace 跳过行内空白
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipBlank 跳过空白、换行与注释
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine 要求当前行剩余部分只有空白或注释
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	if !p.eof() && p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if !p.eof() && p.peek() == '\r' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	p.pos++
+	p.line++
+	return nil
+}
+
+// key 解析裸键 (字母、数字、_ 与 -) 或带引号的键
+func (p *tomlParser) key() (string, error) {
+	if p.eof() {
+		return "", p.errorf("expected a key")
+	}
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.str()
+	}
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		if c == '.' {
+			return "", p.errorf("dotted keys are not supported")
+		}
+		break
+	}
+	if p.pos == start {
+		return "", p.errorf("unexpected %q, expected a key", p.peek())
+	}
+	return p.s[start:p.pos], nil
+}
+
+// value 解析一个值: 标量返回 string, 数组返回 []string, 内联表返回 map[string]string
+func (p *tomlParser) value() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.peek() {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	default:
+		return p.bare()
+	}
+}
+
+// str 解析基本字符串 (支持转义) 或字面量字符串
+func (p *tomlParser) str() (string, error) {
+	quote := p.peek()
+	if strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3)) {
+		return "", p.errorf("multi-line strings are not supported")
+	}
+	start := p.pos
+	p.pos++
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\n':
+			return "", p.errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			raw := p.s[start:p.pos]
+			if quote == '\'' {
+				return raw[1 : len(raw)-1], nil
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return "", p.errorf("invalid string %s", raw)
+			}
+			return s, nil
+		default:
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// bare 解析布尔值与数字; 数字中的下划线分隔符会被去掉
+func (p *tomlParser) bare() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' || c == ']' || c == '}' || c == '#' {
+			break
+		}
+		p.pos++
+	}
+	tok := p.s[start:p.pos]
+	switch tok {
+	case "":
+		return "", p.errorf("expected a value")
+	case "true", "false":
+		return tok, nil
+	}
+	num := strings.ReplaceAll(tok, "_", "")
+	if _, err := strconv.ParseFloat(num, 64); err != nil {
+		return "", p.errorf("invalid value %q (strings must be quoted)", tok)
+	}
+	return num, nil
+}
+
+// array 解析数组, 元素之间允许换行与注释, 允许末尾逗号
+func (p *tomlParser) array() ([]string, error) {
+	p.pos++ // [
+	items := []string{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return items, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, p.errorf("nested arrays and tables are not supported")
+		}
+		items = append(items, s)
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+// inlineTable 解析单行内联表 {k = "v", ...}
+func (p *tomlParser) inlineTable() (map[string]string, error) {
+	p.pos++ // {
+	table := map[string]string{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.peek() == '}' && len(table) == 0 {
+			p.pos++
+			return table, nil
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.eof() || p.peek() != '=' {
+			return nil, p.errorf("expected = after key %q", key)
+		}
+		p.pos++
+		p.skipSpace()
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, p.errorf("key %q in inline table must be a plain value", key)
+		}
+		table[key] = s
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLConfig(t *testing.T) {
+	data := `# codex-feishu 配置
+webhook_url = "https://open.feishu.cn/open-apis/bot/v2/hook/abc"  # 行尾注释
+secret = 'C:\raw\path'
+app_name = "Agent \"X\"\t\u4e2d"
+max_retries = 3
+max_payload_bytes = 28_672
+similarity_threshold = 0.85
+dedup_inputs = true
+"quoted-key" = "v"
+sinks = [
+  "feishu",   # 主群
+  "slack",
+]
+empty_list = []
+footer_lines = ["time", 'hash']
+mention = {alice = "ou_a", bob = "ou_b"}
+
+[extra_headers]
+Authorization = "Bearer t"
+X-Trace = 'abc'
+`
+	got, err := parseTOMLConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"webhook_url":          "https://open.feishu.cn/open-apis/bot/v2/hook/abc",
+		"secret":               `C:\raw\path`,
+		"app_name":             "Agent \"X\"\t中",
+		"max_retries":          "3",
+		"max_payload_bytes":    "28672",
+		"similarity_threshold": "0.85",
+		"dedup_inputs":         "true",
+		"quoted-key":           "v",
+		"sinks":                "feishu,slack",
+		"empty_list":           "",
+		"footer_lines":         "time,hash",
+		"mention":              `{"alice":"ou_a","bob":"ou_b"}`,
+		"extra_headers":        `{"Authorization":"Bearer t","X-Trace":"abc"}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOMLConfig =\n%v\nwant\n%v", got, want)
+	}
+}
+
+// 同样的配置写成 JSON 与 TOML 得到相同的键值
+func TestTOMLMatchesJSON(t *testing.T) {
+	fromJSON, err := parseConfigFile([]byte(`{
+  "webhook_url": " https://example.test/hook ",
+  "timeout": "5s",
+  "max_retries": 2,
+  "gzip": true,
+  "sinks": ["feishu", "ntfy"],
+  "extra_headers": {"X-A": "1"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTOML, err := parseTOMLConfig([]byte(`
+webhook_url = " https://example.test/hook "
+timeout = "5s"
+max_retries = 2
+gzip = true
+sinks = ["feishu", "ntfy"]
+extra_headers = { X-A = "1" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromTOML) {
+		t.Errorf("JSON = %v\nTOML = %v", fromJSON, fromTOML)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"webhook_url = https://x", `line 1: invalid value "https://x"`},
+		{"a = 1\nb = \"open", "line 2: unterminated string"},
+		{"a = 1\na = 2", `line 2: key "a" defined twice`},
+		{"a.b = 1", "dotted keys are not supported"},
+		{"a = \"\"\"x\"\"\"", "multi-line strings are not supported"},
+		{"a = [[1]]", "nested arrays and tables are not supported"},
+		{"a = [1, 2", "unterminated array"},
+		{"a = 1 2", "unexpected '2' after value"},
+		{"a 1", `expected = after key "a"`},
+		{"[t]\na = 1\n[t]", "table [t] defined twice"},
+		{"t = 1\n[t]", `table [t] conflicts with key "t"`},
+		{"[t]\na = [1]", `key "a" in table [t] must be a plain value`},
+		{"a = {b = [1]}", `key "b" in inline table must be a plain value`},
+		{"a = {b = 1", "unterminated inline table"},
+		{"= 1", "expected a key"},
+	}
+	for _, tt := range tests {
+		_, err := parseTOMLConfig([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTOMLConfig(%q) error = %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestTOMLConfigFile(t *testing.T) {
+	isolateEnv(t, nil)
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "codex-feishu")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	toml := `webhook_url = "https://open.feishu.cn/open-apis/bot/v2/hook/toml"
+app_name = "FromTOML"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(toml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WebhookURL != "https://open.feishu.cn/open-apis/bot/v2/hook/toml" || cfg.AppName != "FromTOML" {
+		t.Errorf("config from TOML: webhook=%q app=%q", cfg.WebhookURL, cfg.AppName)
+	}
+
+	// 环境变量优先于配置文件
+	t.Setenv("FEISHU_APP_NAME", "FromEnv")
+	if cfg, err = loadConfig(); err != nil || cfg.AppName != "FromEnv" {
+		t.Errorf("AppName = %q, err = %v; want the environment value", cfg.AppName, err)
+	}
+
+	// config.json 与 config.toml 同时存在时使用 config.json
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"app_name":"FromJSON"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("FEISHU_APP_NAME")
+	if cfg, err = loadConfig(); err != nil || cfg.AppName != "FromJSON" {
+		t.Errorf("AppName = %q, err = %v; want config.json to win", cfg.AppName, err)
+	}
+}
+
+func TestTOMLConfigFileError(t *testing.T) {
+	isolateEnv(t, map[string]string{"FEISHU_CONFIG_FILE": writeTestFile(t, "notify.toml", "timeout = 5s\n")})
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "notify.toml") || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("loadConfig error = %v, want the file name and line", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// truncateStrategy 决定截断时如何计量字符串长度, 各渠道按自身限制选择
+type truncateStrategy int
+
+const (
+	truncateByRunes truncateStrategy = iota // 按 rune 计数 (飞书卡片等按字符展示的渠道)
+	truncateByBytes                         // 按 UTF-8 字节计数 (如企业微信按字节限制)
+)
+
+// ellipsis 截断后追加的省略号, 在两种计量方式下长度均为 3
+const ellipsis = "..."
+
+// size 返回单个 rune 在该策略下的长度
+func (st truncateStrategy) size(r rune) int {
+	if st == truncateByBytes {
+		return utf8.RuneLen(r)
+	}
+	return 1
+}
+
+// measure 返回字符串在该策略下的长度
+func (st truncateStrategy) measure(s string) int {
+	if st == truncateByBytes {
+		return len(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += st.size(r)
+	}
+	return n
+}
+
+// prefix 返回长度不超过 limit 的最长前缀, 不会截断在多字节字符中间
+func (st truncateStrategy) prefix(s string, limit int) string {
+	n := 0
+	for i, r := range s {
+		n += st.size(r)
+		if n > limit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// truncate 截断字符串到指定长度, 过长时添加省略号 (省略号计入长度)
+func truncate(s string, limit int, st truncateStrategy) string {
+	if limit <= 0 {
+		return ""
+	}
+	if st.measure(s) <= limit {
+		return s
+	}
+	if limit <= len(ellipsis) {
+		return st.prefix(s, limit)
+	}
+	return st.prefix(s, limit-len(ellipsis)) + ellipsis
+}
+
+// truncateRunes 截断字符串到指定的 rune 长度, 过长时添加省略号
+func truncateRunes(s string, limit int) string {
+	return truncate(s, limit, truncateByRunes)
+}
+
+// truncateText 截断展示文本: 以 ASCII 为主的文本在单词边界处截断, 其余 (如中文) 硬截断
+func truncateText(s string, limit int, st truncateStrategy) string {
+	if !mostlyASCII(s) {
+		return truncate(s, limit, st)
+	}
+	return truncateWords(s, limit, st)
+}
+
+// truncateWords 截断到 limit 以内, 并回退到最后一个空白处再追加省略号
+// 若回退会丢掉超过一半的内容 (如超长单词或 URL), 则退化为硬截断
+func truncateWords(s string, limit int, st truncateStrategy) string {
+	if limit <= len(ellipsis) || st.measure(s) <= limit {
+		return truncate(s, limit, st)
+	}
+	cut := st.prefix(s, limit-len(ellipsis))
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i >= 0 && st.measure(cut[:i]) >= st.measure(cut)/2 {
+		return strings.TrimRightFunc(cut[:i], unicode.IsSpace) + ellipsis
+	}
+	return cut + ellipsis
+}
+
+// mostlyASCII 判断非空白字符中 ASCII 是否占绝大多数 (>= 90%)
+func mostlyASCII(s string) bool {
+	total, ascii := 0, 0
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if r < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	return total > 0 && ascii*10 >= total*9
+}
+
+// suffix 返回长度不超过 limit 的最长后缀, 不会截断在多字节字符中间
+func (st truncateStrategy) suffix(s string, limit int) string {
+	n := 0
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		n += st.size(r)
+		if n > limit {
+			return s[i:]
+		}
+		i -= size
+	}
+	return s
+}
+
+// truncateMiddle 截断到 limit 以内, 保留开头与结尾, 中间以省略号代替 (省略号计入长度)
+func truncateMiddle(s string, limit int, st truncateStrategy) string {
+	if limit <= len(ellipsis) || st.measure(s) <= limit {
+		return truncate(s, limit, st)
+	}
+	head := st.prefix(s, (limit-len(ellipsis))/2)
+	tail := st.suffix(s, limit-len(ellipsis)-st.measure(head))
+	return head + ellipsis + tail
+}
+
+// headTailLines 保留前 keep 行与后 keep 行, 中间替换为 marker 返回的省略标记;
+// 总行数不超过 2*keep 时原样返回
+func headTailLines(s string, keep int, marker func(omitted int) string) string {
+	lines := strings.Split(s, "\n")
+	if keep <= 0 || len(lines) <= 2*keep {
+		return s
+	}
+	omitted := len(lines) - 2*keep
+	out := make([]string, 0, 2*keep+1)
+	out = append(out, lines[:keep]...)
+	out = append(out, marker(omitted))
+	out = append(out, lines[len(lines)-keep:]...)
+	return strings.Join(out, "\n")
+}
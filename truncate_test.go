@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateTextEnglish(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"the quick brown fox jumps", 20, "the quick brown..."},
+		{"the quick brown fox", 19, "the quick brown fox"},                   // 未超长时原样返回
+		{"hello   world again", 15, "hello..."},                              // 回退时去掉多余空白
+		{"supercalifragilisticexpialidocious x", 20, "supercalifragilis..."}, // 超长单词退化为硬截断
+		{"a verylongwordthatgoesonandon", 20, "a verylongwordtha..."},        // 回退会丢掉过半内容时硬截断
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.in, tt.limit, truncateByRunes); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// 中文没有空格, 保持按字符硬截断
+func TestTruncateTextChinese(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"这是一个很长的中文句子需要截断", 10, "这是一个很长的..."},
+		{"修复 登录 页面 的 样式 问题 并 补充 测试", 10, "修复 登录 页..."},
+		{"中文", 10, "中文"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.in, tt.limit, truncateByRunes); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// limit <= 3 与空字符串的行为与 truncateRunes 一致
+func TestTruncateTextEdgeCases(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"", 10, ""},
+		{"", 0, ""},
+		{"hello world", 0, ""},
+		{"hello world", 2, "he"},
+		{"hello world", 3, "hel"},
+		{"你好世界", 3, "你好世"},
+	}
+	for _, tt := range tests {
+		got := truncateText(tt.in, tt.limit, truncateByRunes)
+		if got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+		if runes := truncateRunes(tt.in, tt.limit); got != runes {
+			t.Errorf("truncateText(%q, %d) = %q, truncateRunes = %q", tt.in, tt.limit, got, runes)
+		}
+	}
+}
+
+func TestMostlyASCII(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"", false},
+		{"   ", false},
+		{"plain english text", true},
+		{"fix bug in café module", true},
+		{"hello 世界", false},
+		{"中文内容", false},
+	}
+	for _, tt := range tests {
+		if got := mostlyASCII(tt.in); got != tt.want {
+			t.Errorf("mostlyASCII(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateStrategies(t *testing.T) {
+	const in = "你好世界abc" // 7 个 rune, 15 字节, 显示宽度 11
+	tests := []struct {
+		st    truncateStrategy
+		limit int
+		want  string
+	}{
+		{truncateByRunes, 7, in},
+		{truncateByRunes, 6, "你好世..."},
+		{truncateByBytes, 15, in},
+		{truncateByBytes, 14, "你好世..."},
+		{truncateByBytes, 11, "你好..."}, // 不截断在多字节字符中间
+		{truncateByBytes, 2, ""},
+	}
+	for _, tt := range tests {
+		got := truncate(in, tt.limit, tt.st)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d, %d) = %q, want %q", in, tt.limit, tt.st, got, tt.want)
+		}
+		if tt.st.measure(got) > tt.limit || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d, %d) = %q exceeds the limit or splits a rune", in, tt.limit, tt.st, got)
+		}
+	}
+}
+
+func TestTruncateStrategyMeasure(t *testing.T) {
+	tests := []struct {
+		in           string
+		runes, bytes int
+	}{
+		{"abc", 3, 3},
+		{"中文", 2, 6},
+		{"😀", 1, 4},
+		{"e\u0301", 2, 3},
+		{"ｆｕｌｌ", 4, 12},
+	}
+	for _, tt := range tests {
+		if got := truncateByRunes.measure(tt.in); got != tt.runes {
+			t.Errorf("runes(%q) = %d, want %d", tt.in, got, tt.runes)
+		}
+		if got := truncateByBytes.measure(tt.in); got != tt.bytes {
+			t.Errorf("bytes(%q) = %d, want %d", tt.in, got, tt.bytes)
+		}
+	}
+}
+
+func TestTruncateMiddleStrategies(t *testing.T) {
+	const in = "你好世界你好世界"
+	tests := []struct {
+		st    truncateStrategy
+		limit int
+		want  string
+	}{
+		{truncateByRunes, 7, "你好...世界"},
+		{truncateByBytes, 15, "你好...世界"},
+	}
+	for _, tt := range tests {
+		if got := truncateMiddle(in, tt.limit, tt.st); got != tt.want {
+			t.Errorf("truncateMiddle(%q, %d, %d) = %q, want %q", in, tt.limit, tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestHeadTailLines(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	in := strings.Join(lines, "\n")
+	marker := func(omitted int) string { return fmt.Sprintf("… (省略 %d 行) …", omitted) }
+
+	got := strings.Split(headTailLines(in, 3, marker), "\n")
+	want := []string{"line 1", "line 2", "line 3", "… (省略 14 行) …", "line 18", "line 19", "line 20"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("headTailLines(20 lines, 3) = %q, want %q", got, want)
+	}
+	for _, keep := range []int{10, 20, 0} {
+		if got := headTailLines(in, keep, marker); got != in {
+			t.Errorf("headTailLines(20 lines, %d) changed the text", keep)
+		}
+	}
+}
+
+func TestResultModeHeadTail(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, fmt.Sprintf("step %d ok", i))
+	}
+	n := testNotification()
+	n.LastAssistantMessage = strings.Join(lines, "\n")
+
+	cfg := testConfig(t, map[string]string{"FEISHU_RESULT_MODE": "head_tail", "FEISHU_RESULT_HEAD_TAIL_LINES": "2"})
+	got := resultText(n, cfg, false)
+	if want := "step 1 ok\nstep 2 ok\n… (省略 26 行) …\nstep 29 ok\nstep 30 ok"; got != want {
+		t.Errorf("head_tail result = %q, want %q", got, want)
+	}
+
+	// 默认 truncate 模式保持只保留开头
+	cfg = testConfig(t, nil)
+	if got := resultText(n, cfg, false); !strings.HasPrefix(got, "step 1 ok") || strings.Contains(got, "省略") {
+		t.Errorf("default result = %q", got)
+	}
+
+	// 单行过长时保留首尾
+	n.LastAssistantMessage = "BEGIN " + strings.Repeat("x", 2*resultLimit) + " END"
+	cfg = testConfig(t, map[string]string{"FEISHU_RESULT_MODE": "head_tail"})
+	if got := resultText(n, cfg, false); !strings.HasPrefix(got, "BEGIN") || !strings.HasSuffix(got, "END") || len([]rune(got)) > resultLimit {
+		t.Errorf("long single line = %q…", got[:20])
+	}
+}
+
+func TestResultModeInvalid(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"FEISHU_RESULT_MODE": "summary"},
+		{"FEISHU_RESULT_MODE": "head_tail", "FEISHU_RESULT_HEAD_TAIL_LINES": "0"},
+	} {
+		isolateEnv(t, env)
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("loadConfig accepted %v", env)
+		}
+	}
+}
+
+// 企业微信按字节截断执行结果, 飞书按字符截断
+func TestResultTextTruncateBy(t *testing.T) {
+	n := testNotification()
+	n.LastAssistantMessage = strings.Repeat("测试通过。", 200)
+
+	cfg := testConfig(t, nil)
+	if got := utf8.RuneCountInString(resultText(n, cfg, false)); got != resultLimit {
+		t.Errorf("feishu result is %d runes, want %d", got, resultLimit)
+	}
+
+	cfg = testConfig(t, map[string]string{
+		"FEISHU_BACKEND":           backendWeCom,
+		"FEISHU_WECOM_WEBHOOK_URL": "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=x",
+	})
+	notifiers, err := newWeComNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wcfg := notifiers[0].(*wecomNotifier).cfg
+	if wcfg.TruncateBy != truncateByBytes {
+		t.Fatalf("wecom TruncateBy = %d, want truncateByBytes", wcfg.TruncateBy)
+	}
+	got := resultText(n, wcfg, false)
+	if len(got) > resultLimit || !utf8.ValidString(got) || !strings.HasSuffix(got, ellipsis) {
+		t.Errorf("wecom result is %d bytes (%q), want at most %d", len(got), got, resultLimit)
+	}
+}
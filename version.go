@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+// binaryName 程序名, 用于版本信息与卡片底部
+const binaryName = "codex-notify"
+
+// 构建信息, 通过 -ldflags "-X main.version=... -X main.commit=... -X main.date=..." 注入
+var (
+	version = "dev"
+	commit  = "dev"
+	date    = "dev"
+)
+
+// versionString 返回可读的版本信息
+func versionString() string {
+	return fmt.Sprintf("%s %s (commit %s, built %s)", binaryName, version, commit, date)
+}
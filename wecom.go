@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ================= 企业微信群机器人 sink =================
+// FEISHU_BACKEND=wecom 时改为发送到企业微信群机器人 (FEISHU_WECOM_WEBHOOK_URL, 形如 .../webhook/send?key=xxx),
+// 内容与飞书卡片相同, 以 markdown 消息发送。企业微信的 markdown 不支持代码块与表格,
+// 且 content 最长 4096 字节, 消息体上限因此收紧到 wecomMaxPayloadBytes。
+// 也可以在 FEISHU_ROUTES 中以 wecom: 前缀只让部分目录发往企业微信。
+
+const backendWeCom = "wecom"
+
+// wecomMaxPayloadBytes 企业微信 markdown 消息的 content 上限
+const wecomMaxPayloadBytes = 4096
+
+// wecomRateLimitCodes 企业微信机器人的频率限制错误码 (每分钟最多 20 条)
+var wecomRateLimitCodes = map[int]bool{
+	45009: true, // api freq out of limit
+}
+
+func init() {
+	registerBackend(backendWeCom, func(cfg *FeishuConfig, webhook, _ string) {
+		cfg.WeComWebhookURL = webhook
+	})
+	registerNotifier("wecom", newWeComNotifier)
+}
+
+type wecomNotifier struct {
+	cfg    FeishuConfig
+	client doer
+}
+
+func newWeComNotifier(cfg FeishuConfig) ([]Notifier, error) {
+	if cfg.Backend != backendWeCom {
+		return nil, nil
+	}
+	if cfg.WeComWebhookURL == "" {
+		return nil, errors.New("FEISHU_BACKEND=wecom requires FEISHU_WECOM_WEBHOOK_URL")
+	}
+	if err := validateWebhookURL(cfg.WeComWebhookURL); err != nil {
+		return nil, fmt.Errorf("FEISHU_WECOM_WEBHOOK_URL: %w", err)
+	}
+	if cfg.MaxPayloadBytes <= 0 || cfg.MaxPayloadBytes > wecomMaxPayloadBytes {
+		cfg.MaxPayloadBytes = wecomMaxPayloadBytes
+	}
+	// 企业微信按字节计算长度, 中文结果按字符截断会占用三倍的配额
+	cfg.TruncateBy = truncateByBytes
+	return []Notifier{&wecomNotifier{cfg: cfg, client: newHTTPClient(cfg)}}, nil
+}
+
+func (w *wecomNotifier) Name() string { return "wecom" }
+
+func (w *wecomNotifier) Target() string { return redactQueryToken(w.cfg.WeComWebhookURL, "key") }
+
+type wecomMessage struct {
+	MsgType  string        `json:"msgtype"`
+	Markdown wecomMarkdown `json:"markdown"`
+}
+
+type wecomMarkdown struct {
+	Content string `json:"content"`
+}
+
+func (w *wecomNotifier) Send(ctx context.Context, n CodexNotification) error {
+	cfg := w.cfg
+	msg, err := fitPayload(n, cfg, func(cfg FeishuConfig) interface{} {
+		n, cfg, failed := prepareNotification(n, cfg)
+		return wecomMessage{MsgType: "markdown", Markdown: wecomMarkdown{Content: markdownReport(n, cfg, failed)}}
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return writeDryRun(os.Stdout, msg, cfg.DryRunIndent, useColor(os.Stdout))
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.client, cfg, cfg.WeComWebhookURL, w.Target(), nil, payload, errcodeChecker("wecom", wecomRateLimitCodes))
+}
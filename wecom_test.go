@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// newTestWeComNotifier 构造发往 srv 的企业微信 sink
+func newTestWeComNotifier(t *testing.T, srv *httptest.Server, env map[string]string) *wecomNotifier {
+	t.Helper()
+	if env == nil {
+		env = map[string]string{}
+	}
+	env["FEISHU_BACKEND"] = backendWeCom
+	env["FEISHU_WECOM_WEBHOOK_URL"] = srv.URL + "/cgi-bin/webhook/send?key=test-key"
+	if _, ok := env["FEISHU_MAX_RETRIES"]; !ok {
+		env["FEISHU_MAX_RETRIES"] = "0"
+	}
+	notifiers, err := newWeComNotifier(testConfig(t, env))
+	if err != nil {
+		t.Fatalf("newWeComNotifier: %v", err)
+	}
+	return notifiers[0].(*wecomNotifier)
+}
+
+// decodeWeComMessage 解析请求体中的 markdown 消息
+func decodeWeComMessage(t *testing.T, body []byte) wecomMessage {
+	t.Helper()
+	var msg wecomMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("request body is not valid JSON: %v\n%s", err, body)
+	}
+	return msg
+}
+
+func TestWeComSend(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	w := newTestWeComNotifier(t, srv, nil)
+	if err := w.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	msg := decodeWeComMessage(t, (*got)[0].Body)
+	if msg.MsgType != "markdown" {
+		t.Errorf("msgtype = %q, want markdown", msg.MsgType)
+	}
+	for _, want := range []string{"修复登录页面的样式问题", "已修复, 所有测试通过。", "/work/demo"} {
+		if !strings.Contains(msg.Markdown.Content, want) {
+			t.Errorf("content does not contain %q:\n%s", want, msg.Markdown.Content)
+		}
+	}
+	if ct := (*got)[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if strings.Contains(w.Target(), "test-key") {
+		t.Errorf("Target = %q, want the key hidden", w.Target())
+	}
+}
+
+// 中文内容按字节计算, 消息体收紧到 4096 字节以内且不会截断在多字节字符中间
+func TestWeComPayloadCap(t *testing.T) {
+	for _, env := range []map[string]string{nil, {"FEISHU_MAX_PAYLOAD_BYTES": "20000"}} {
+		srv, got := feishuStub(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+		w := newTestWeComNotifier(t, srv, env)
+		if w.cfg.MaxPayloadBytes != wecomMaxPayloadBytes {
+			t.Errorf("MaxPayloadBytes = %d with %v, want %d", w.cfg.MaxPayloadBytes, env, wecomMaxPayloadBytes)
+		}
+		if err := w.Send(context.Background(), hugeNotification()); err != nil {
+			t.Fatal(err)
+		}
+		body := (*got)[0].Body
+		if len(body) > wecomMaxPayloadBytes {
+			t.Errorf("payload is %d bytes, want at most %d", len(body), wecomMaxPayloadBytes)
+		}
+		content := decodeWeComMessage(t, body).Markdown.Content
+		if len(content) > wecomMaxPayloadBytes || !utf8.ValidString(content) {
+			t.Errorf("content is %d bytes or splits a rune", len(content))
+		}
+		if !strings.Contains(content, "（内容已截断）") {
+			t.Error("trimmed content has no truncation note")
+		}
+	}
+}
+
+func TestWeComErrcode(t *testing.T) {
+	srv, got := feishuStub(t, http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	w := newTestWeComNotifier(t, srv, map[string]string{"FEISHU_MAX_RETRIES": "2", "FEISHU_RETRY_DELAY": "1ms"})
+	err := w.Send(context.Background(), testNotification())
+	var platformErr *PlatformError
+	if !errors.As(err, &platformErr) || platformErr.Platform != "wecom" || platformErr.Code != 93000 || platformErr.RateLimited {
+		t.Fatalf("Send error = %v, want a non-rate-limited wecom error 93000", err)
+	}
+	if len(*got) != 1 {
+		t.Errorf("got %d requests, want no retry for errcode 93000", len(*got))
+	}
+	if strings.Contains(err.Error(), "test-key") {
+		t.Errorf("error leaks the webhook key: %v", err)
+	}
+
+	// 频率限制错误码按配置重试
+	srv, got = feishuStub(t, http.StatusOK, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+	w = newTestWeComNotifier(t, srv, map[string]string{"FEISHU_MAX_RETRIES": "2", "FEISHU_RETRY_DELAY": "1ms"})
+	err = w.Send(context.Background(), testNotification())
+	if !errors.As(err, &platformErr) || !platformErr.RateLimited {
+		t.Fatalf("Send error = %v, want a rate-limited wecom error", err)
+	}
+	if len(*got) != 3 {
+		t.Errorf("got %d requests, want 3 (two retries)", len(*got))
+	}
+}
+
+func TestWeComHTTPError(t *testing.T) {
+	srv, _ := feishuStub(t, http.StatusNotFound, `not found`)
+	w := newTestWeComNotifier(t, srv, nil)
+	var statusErr *HTTPStatusError
+	if err := w.Send(context.Background(), testNotification()); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Send error = %v, want HTTP 404", err)
+	}
+}